@@ -4,8 +4,9 @@ package api
 
 import (
 	"encoding/hex"
+	"errors"
+	"math"
 	"net"
-	"sort"
 	"strconv"
 	"time"
 
@@ -24,6 +25,13 @@ type Server struct {
 	bs      *database.BlockStorage
 }
 
+// walletResponse це гаманець разом з балансом у монетах.
+// Усі суми в JSON API передаются в копійках (1 монета = chain.Coin копійок).
+type walletResponse struct {
+	chain.Wallet
+	BalanceCoins string `json:"balance_coins"` // наприклад "1.50000000"
+}
+
 // NewServer створює новий екземпляр API-сервера.
 func NewServer(node *p2p.Node, mempool *chain.Mempool, bs *database.BlockStorage) *Server {
 	app := fiber.New()
@@ -64,10 +72,18 @@ func NewServer(node *p2p.Node, mempool *chain.Mempool, bs *database.BlockStorage
 func (s *Server) setupRoutes() {
 	s.app.Get("/", s.handleGetStatus)
 	s.app.Get("/block/:id", s.handleGetBlock)
+	s.app.Get("/block/:id/proof/:index", s.handleGetTxProof)
+	s.app.Get("/block/:id/commit", s.handleGetCommit)
 	s.app.Get("/txs", s.handleGetMempoolLen)
 	s.app.Get("/blocks", s.handleGetAllBlocks)
 	s.app.Get("/addr/:id", s.handleGetBalance)
+	s.app.Get("/addr/:id/txs", s.handleGetAddressTxs)
+	s.app.Get("/addr/:id/proof", s.handleGetWalletProof)
+	s.app.Get("/addr/:id/unbonding", s.handleGetUnbonding)
+	s.app.Get("/addr/:id/delegations", s.handleGetDelegations)
+	s.app.Get("/tx/:hash", s.handleGetTx)
 	s.app.Get("/lastBlock", s.handleGetLastBlock)
+	s.app.Get("/validators", s.handleGetValidatorSet)
 	s.app.Post("/tx", s.handlePostTx)
 
 	// щоб сервер не відповідав усіляким підораскам
@@ -114,23 +130,107 @@ func (s *Server) handleGetBlock(c *fiber.Ctx) error {
 	return c.JSON(block)
 }
 
-func (s *Server) handleGetAllBlocks(c *fiber.Ctx) error {
-	blocks, err := s.bs.GetAllBlocks()
+// handleGetTxProof повертає заголовок блоку, транзакцію і доказ її входження в блок.
+// Цього достатньо, щоб перевірити транзакцію через chain.VerifyTxProof без завантаження всього блоку.
+func (s *Server) handleGetTxProof(c *fiber.Ctx) error {
+	blockHeight64, err := strconv.ParseUint(c.Params("id"), 10, 32)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Invalid ID format",
+		})
+	}
+	index, err := strconv.Atoi(c.Params("index"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Invalid index format",
+		})
+	}
+
+	block, err := s.bs.GetBlock(uint32(blockHeight64))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "помилка отримання блоку",
+		})
+	}
+
+	proof, err := block.TxProof(index)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
 			"error": err.Error(),
 		})
 	}
 
-	// сортувати по зростанню номера блока
-	sort.Slice(blocks, func(i int, j int) bool {
-		return blocks[i].Height < blocks[j].Height
+	return c.JSON(fiber.Map{
+		"header": block.BlockHeader,
+		"hash":   block.Hash,
+		"tx":     block.Transactions[index],
+		"proof":  proof,
 	})
+}
+
+// handleGetCommit повертає заголовок блоку і сертифікат з precommit валідаторів, які його прийняли.
+// Цього достатньо, щоб незалежно перевірити фінальність блоку через chain.CommitCertificate.Verify.
+func (s *Server) handleGetCommit(c *fiber.Ctx) error {
+	blockHeight64, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Invalid ID format",
+		})
+	}
+
+	block, err := s.bs.GetBlock(uint32(blockHeight64))
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"error": "блок не знайдено",
+		})
+	}
+
+	cert, err := s.bs.GetCommit(block.Height)
+	if errors.Is(err, database.ErrNoCommit) {
+		return c.Status(404).JSON(fiber.Map{
+			"error": "для цього блоку немає сертифікату",
+		})
+	}
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error": "помилка отримання сертифікату",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"header": block.BlockHeader,
+		"hash":   block.Hash,
+		"commit": cert,
+	})
+}
+
+// handleGetAllBlocks повертає блоки в порядку зростання висоти.
+// Необовʼязкові параметри from і to обмежують висоти (включно).
+func (s *Server) handleGetAllBlocks(c *fiber.Ctx) error {
+	from, err := strconv.ParseUint(c.Query("from", "0"), 10, 32)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Invalid from format",
+		})
+	}
+	to, err := strconv.ParseUint(c.Query("to", strconv.FormatUint(math.MaxUint32, 10)), 10, 32)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Invalid to format",
+		})
+	}
+
+	blocks, err := s.bs.GetBlocksRange(uint32(from), uint32(to))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
 
 	return c.JSON(blocks)
 }
 
-// handlePostTx обробляє нову транзакцію.
+// handlePostTx обробляє нову транзакцію. amount і fee мають бути в копійках.
 func (s *Server) handlePostTx(c *fiber.Ctx) error {
 	var tx chain.Transaction
 	if err := c.BodyParser(&tx); err != nil {
@@ -147,7 +247,7 @@ func (s *Server) handlePostTx(c *fiber.Ctx) error {
 }
 
 func (s *Server) handleGetMempoolLen(c *fiber.Ctx) error {
-	return c.SendString(strconv.Itoa(len(s.mempool.TXs)))
+	return c.SendString(strconv.Itoa(s.mempool.Len()))
 }
 
 func (s *Server) handleGetBalance(c *fiber.Ctx) error {
@@ -165,7 +265,155 @@ func (s *Server) handleGetBalance(c *fiber.Ctx) error {
 			"error": err,
 		})
 	}
-	return c.Status(200).JSON(wallet)
+	return c.Status(200).JSON(walletResponse{
+		Wallet:       wallet,
+		BalanceCoins: chain.FormatAmount(wallet.Balance),
+	})
+}
+
+// handleGetWalletProof повертає заголовок останнього блоку і доказ гаманця в стані після нього.
+// Доказ перевіряєтся через chain.VerifyStateProof з StateRoot заголовку, а заголовок - сертифікатом з /block/:id/commit
+func (s *Server) handleGetWalletProof(c *fiber.Ctx) error {
+	addrBytes, err := hex.DecodeString(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	block, proof, err := s.bs.GetWalletProof(addrBytes)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error": "помилка отримання доказу",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"header": block.BlockHeader,
+		"hash":   block.Hash,
+		"proof":  proof,
+	})
+}
+
+// handleGetUnbonding повертає незавершені unbond адреси по валідаторах: суму і висоту, з якої її можна повернути транзакцією withdraw
+func (s *Server) handleGetUnbonding(c *fiber.Ctx) error {
+	addrBytes, err := hex.DecodeString(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error": err,
+		})
+	}
+
+	unbondings, err := s.bs.GetUnbondings(addrBytes)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	return c.JSON(unbondings)
+}
+
+type delegationResponse struct {
+	chain.Delegation
+	Amount int64 `json:"amount"` // скільки stake зараз коштують shares
+}
+
+// handleGetDelegations повертає делегації адреси разом з їх поточною сумою
+func (s *Server) handleGetDelegations(c *fiber.Ctx) error {
+	addrBytes, err := hex.DecodeString(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error": err,
+		})
+	}
+
+	delegations, err := s.bs.GetDelegatorDelegations(addrBytes)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	res := make([]delegationResponse, 0, len(delegations))
+	for _, d := range delegations {
+		validator, err := s.bs.GetValidator(d.Validator)
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		res = append(res, delegationResponse{Delegation: d, Amount: validator.TokensFor(d.Shares)})
+	}
+	return c.JSON(res)
+}
+
+// handleGetValidatorSet повертає набір валідаторів поточної епохи, який створює і підписує наступний блок,
+// і його hash (ValidatorsHash в заголовку наступного блоку)
+func (s *Server) handleGetValidatorSet(c *fiber.Ctx) error {
+	set, err := s.bs.GetValidatorSet()
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	return c.JSON(fiber.Map{
+		"validators": set.Validators,
+		"hash":       set.Hash(),
+	})
+}
+
+// handleGetTx шукає транзакцію за її hash (hex).
+func (s *Server) handleGetTx(c *fiber.Ctx) error {
+	hash, err := hex.DecodeString(c.Params("hash"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Invalid hash format",
+		})
+	}
+
+	tx, err := s.bs.GetTransaction(hash)
+	if errors.Is(err, database.ErrTxNotFound) {
+		return c.Status(404).JSON(fiber.Map{
+			"error": "транзакцію не знайдено",
+		})
+	}
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error": "помилка отримання транзакції",
+		})
+	}
+	return c.JSON(tx)
+}
+
+// handleGetAddressTxs повертає транзакції адреси від нових до старих.
+// Параметри offset (за замовчуванням 0) і limit (за замовчуванням 20, максимум 100) для пагінації.
+func (s *Server) handleGetAddressTxs(c *fiber.Ctx) error {
+	addrBytes, err := hex.DecodeString(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Invalid address format",
+		})
+	}
+
+	offset := c.QueryInt("offset", 0)
+	limit := c.QueryInt("limit", 20)
+	if offset < 0 || limit <= 0 || limit > 100 {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "offset має бути >= 0, limit від 1 до 100",
+		})
+	}
+
+	txs, err := s.bs.GetAddressTxs(addrBytes, offset, limit)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	return c.JSON(fiber.Map{
+		"offset": offset,
+		"limit":  limit,
+		"txs":    txs,
+	})
 }
 
 func (s *Server) handleGetLastBlock(c *fiber.Ctx) error {
@@ -178,7 +426,7 @@ func (s *Server) handleGetLastBlock(c *fiber.Ctx) error {
 	return c.JSON(lastBlock)
 }
 
-// Start запускає HTTP-сервер.
-func (s *Server) Start() {
-	log.Fatal().Err(s.app.Listen(":8081")).Msg("помилка запуску http серверу")
+// Start запускає HTTP-сервер на адресі addr, наприклад ":8081".
+func (s *Server) Start(addr string) {
+	log.Fatal().Err(s.app.Listen(addr)).Msg("помилка запуску http серверу")
 }
@@ -0,0 +1,97 @@
+package chain
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// Усі суми (баланси, перекази, fee, stake) зберігаются в копійках - найменших неподільних одиницях.
+const (
+	Decimals       = 8
+	Coin     int64 = 100_000_000 // 10^Decimals копійок в одній монеті
+)
+
+var (
+	ErrAmountOverflow    = errors.New("переповнення суми")
+	ErrInsufficientFunds = errors.New("недостатньо коштів")
+)
+
+// AddAmount додає дві суми з перевіркою на переповнення
+func AddAmount(a, b int64) (int64, error) {
+	if (b > 0 && a > math.MaxInt64-b) || (b < 0 && a < math.MinInt64-b) {
+		return 0, ErrAmountOverflow
+	}
+	return a + b, nil
+}
+
+// SubAmount віднімає b від a з перевіркою на переповнення
+func SubAmount(a, b int64) (int64, error) {
+	if (b > 0 && a < math.MinInt64+b) || (b < 0 && a > math.MaxInt64+b) {
+		return 0, ErrAmountOverflow
+	}
+	return a - b, nil
+}
+
+// MulDiv рахує a * b / c з округленням вниз без переповнення проміжного результату.
+// a і b не від'ємні, c більше 0, а результат не більший за a або b
+func MulDiv(a, b, c int64) int64 {
+	r := new(big.Int).Mul(big.NewInt(a), big.NewInt(b))
+	return r.Div(r, big.NewInt(c)).Int64()
+}
+
+// FormatAmount перетворює суму в копійках на рядок в монетах, наприклад 150000000 -> "1.50000000"
+func FormatAmount(a int64) string {
+	sign := ""
+	u := uint64(a)
+	if a < 0 {
+		sign = "-"
+		u = uint64(-(a + 1)) + 1 // щоб не переповнитись на math.MinInt64
+	}
+	return fmt.Sprintf("%s%d.%0*d", sign, u/uint64(Coin), Decimals, u%uint64(Coin))
+}
+
+// ParseAmount перетворює рядок в монетах ("1.5", "0.00000001", "42") на суму в копійках
+func ParseAmount(s string) (int64, error) {
+	whole, frac, _ := strings.Cut(s, ".")
+	if whole == "" && frac == "" {
+		return 0, fmt.Errorf("порожня сума")
+	}
+	if len(frac) > Decimals {
+		return 0, fmt.Errorf("сума має більше ніж %d знаків після коми", Decimals)
+	}
+	if !isDigits(whole) || !isDigits(frac) {
+		return 0, fmt.Errorf("сума може містити тільки цифри і крапку, без знаку")
+	}
+
+	var w, f int64
+	var err error
+	if whole != "" {
+		if w, err = strconv.ParseInt(whole, 10, 64); err != nil {
+			return 0, err
+		}
+	}
+	if frac != "" {
+		frac += strings.Repeat("0", Decimals-len(frac))
+		if f, err = strconv.ParseInt(frac, 10, 64); err != nil {
+			return 0, err
+		}
+	}
+
+	if w > math.MaxInt64/Coin {
+		return 0, ErrAmountOverflow
+	}
+	return AddAmount(w*Coin, f)
+}
+
+func isDigits(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
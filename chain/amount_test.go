@@ -0,0 +1,179 @@
+package chain
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestAddSubAmount(t *testing.T) {
+	tests := []struct {
+		name   string
+		a, b   int64
+		add    int64
+		addErr bool
+		sub    int64
+		subErr bool
+	}{
+		{"звичайні суми", 150, 50, 200, false, 100, false},
+		{"нуль", math.MaxInt64, 0, math.MaxInt64, false, math.MaxInt64, false},
+		{"на межі", math.MaxInt64 - 1, 1, math.MaxInt64, false, math.MaxInt64 - 2, false},
+		{"переповнення вгору", math.MaxInt64, 1, 0, true, math.MaxInt64 - 1, false},
+		{"переповнення вниз", math.MinInt64, -1, 0, true, math.MinInt64 + 1, false},
+		{"відняти від'ємне", math.MaxInt64, -1, math.MaxInt64 - 1, false, 0, true},
+		{"відняти від мінімуму", math.MinInt64, 1, math.MinInt64 + 1, false, 0, true},
+		{"дві великі суми", math.MaxInt64 / 2, math.MaxInt64/2 + 2, 0, true, -2, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			add, err := AddAmount(tt.a, tt.b)
+			if (err != nil) != tt.addErr || (err == nil && add != tt.add) {
+				t.Errorf("AddAmount = %d, %v, очікувалось %d, помилка: %v", add, err, tt.add, tt.addErr)
+			}
+			if err != nil && !errors.Is(err, ErrAmountOverflow) {
+				t.Errorf("AddAmount повернув %v замість ErrAmountOverflow", err)
+			}
+
+			sub, err := SubAmount(tt.a, tt.b)
+			if (err != nil) != tt.subErr || (err == nil && sub != tt.sub) {
+				t.Errorf("SubAmount = %d, %v, очікувалось %d, помилка: %v", sub, err, tt.sub, tt.subErr)
+			}
+		})
+	}
+}
+
+func TestMulDiv(t *testing.T) {
+	tests := []struct {
+		a, b, c int64
+		want    int64
+	}{
+		{10, 3, 4, 7},
+		{1, 1, 3, 0},
+		{math.MaxInt64, math.MaxInt64, math.MaxInt64, math.MaxInt64},
+		{math.MaxInt64, 500, 10000, math.MaxInt64 / 20},
+		{math.MaxInt64, 1, 2, math.MaxInt64 / 2},
+		{0, math.MaxInt64, 7, 0},
+	}
+	for _, tt := range tests {
+		if got := MulDiv(tt.a, tt.b, tt.c); got != tt.want {
+			t.Errorf("MulDiv(%d, %d, %d) = %d, очікувалось %d", tt.a, tt.b, tt.c, got, tt.want)
+		}
+	}
+}
+
+func TestFormatParseAmount(t *testing.T) {
+	tests := []struct {
+		amount int64
+		str    string
+	}{
+		{0, "0.00000000"},
+		{1, "0.00000001"},
+		{150000000, "1.50000000"},
+		{Coin, "1.00000000"},
+		{math.MaxInt64, "92233720368.54775807"},
+	}
+	for _, tt := range tests {
+		if got := FormatAmount(tt.amount); got != tt.str {
+			t.Errorf("FormatAmount(%d) = %q, очікувалось %q", tt.amount, got, tt.str)
+		}
+		if got, err := ParseAmount(tt.str); err != nil || got != tt.amount {
+			t.Errorf("ParseAmount(%q) = %d, %v, очікувалось %d", tt.str, got, err, tt.amount)
+		}
+	}
+
+	if got := FormatAmount(math.MinInt64); got != "-92233720368.54775808" {
+		t.Errorf("FormatAmount(MinInt64) = %q", got)
+	}
+	if got := FormatAmount(-150000000); got != "-1.50000000" {
+		t.Errorf("FormatAmount(-150000000) = %q", got)
+	}
+}
+
+func TestParseAmount(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    int64
+		wantErr bool
+	}{
+		{"1.5", 150000000, false},
+		{"42", 42 * Coin, false},
+		{".5", 50000000, false},
+		{"5.", 5 * Coin, false},
+		{"0.00000001", 1, false},
+		{"92233720368.54775807", math.MaxInt64, false},
+		{"92233720368.54775808", 0, true},
+		{"92233720369", 0, true},
+		{"999999999999999999999", 0, true},
+		{"0.000000001", 0, true},
+		{"", 0, true},
+		{".", 0, true},
+		{"-1", 0, true},
+		{"+1", 0, true},
+		{"1.-5", 0, true},
+		{"1,5", 0, true},
+		{"abc", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseAmount(tt.in)
+		if (err != nil) != tt.wantErr || (err == nil && got != tt.want) {
+			t.Errorf("ParseAmount(%q) = %d, %v, очікувалось %d, помилка: %v", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestWalletCreditDebit(t *testing.T) {
+	tests := []struct {
+		name    string
+		balance int64
+		credit  int64
+		debit   int64
+		wantErr error
+		wantBal int64
+	}{
+		{"звичайні суми", 100, 50, 120, nil, 30},
+		{"весь баланс", 100, 0, 100, nil, 0},
+		{"недостатньо коштів", 100, 0, 101, ErrInsufficientFunds, 100},
+		{"переповнення при поповненні", math.MaxInt64, 1, 0, ErrAmountOverflow, math.MaxInt64},
+		{"списання від'ємної суми понад максимум", math.MaxInt64, 0, -1, ErrAmountOverflow, math.MaxInt64},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := Wallet{Balance: tt.balance}
+			err := w.Credit(tt.credit)
+			if err == nil {
+				err = w.Debit(tt.debit)
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("помилка %v, очікувалось %v", err, tt.wantErr)
+			}
+			// після помилки баланс не змінюєтся
+			if w.Balance != tt.wantBal {
+				t.Errorf("баланс %d, очікувалось %d", w.Balance, tt.wantBal)
+			}
+		})
+	}
+}
+
+func TestTransactionDebit(t *testing.T) {
+	tests := []struct {
+		kind    TxKind
+		amount  int64
+		fee     int64
+		want    int64
+		wantErr bool
+	}{
+		{TxTransfer, 100, 5, 105, false},
+		{TxBond, 100, 5, 105, false},
+		{TxUnbond, 100, 5, 5, false},
+		{TxWithdraw, 0, 5, 5, false},
+		{TxTransfer, math.MaxInt64, 1, 0, true},
+		{TxUnbond, math.MaxInt64, 1, 1, false},
+	}
+	for _, tt := range tests {
+		tx := Transaction{Kind: tt.kind, Amount: tt.amount, Fee: tt.fee}
+		got, err := tx.Debit()
+		if (err != nil) != tt.wantErr || (err == nil && got != tt.want) {
+			t.Errorf("%s %d+%d: Debit = %d, %v, очікувалось %d, помилка: %v", tt.kind, tt.amount, tt.fee, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
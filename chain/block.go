@@ -6,41 +6,46 @@ package chain
 import (
 	"bytes"
 	"crypto/sha3"
-	"encoding/base64"
-	"encoding/json"
 	"fmt"
-	"sort"
 
 	"github.com/PQlite/crypto"
 	"github.com/rs/zerolog/log"
 )
 
-type Block struct {
-	Height       uint32         // Номер блоку
-	Timestamp    int64          // UNIX час
-	PrevHash     []byte         // Хеш попереднього блоку
-	Hash         []byte         // Хеш цього блоку (розраховується по іншим полям)
-	Proposer     []byte         // Адреса або публічний ключ того, хто створив блок
-	Transactions []*Transaction // Список транзакцій
-	Signature    []byte         // Підпис Proposer'а на блоку
+// BlockHeader це все, що входить в hash блоку. Транзакції входять тільки через TxRoot,
+// тому для перевірки входження транзакції в блок достатньо заголовку і MerkleProof
+type BlockHeader struct {
+	ChainID        string // Ідентифікатор мережі
+	Height         uint32 // Номер блоку
+	Round          uint32 // Раунд консенсусу на цій висоті, в якому блок було створено
+	Timestamp      int64  // UNIX час
+	PrevHash       []byte // Хеш попереднього блоку
+	LastCommitHash []byte // Hash сертифікату попереднього блоку. Порожній, якщо LastCommit немає
+	ValidatorsHash []byte // Hash набору валідаторів, які створюють і підписують цей блок. Порожній для genesis
+	Proposer       []byte // Адреса або публічний ключ того, хто створив блок
+	RandaoReveal   []byte // Секрет proposer, hash якого він зафіксував попереднім своїм блоком. Порожній, якщо це його перший блок
+	RandaoCommit   []byte // Hash секрету, який proposer відкриє в наступному своєму блоці
+	TxRoot         []byte // Merkle root транзакцій блоку
+	TxCount        uint32 // Кількість транзакцій блоку, з нею доказ входження транзакції перевіряє свій Total
+	EvidenceRoot   []byte // Merkle root доказів подвійного підпису
+	StateRoot      []byte // Корінь стану (гаманці і валідатори) після виконання блоку
 }
 
-// sortTransactions сортує транзакції в блоці за їх підписами.
-// Це необхідно для детерміністичної серіалізації.
-func (b *Block) sortTransactions() {
-	sort.Slice(b.Transactions, func(i, j int) bool {
-		return bytes.Compare(b.Transactions[i].Signature, b.Transactions[j].Signature) < 0
-	})
+type Block struct {
+	BlockHeader
+	Hash         []byte             // Хеш заголовку блоку
+	Transactions []*Transaction     // Список транзакцій, порядок фіксуєтся через TxRoot
+	Evidence     []*Evidence        // Докази подвійного підпису валідаторів, фіксуются через EvidenceRoot
+	LastCommit   *CommitCertificate // Сертифікат попереднього блоку: хто з валідаторів за нього голосував
+	Signature    []byte             // Підпис Proposer'а на заголовку блоку
 }
 
 func (b *Block) Sign(binPriv []byte) error {
-	b.sortTransactions()
-
-	BlockForSignBytes, err := json.Marshal(*b)
+	blockForSignBytes, err := b.MarshalDeterministic()
 	if err != nil {
 		return err
 	}
-	sig, err := crypto.Sign(binPriv, BlockForSignBytes)
+	sig, err := crypto.Sign(binPriv, blockForSignBytes)
 	if err != nil {
 		return err
 	}
@@ -50,38 +55,64 @@ func (b *Block) Sign(binPriv []byte) error {
 	return nil
 }
 
-func (b *Block) GenerateHash() error {
+// computeHash рахує hash блоку, не змінюючи поле Hash
+func (b *Block) computeHash() ([]byte, error) {
 	blockBytes, err := b.MarshalDeterministic()
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	blockHash := sha3.Sum224(blockBytes)
+	return blockHash[:], nil
+}
+
+func (b *Block) GenerateHash() error {
+	blockHash, err := b.computeHash()
+	if err != nil {
+		return err
+	}
 
-	b.Hash = blockHash[:]
+	b.Hash = blockHash
 
 	return nil
 }
 
-func (b *Block) Verify() error {
-	blockForVerify := *b
-	blockForVerify.Signature = nil
-	blockForVerify.Hash = nil
+func (b *Block) Verify(chainID string) error {
+	if b.ChainID != chainID {
+		return fmt.Errorf("блок для іншої мережі: %q, очікувалось: %q", b.ChainID, chainID)
+	}
+
+	if !bytes.Equal(b.TxRoot, b.ComputeTxRoot()) {
+		log.Error().Hex("tx root", b.TxRoot).Msg("TxRoot не збігаєтся з транзакціями блоку")
+		return fmt.Errorf("TxRoot не збігаєтся з транзакціями")
+	}
+	if int(b.TxCount) != len(b.Transactions) {
+		return fmt.Errorf("TxCount %d, а блок має %d транзакцій", b.TxCount, len(b.Transactions))
+	}
+
+	if !bytes.Equal(b.EvidenceRoot, b.ComputeEvidenceRoot()) {
+		log.Error().Hex("evidence root", b.EvidenceRoot).Msg("EvidenceRoot не збігаєтся з доказами блоку")
+		return fmt.Errorf("EvidenceRoot не збігаєтся з доказами")
+	}
 
-	blockForVerify.sortTransactions()
+	if !bytes.Equal(b.LastCommitHash, b.ComputeLastCommitHash()) {
+		log.Error().Hex("last commit hash", b.LastCommitHash).Msg("LastCommitHash не збігаєтся з LastCommit блоку")
+		return fmt.Errorf("LastCommitHash не збігаєтся з LastCommit")
+	}
 
-	if err := blockForVerify.GenerateHash(); err != nil {
+	localHash, err := b.computeHash()
+	if err != nil {
 		log.Error().Err(err).Msg("помилка генерації hash`у блоку")
 		return err
 	}
-	if !bytes.Equal(b.Hash, blockForVerify.Hash) {
-		log.Error().Hex("local hash", blockForVerify.Hash).Hex("out hash", b.Hash).Msg("hash перевірочногу блоку не збігаєтся")
+	if !bytes.Equal(b.Hash, localHash) {
+		log.Error().Hex("local hash", localHash).Hex("out hash", b.Hash).Msg("hash перевірочногу блоку не збігаєтся")
 		return fmt.Errorf("hash`s не збігаются")
 	}
 
-	binBlockForVerify, err := json.Marshal(blockForVerify)
+	binBlockForVerify, err := b.MarshalDeterministic()
 	if err != nil {
-		log.Error().Err(err).Msg("помилка json.Marshal в verify блоку")
+		log.Error().Err(err).Msg("помилка серіалізації в verify блоку")
 		return err
 	}
 
@@ -96,7 +127,7 @@ func (b *Block) Verify() error {
 func (b *Block) VerifyTransactions() error {
 	// OPTIMIZE: зробити обробку багатопотоковою
 	for _, tx := range b.Transactions {
-		err := tx.Verify()
+		err := tx.Verify(b.ChainID)
 		if err != nil {
 			log.Error().Err(err).Msg("помилка перевірки підписку транзакцій")
 			return err
@@ -106,51 +137,24 @@ func (b *Block) VerifyTransactions() error {
 	return nil
 }
 
-func (b *Block) MarshalDeterministic() ([]byte, error) {
-	b.sortTransactions()
-
-	res, err := json.Marshal(b)
-	if err != nil {
-		return nil, err
-	}
-
-	return res, nil
-}
-
-func CreateGenesisBlock() (Block, Validator, Wallet) {
-	genesisReceiverPubKeyBase64 := "jiiHWiJWyBn42vc8qNEdNY04hVysOnWl0Vx5Xb/mdGo="
-	pubBytes, err := base64.StdEncoding.DecodeString(genesisReceiverPubKeyBase64)
-	if err != nil {
-		panic(err)
+// Size розмір блоку для обмеження MaxBlockBytes: канонічне представлення заголовку, транзакцій, доказів і LastCommit.
+// Hash і підпис блоку не рахуются
+func (b *Block) Size() int64 {
+	size := int64(len(b.BlockHeader.MarshalDeterministic()))
+	for _, tx := range b.Transactions {
+		size += tx.Size()
 	}
-
-	valTx := Transaction{
-		From:      pubBytes,
-		To:        []byte("stake"),
-		Amount:    1,
-		Timestamp: 0,
-		Nonce:     1,
+	for _, e := range b.Evidence {
+		size += int64(len(e.MarshalDeterministic()))
 	}
-	balanceTx := Transaction{
-		From:      []byte("reward"),
-		To:        pubBytes,
-		Amount:    100000000,
-		Timestamp: 0,
-		Nonce:     2,
+	if b.LastCommit != nil {
+		size += int64(len(b.LastCommit.MarshalDeterministic()))
 	}
+	return size
+}
 
-	b := Block{
-		Height:       0,
-		Transactions: []*Transaction{&valTx, &balanceTx},
-	}
-	val := Validator{
-		Address: pubBytes,
-		Amount:  1,
-	}
-	wallet := Wallet{
-		Address: pubBytes,
-		Balance: 100000000,
-		Nonce:   2,
-	}
-	return b, val, wallet
+// MarshalDeterministic повертає канонічне бінарне представлення заголовку блоку (див. encoding.go).
+// І hash, і підпис рахуются з цих байтів
+func (b *Block) MarshalDeterministic() ([]byte, error) {
+	return b.BlockHeader.MarshalDeterministic(), nil
 }
@@ -0,0 +1,118 @@
+package chain
+
+import (
+	"bytes"
+	"crypto/sha3"
+	"fmt"
+)
+
+// Quorum перевіряє, чи достатньо power з total stake. Для рішень консенсусу це HasTwoThirds
+type Quorum func(power int64, total int64) bool
+
+// CommitCertificate доказ того, що блок прийнято: precommit голоси валідаторів за BlockHash
+// на висоті Height в раунді Round, які разом мають кворум stake
+type CommitCertificate struct {
+	ChainID    string `json:"chain_id"`
+	Height     uint32 `json:"height"`
+	Round      uint32 `json:"round"`
+	BlockHash  []byte `json:"block_hash"`
+	Precommits []Vote `json:"precommits"`
+}
+
+// NewCommitCertificate збирає сертифікат з голосів. Голоси за інші блоки і раунди пропускаются
+func NewCommitCertificate(b *Block, round uint32, votes []Vote) *CommitCertificate {
+	c := &CommitCertificate{
+		ChainID:   b.ChainID,
+		Height:    b.Height,
+		Round:     round,
+		BlockHash: b.Hash,
+	}
+	for _, v := range votes {
+		if v.Type == VotePrecommit && v.Height == b.Height && v.Round == round && bytes.Equal(v.BlockHash, b.Hash) {
+			c.Precommits = append(c.Precommits, v)
+		}
+	}
+	return c
+}
+
+// Verify перевіряє, що кожен голос - валідний precommit саме за цей блок від валідатора з validators,
+// жоден валідатор не голосує двічі, і разом голоси мають quorum від усього stake validators
+func (c *CommitCertificate) Verify(validators []Validator, quorum Quorum) error {
+	if len(c.BlockHash) == 0 {
+		return fmt.Errorf("сертифікат без hash блоку")
+	}
+
+	total, err := TotalStake(validators)
+	if err != nil {
+		return err
+	}
+
+	var power int64
+	seen := make(map[string]bool, len(c.Precommits))
+	for i := range c.Precommits {
+		v := &c.Precommits[i]
+
+		if v.Type != VotePrecommit || v.Height != c.Height || v.Round != c.Round || !bytes.Equal(v.BlockHash, c.BlockHash) {
+			return fmt.Errorf("голос %x не є precommit за блок сертифікату", v.Pub)
+		}
+		if seen[string(v.Pub)] {
+			return fmt.Errorf("валідатор %x голосує в сертифікаті двічі", v.Pub)
+		}
+		seen[string(v.Pub)] = true
+
+		validator := findValidator(validators, v.Pub)
+		if validator == nil {
+			return fmt.Errorf("%x не є валідатором", v.Pub)
+		}
+		if err := v.Verify(c.ChainID); err != nil {
+			return fmt.Errorf("не валідний підпис голосу %x: %w", v.Pub, err)
+		}
+
+		// не переповнюєтся, тому що TotalStake вже порахував суму всіх валідаторів
+		power += validator.Amount
+	}
+
+	if !quorum(power, total) {
+		return fmt.Errorf("голоси мають %d з %d stake, цього не достатньо", power, total)
+	}
+	return nil
+}
+
+// Hash sha3-256 канонічного кодування сертифікату
+func (c *CommitCertificate) Hash() []byte {
+	h := sha3.Sum256(c.MarshalDeterministic())
+	return h[:]
+}
+
+// ComputeLastCommitHash рахує hash LastCommit блоку, або порожній hash, якщо його немає
+func (b *Block) ComputeLastCommitHash() []byte {
+	if b.LastCommit == nil {
+		return nil
+	}
+	return b.LastCommit.Hash()
+}
+
+// VerifyBlock перевіряє, що сертифікат саме для блоку b
+func (c *CommitCertificate) VerifyBlock(b *Block) error {
+	if c.ChainID != b.ChainID || c.Height != b.Height {
+		return fmt.Errorf("сертифікат для %s/%d, а блок %s/%d", c.ChainID, c.Height, b.ChainID, b.Height)
+	}
+
+	blockHash, err := b.computeHash()
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(blockHash, c.BlockHash) {
+		return fmt.Errorf("сертифікат для іншого блоку")
+	}
+	return nil
+}
+
+func findValidator(validators []Validator, addr []byte) *Validator {
+	for i := range validators {
+		if bytes.Equal(validators[i].Address, addr) {
+			return &validators[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+package chain
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"testing"
+)
+
+func TestCommitCertificateVerify(t *testing.T) {
+	priv, pub := testKey()
+	otherPriv, otherPub := testOtherKey()
+	thirdPriv := ed25519.NewKeyFromSeed(bytes.Repeat([]byte{0x43}, ed25519.SeedSize))
+	thirdPub := thirdPriv.Public().(ed25519.PublicKey)
+
+	// разом 99: priv і otherPriv мають більше 2/3, priv і thirdPriv - рівно 2/3
+	validators := []Validator{
+		{Address: pub, Amount: 42},
+		{Address: otherPub, Amount: 33},
+		{Address: thirdPub, Amount: 24},
+	}
+	block := bytes.Repeat([]byte{0xaa}, 28)
+	cert := func(votes ...*Vote) *CommitCertificate {
+		c := &CommitCertificate{ChainID: testChainID, Height: 10, Round: 0, BlockHash: block}
+		for _, v := range votes {
+			c.Precommits = append(c.Precommits, *v)
+		}
+		return c
+	}
+
+	tests := []struct {
+		name    string
+		c       func() *CommitCertificate
+		quorum  Quorum
+		wantErr bool
+	}{
+		{"більше 2/3", func() *CommitCertificate {
+			return cert(testVote(t, priv, VotePrecommit, 0, 0xaa), testVote(t, otherPriv, VotePrecommit, 0, 0xaa))
+		}, HasTwoThirds, false},
+		{"всі валідатори", func() *CommitCertificate {
+			return cert(testVote(t, priv, VotePrecommit, 0, 0xaa), testVote(t, otherPriv, VotePrecommit, 0, 0xaa), testVote(t, thirdPriv, VotePrecommit, 0, 0xaa))
+		}, HasTwoThirds, false},
+		{"рівно 2/3", func() *CommitCertificate {
+			return cert(testVote(t, priv, VotePrecommit, 0, 0xaa), testVote(t, thirdPriv, VotePrecommit, 0, 0xaa))
+		}, HasTwoThirds, true},
+		{"більше 1/3", func() *CommitCertificate {
+			return cert(testVote(t, priv, VotePrecommit, 0, 0xaa))
+		}, HasOneThird, false},
+		{"без голосів", func() *CommitCertificate { return cert() }, HasTwoThirds, true},
+		{"без hash блоку", func() *CommitCertificate {
+			c := cert(testVote(t, priv, VotePrecommit, 0, 0xaa), testVote(t, otherPriv, VotePrecommit, 0, 0xaa))
+			c.BlockHash = nil
+			return c
+		}, HasTwoThirds, true},
+		{"голос двічі", func() *CommitCertificate {
+			v := testVote(t, priv, VotePrecommit, 0, 0xaa)
+			return cert(v, v, v)
+		}, HasTwoThirds, true},
+		{"prevote", func() *CommitCertificate {
+			return cert(testVote(t, priv, VotePrecommit, 0, 0xaa), testVote(t, otherPriv, VotePrevote, 0, 0xaa))
+		}, HasTwoThirds, true},
+		{"голос за інший блок", func() *CommitCertificate {
+			return cert(testVote(t, priv, VotePrecommit, 0, 0xaa), testVote(t, otherPriv, VotePrecommit, 0, 0xbb))
+		}, HasTwoThirds, true},
+		{"голос за nil", func() *CommitCertificate {
+			return cert(testVote(t, priv, VotePrecommit, 0, 0xaa), testVote(t, otherPriv, VotePrecommit, 0, 0))
+		}, HasTwoThirds, true},
+		{"голос іншого раунду", func() *CommitCertificate {
+			return cert(testVote(t, priv, VotePrecommit, 0, 0xaa), testVote(t, otherPriv, VotePrecommit, 1, 0xaa))
+		}, HasTwoThirds, true},
+		{"голос іншої висоти", func() *CommitCertificate {
+			c := cert(testVote(t, priv, VotePrecommit, 0, 0xaa), testVote(t, otherPriv, VotePrecommit, 0, 0xaa))
+			c.Height = 11
+			return c
+		}, HasTwoThirds, true},
+		{"не валідатор", func() *CommitCertificate {
+			outsider := ed25519.NewKeyFromSeed(bytes.Repeat([]byte{0x44}, ed25519.SeedSize))
+			return cert(testVote(t, priv, VotePrecommit, 0, 0xaa), testVote(t, otherPriv, VotePrecommit, 0, 0xaa), testVote(t, outsider, VotePrecommit, 0, 0xaa))
+		}, HasTwoThirds, true},
+		{"підроблений підпис", func() *CommitCertificate {
+			v := testVote(t, otherPriv, VotePrecommit, 0, 0xaa)
+			v.Signature = bytes.Clone(v.Signature)
+			v.Signature[0] ^= 1
+			return cert(testVote(t, priv, VotePrecommit, 0, 0xaa), v)
+		}, HasTwoThirds, true},
+		{"інша мережа", func() *CommitCertificate {
+			c := cert(testVote(t, priv, VotePrecommit, 0, 0xaa), testVote(t, otherPriv, VotePrecommit, 0, 0xaa))
+			c.ChainID = "other"
+			return c
+		}, HasTwoThirds, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.c().Verify(validators, tt.quorum); (err != nil) != tt.wantErr {
+				t.Errorf("помилка %v, очікувалась помилка: %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNewCommitCertificate(t *testing.T) {
+	priv, pub := testKey()
+	otherPriv, otherPub := testOtherKey()
+	validators := []Validator{{Address: pub, Amount: 50}, {Address: otherPub, Amount: 50}}
+
+	b := testSignedBlock(t, priv, 10, 0, 1)
+	vote := func(priv ed25519.PrivateKey, voteType VoteType, round uint32, hash []byte) Vote {
+		v := Vote{Type: voteType, Height: 10, Round: round, BlockHash: hash, Pub: priv.Public().(ed25519.PublicKey)}
+		if err := v.Sign(testChainID, priv); err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+	votes := []Vote{
+		vote(priv, VotePrevote, 1, b.Hash),
+		vote(priv, VotePrecommit, 0, b.Hash),
+		vote(priv, VotePrecommit, 1, b.Hash),
+		vote(otherPriv, VotePrecommit, 1, nil),
+		vote(otherPriv, VotePrecommit, 1, b.Hash),
+	}
+
+	c := NewCommitCertificate(b, 1, votes)
+	if len(c.Precommits) != 2 {
+		t.Fatalf("в сертифікат потрапило %d голосів, очікувалось 2", len(c.Precommits))
+	}
+	if err := c.Verify(validators, HasTwoThirds); err != nil {
+		t.Errorf("сертифікат не валідний: %v", err)
+	}
+	if err := c.VerifyBlock(b); err != nil {
+		t.Errorf("сертифікат не для свого блоку: %v", err)
+	}
+
+	other := testSignedBlock(t, priv, 10, 0, 2)
+	if c.VerifyBlock(other) == nil {
+		t.Error("сертифікат підійшов для іншого блоку")
+	}
+}
@@ -0,0 +1,473 @@
+package chain
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+)
+
+// Канонічне бінарне кодування, від якого залежать hash`і і підписи.
+// На відміну від json, воно не змінюєтся від перейменування полів чи тегів структур,
+// тому його можна відтворити в клієнтах на інших мовах. Опис формату і тестові вектори в docs/encoding.md
+//
+// Правила:
+//   - кожен обʼєкт починаєтся з байту версії кодування і байту типу обʼєкта
+//   - uint32 і int64 записуются big-endian фіксованої довжини (int64 в доповнювальному коді)
+//   - []byte і string записуются як довжина (uint32) і самі байти
+//   - bool записуєтся як uint32 0 або 1
+//   - списки записуются як кількість елементів (uint32) і самі елементи
+//   - поля записуются в порядку, визначеному в функціях нижче, а не в порядку полів структур
+
+// EncodingVersion версія канонічного кодування. Змінюєтся при будь-якій зміні формату після запуску мережі.
+// Версія 1 ще не випущена: поки мережі немає, формат змінюєтся без нової версії (див. docs/encoding.md)
+const EncodingVersion byte = 1
+
+const (
+	tagTransaction  byte = 0x01
+	tagBlock        byte = 0x02
+	tagVote         byte = 0x03
+	tagWallet       byte = 0x04
+	tagValidator    byte = 0x05
+	tagParams       byte = 0x06
+	tagProposal     byte = 0x07
+	tagEvidence     byte = 0x08
+	tagCommit       byte = 0x09
+	tagLiveness     byte = 0x0a
+	tagUnbonding    byte = 0x0b
+	tagDelegation   byte = 0x0c
+	tagValidatorSet byte = 0x0d
+	tagRandaoCommit byte = 0x0e
+)
+
+var errDecode = errors.New("не правельні байти канонічного кодування")
+
+type encoder struct {
+	buf bytes.Buffer
+}
+
+func newEncoder(tag byte) *encoder {
+	e := &encoder{}
+	e.buf.WriteByte(EncodingVersion)
+	e.buf.WriteByte(tag)
+	return e
+}
+
+func (e *encoder) writeUint32(v uint32) {
+	var b [4]byte
+	binary.BigEndian.PutUint32(b[:], v)
+	e.buf.Write(b[:])
+}
+
+func (e *encoder) writeInt64(v int64) {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], uint64(v))
+	e.buf.Write(b[:])
+}
+
+func (e *encoder) writeBytes(v []byte) {
+	e.writeUint32(uint32(len(v)))
+	e.buf.Write(v)
+}
+
+func (e *encoder) writeString(v string) {
+	e.writeBytes([]byte(v))
+}
+
+// writeBool записує bool як uint32 0 або 1
+func (e *encoder) writeBool(v bool) {
+	if v {
+		e.writeUint32(1)
+	} else {
+		e.writeUint32(0)
+	}
+}
+
+func (e *encoder) bytes() []byte {
+	return e.buf.Bytes()
+}
+
+type decoder struct {
+	data []byte
+	err  error
+}
+
+func newDecoder(data []byte, tag byte) *decoder {
+	d := &decoder{data: data}
+	if len(data) < 2 || data[0] != EncodingVersion || data[1] != tag {
+		d.err = errDecode
+		return d
+	}
+	d.data = data[2:]
+	return d
+}
+
+func (d *decoder) next(n int) []byte {
+	if d.err != nil {
+		return nil
+	}
+	if len(d.data) < n {
+		d.err = errDecode
+		return nil
+	}
+	b := d.data[:n]
+	d.data = d.data[n:]
+	return b
+}
+
+func (d *decoder) readUint32() uint32 {
+	b := d.next(4)
+	if b == nil {
+		return 0
+	}
+	return binary.BigEndian.Uint32(b)
+}
+
+func (d *decoder) readInt64() int64 {
+	b := d.next(8)
+	if b == nil {
+		return 0
+	}
+	return int64(binary.BigEndian.Uint64(b))
+}
+
+func (d *decoder) readBytes() []byte {
+	n := d.readUint32()
+	b := d.next(int(n))
+	if b == nil {
+		return nil
+	}
+	return bytes.Clone(b)
+}
+
+func (d *decoder) readBool() bool {
+	switch d.readUint32() {
+	case 0:
+		return false
+	case 1:
+		return true
+	}
+	if d.err == nil {
+		d.err = errDecode
+	}
+	return false
+}
+
+// finish повертає помилку, якщо дані були не правельні або залишились зайві байти
+func (d *decoder) finish() error {
+	if d.err == nil && len(d.data) != 0 {
+		d.err = errDecode
+	}
+	return d.err
+}
+
+// SigningBytes канонічне представлення транзакції без підпису. Саме ці байти підписуются
+func (t *Transaction) SigningBytes() []byte {
+	e := newEncoder(tagTransaction)
+	e.writeString(t.ChainID)
+	e.writeUint32(uint32(t.Kind))
+	e.writeBytes(t.From)
+	e.writeBytes(t.To)
+	e.writeInt64(t.Amount)
+	e.writeInt64(t.Fee)
+	e.writeInt64(t.Timestamp)
+	e.writeUint32(t.Nonce)
+	return e.bytes()
+}
+
+// MarshalDeterministic канонічне представлення транзакції разом з підписом
+func (t *Transaction) MarshalDeterministic() []byte {
+	e := &encoder{}
+	e.writeBytes(t.SigningBytes())
+	e.writeBytes(t.Signature)
+	return e.bytes()
+}
+
+// MarshalDeterministic канонічне представлення заголовку блоку
+func (h *BlockHeader) MarshalDeterministic() []byte {
+	e := newEncoder(tagBlock)
+	e.writeString(h.ChainID)
+	e.writeUint32(h.Height)
+	e.writeUint32(h.Round)
+	e.writeInt64(h.Timestamp)
+	e.writeBytes(h.PrevHash)
+	e.writeBytes(h.LastCommitHash)
+	e.writeBytes(h.ValidatorsHash)
+	e.writeBytes(h.Proposer)
+	e.writeBytes(h.RandaoReveal)
+	e.writeBytes(h.RandaoCommit)
+	e.writeBytes(h.TxRoot)
+	e.writeUint32(h.TxCount)
+	e.writeBytes(h.EvidenceRoot)
+	e.writeBytes(h.StateRoot)
+	return e.bytes()
+}
+
+// MarshalBinary канонічне представлення гаманця. В такому вигляді гаманець зберігаєтся в стані
+func (w *Wallet) MarshalBinary() ([]byte, error) {
+	e := newEncoder(tagWallet)
+	e.writeBytes(w.Address)
+	e.writeInt64(w.Balance)
+	e.writeUint32(w.Nonce)
+	return e.bytes(), nil
+}
+
+func (w *Wallet) UnmarshalBinary(data []byte) error {
+	d := newDecoder(data, tagWallet)
+	w.Address = d.readBytes()
+	w.Balance = d.readInt64()
+	w.Nonce = d.readUint32()
+	return d.finish()
+}
+
+// MarshalBinary канонічне представлення валідатора. В такому вигляді валідатор зберігаєтся в стані
+func (v *Validator) MarshalBinary() ([]byte, error) {
+	e := newEncoder(tagValidator)
+	e.writeBytes(v.Address)
+	e.writeInt64(v.Amount)
+	e.writeBool(v.Jailed)
+	e.writeUint32(v.JailedUntil)
+	e.writeBool(v.Tombstoned)
+	e.writeInt64(v.DelegatorShares)
+	e.writeInt64(v.Commission)
+	return e.bytes(), nil
+}
+
+func (v *Validator) UnmarshalBinary(data []byte) error {
+	d := newDecoder(data, tagValidator)
+	v.Address = d.readBytes()
+	v.Amount = d.readInt64()
+	v.Jailed = d.readBool()
+	v.JailedUntil = d.readUint32()
+	v.Tombstoned = d.readBool()
+	v.DelegatorShares = d.readInt64()
+	v.Commission = d.readInt64()
+	return d.finish()
+}
+
+// MarshalBinary канонічне представлення параметрів консенсусу. В такому вигляді вони зберігаются в стані
+func (p *ConsensusParams) MarshalBinary() ([]byte, error) {
+	e := newEncoder(tagParams)
+	e.writeInt64(p.BlockReward)
+	e.writeInt64(p.TimeoutPropose)
+	e.writeInt64(p.TimeoutVote)
+	e.writeInt64(p.TimeoutDelta)
+	e.writeInt64(p.SlashFractionDoubleSign)
+	e.writeInt64(p.EvidenceMaxAge)
+	e.writeInt64(p.SignedBlocksWindow)
+	e.writeInt64(p.MaxMissedVotes)
+	e.writeInt64(p.MaxMissedProposals)
+	e.writeInt64(p.SlashFractionDowntime)
+	e.writeInt64(p.JailDuration)
+	e.writeInt64(p.UnbondingPeriod)
+	e.writeInt64(p.RewardReductionInterval)
+	e.writeInt64(p.RewardReduction)
+	e.writeInt64(p.ProposerBonus)
+	e.writeInt64(p.EpochLength)
+	e.writeInt64(p.BlockTime)
+	e.writeInt64(p.MaxClockDrift)
+	e.writeInt64(p.MaxBlockBytes)
+	e.writeInt64(p.MaxBlockTxs)
+	e.writeInt64(p.MaxTxBytes)
+	return e.bytes(), nil
+}
+
+func (p *ConsensusParams) UnmarshalBinary(data []byte) error {
+	d := newDecoder(data, tagParams)
+	p.BlockReward = d.readInt64()
+	p.TimeoutPropose = d.readInt64()
+	p.TimeoutVote = d.readInt64()
+	p.TimeoutDelta = d.readInt64()
+	p.SlashFractionDoubleSign = d.readInt64()
+	p.EvidenceMaxAge = d.readInt64()
+	p.SignedBlocksWindow = d.readInt64()
+	p.MaxMissedVotes = d.readInt64()
+	p.MaxMissedProposals = d.readInt64()
+	p.SlashFractionDowntime = d.readInt64()
+	p.JailDuration = d.readInt64()
+	p.UnbondingPeriod = d.readInt64()
+	p.RewardReductionInterval = d.readInt64()
+	p.RewardReduction = d.readInt64()
+	p.ProposerBonus = d.readInt64()
+	p.EpochLength = d.readInt64()
+	p.BlockTime = d.readInt64()
+	p.MaxClockDrift = d.readInt64()
+	p.MaxBlockBytes = d.readInt64()
+	p.MaxBlockTxs = d.readInt64()
+	p.MaxTxBytes = d.readInt64()
+	return d.finish()
+}
+
+// voteSigningBytes байти, які підписує валідатор, коли голосує
+func voteSigningBytes(chainID string, v *Vote) []byte {
+	e := newEncoder(tagVote)
+	e.writeString(chainID)
+	e.writeUint32(uint32(v.Type))
+	e.writeUint32(v.Height)
+	e.writeUint32(v.Round)
+	e.writeBytes(v.BlockHash)
+	return e.bytes()
+}
+
+// proposalSigningBytes байти, які підписує proposer, коли пропонує блок в раунді
+func proposalSigningBytes(chainID string, height uint32, round uint32, polRound int32, blockHash []byte) []byte {
+	e := newEncoder(tagProposal)
+	e.writeString(chainID)
+	e.writeUint32(height)
+	e.writeUint32(round)
+	e.writeInt64(int64(polRound))
+	e.writeBytes(blockHash)
+	return e.bytes()
+}
+
+// MarshalDeterministic канонічне представлення доказу. З нього рахуєтся hash доказу
+func (e *Evidence) MarshalDeterministic() []byte {
+	enc := newEncoder(tagEvidence)
+	enc.writeUint32(uint32(e.Type))
+	switch {
+	case e.Type == EvidenceDuplicateVote && e.VoteA != nil && e.VoteB != nil:
+		enc.writeBytes(e.VoteA.Pub)
+		enc.writeUint32(uint32(e.VoteA.Type))
+		enc.writeUint32(e.VoteA.Height)
+		enc.writeUint32(e.VoteA.Round)
+		enc.writeBytes(e.VoteA.BlockHash)
+		enc.writeBytes(e.VoteA.Signature)
+		enc.writeBytes(e.VoteB.BlockHash)
+		enc.writeBytes(e.VoteB.Signature)
+	case e.Type == EvidenceDuplicateBlock && e.HeaderA != nil && e.HeaderB != nil:
+		enc.writeBytes(e.HeaderA.BlockHeader.MarshalDeterministic())
+		enc.writeBytes(e.HeaderA.Signature)
+		enc.writeBytes(e.HeaderB.BlockHeader.MarshalDeterministic())
+		enc.writeBytes(e.HeaderB.Signature)
+	}
+	return enc.bytes()
+}
+
+// MarshalDeterministic канонічне представлення сертифікату. Поля голосів, крім pub і підпису,
+// збігаются з полями сертифікату (це перевіряє Verify), тому не записуются
+func (c *CommitCertificate) MarshalDeterministic() []byte {
+	e := newEncoder(tagCommit)
+	e.writeString(c.ChainID)
+	e.writeUint32(c.Height)
+	e.writeUint32(c.Round)
+	e.writeBytes(c.BlockHash)
+	e.writeUint32(uint32(len(c.Precommits)))
+	for i := range c.Precommits {
+		e.writeBytes(c.Precommits[i].Pub)
+		e.writeBytes(c.Precommits[i].Signature)
+	}
+	return e.bytes()
+}
+
+// MarshalBinary канонічне представлення пропусків валідатора. В такому вигляді вони зберігаются в стані
+func (l *LivenessInfo) MarshalBinary() ([]byte, error) {
+	e := newEncoder(tagLiveness)
+	e.writeBytes(l.Address)
+	e.writeBytes(l.MissedVotes)
+	e.writeUint32(l.MissedVotesCount)
+	e.writeUint32(uint32(len(l.MissedProposals)))
+	for _, h := range l.MissedProposals {
+		e.writeUint32(h)
+	}
+	return e.bytes(), nil
+}
+
+func (l *LivenessInfo) UnmarshalBinary(data []byte) error {
+	d := newDecoder(data, tagLiveness)
+	l.Address = d.readBytes()
+	l.MissedVotes = d.readBytes()
+	l.MissedVotesCount = d.readUint32()
+	n := d.readUint32()
+	l.MissedProposals = nil
+	for i := uint32(0); i < n && d.err == nil; i++ {
+		l.MissedProposals = append(l.MissedProposals, d.readUint32())
+	}
+	return d.finish()
+}
+
+// MarshalBinary канонічне представлення незавершених unbond. В такому вигляді вони зберігаются в стані
+func (u *Unbonding) MarshalBinary() ([]byte, error) {
+	e := newEncoder(tagUnbonding)
+	e.writeBytes(u.Delegator)
+	e.writeBytes(u.Validator)
+	e.writeUint32(uint32(len(u.Entries)))
+	for _, entry := range u.Entries {
+		e.writeInt64(entry.Amount)
+		e.writeUint32(entry.CreationHeight)
+		e.writeUint32(entry.CompletionHeight)
+	}
+	return e.bytes(), nil
+}
+
+func (u *Unbonding) UnmarshalBinary(data []byte) error {
+	d := newDecoder(data, tagUnbonding)
+	u.Delegator = d.readBytes()
+	u.Validator = d.readBytes()
+	n := d.readUint32()
+	u.Entries = nil
+	for i := uint32(0); i < n && d.err == nil; i++ {
+		u.Entries = append(u.Entries, UnbondingEntry{
+			Amount:           d.readInt64(),
+			CreationHeight:   d.readUint32(),
+			CompletionHeight: d.readUint32(),
+		})
+	}
+	return d.finish()
+}
+
+// MarshalBinary канонічне представлення делегації. В такому вигляді вона зберігаєтся в стані
+func (dl *Delegation) MarshalBinary() ([]byte, error) {
+	e := newEncoder(tagDelegation)
+	e.writeBytes(dl.Validator)
+	e.writeBytes(dl.Delegator)
+	e.writeInt64(dl.Shares)
+	return e.bytes(), nil
+}
+
+func (dl *Delegation) UnmarshalBinary(data []byte) error {
+	d := newDecoder(data, tagDelegation)
+	dl.Validator = d.readBytes()
+	dl.Delegator = d.readBytes()
+	dl.Shares = d.readInt64()
+	return d.finish()
+}
+
+// MarshalBinary канонічне представлення набору валідаторів. З нього рахуєтся ValidatorsHash
+func (s *ValidatorSet) MarshalBinary() ([]byte, error) {
+	e := newEncoder(tagValidatorSet)
+	e.writeUint32(uint32(len(s.Validators)))
+	for _, v := range s.Validators {
+		e.writeBytes(v.Address)
+		e.writeInt64(v.Amount)
+	}
+	return e.bytes(), nil
+}
+
+func (s *ValidatorSet) UnmarshalBinary(data []byte) error {
+	d := newDecoder(data, tagValidatorSet)
+	n := d.readUint32()
+	s.Validators = nil
+	for i := uint32(0); i < n && d.err == nil; i++ {
+		s.Validators = append(s.Validators, Validator{
+			Address: d.readBytes(),
+			Amount:  d.readInt64(),
+		})
+	}
+	return d.finish()
+}
+
+// MarshalBinary канонічне представлення RandaoCommit. В такому вигляді він зберігаєтся в стані
+func (c *RandaoCommit) MarshalBinary() ([]byte, error) {
+	e := newEncoder(tagRandaoCommit)
+	e.writeBytes(c.Address)
+	e.writeBytes(c.Commit)
+	e.writeUint32(c.Count)
+	return e.bytes(), nil
+}
+
+func (c *RandaoCommit) UnmarshalBinary(data []byte) error {
+	d := newDecoder(data, tagRandaoCommit)
+	c.Address = d.readBytes()
+	c.Commit = d.readBytes()
+	c.Count = d.readUint32()
+	return d.finish()
+}
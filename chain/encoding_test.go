@@ -0,0 +1,169 @@
+package chain
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/hex"
+	"testing"
+)
+
+// Тестові вектори з docs/encoding.md. Якщо тест падає після зміни формату, треба оновити і документ
+
+const testChainID = "PQlite_test"
+
+func testKey() (ed25519.PrivateKey, ed25519.PublicKey) {
+	seed := make([]byte, ed25519.SeedSize)
+	for i := range seed {
+		seed[i] = byte(i)
+	}
+	priv := ed25519.NewKeyFromSeed(seed)
+	return priv, priv.Public().(ed25519.PublicKey)
+}
+
+func checkHex(t *testing.T, name string, got []byte, want string) {
+	t.Helper()
+	if h := hex.EncodeToString(got); h != want {
+		t.Errorf("%s:\n  отримано:   %s\n  очікувалось: %s", name, h, want)
+	}
+}
+
+// testBlock транзакція, набір валідаторів і підписаний блок з документу
+func testBlock(t *testing.T) (*Block, *ValidatorSet) {
+	t.Helper()
+	priv, pub := testKey()
+
+	tx := Transaction{
+		ChainID:   testChainID,
+		Kind:      TxTransfer,
+		From:      pub,
+		To:        bytes.Repeat([]byte{0x11}, 32),
+		Amount:    150000000,
+		Fee:       1000,
+		Timestamp: 1700000000000,
+		Nonce:     1,
+	}
+	if err := tx.Sign(priv); err != nil {
+		t.Fatal(err)
+	}
+
+	set := &ValidatorSet{Validators: []Validator{{Address: pub, Amount: 100000000}}}
+	b := &Block{
+		BlockHeader: BlockHeader{
+			ChainID:        testChainID,
+			Height:         1,
+			Timestamp:      1700000001000,
+			PrevHash:       bytes.Repeat([]byte{0xaa}, 28),
+			ValidatorsHash: set.Hash(),
+			Proposer:       pub,
+			RandaoReveal:   bytes.Repeat([]byte{0xcc}, 32),
+			RandaoCommit:   bytes.Repeat([]byte{0xdd}, 32),
+			StateRoot:      bytes.Repeat([]byte{0xbb}, 32),
+		},
+		Transactions: []*Transaction{&tx},
+	}
+	b.TxRoot = b.ComputeTxRoot()
+	b.TxCount = uint32(len(b.Transactions))
+	b.EvidenceRoot = b.ComputeEvidenceRoot()
+	if err := b.Sign(priv); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.GenerateHash(); err != nil {
+		t.Fatal(err)
+	}
+	return b, set
+}
+
+func TestTransactionVector(t *testing.T) {
+	b, _ := testBlock(t)
+	tx := b.Transactions[0]
+
+	checkHex(t, "signing bytes", tx.SigningBytes(), "01010000000b50516c6974655f74657374000000000000002003a107bff3ce10be1d70dd18e74bc09967e4d6309ba50d5f1ddc8664125531b80000002011111111111111111111111111111111111111111111111111111111111111110000000008f0d18000000000000003e80000018bcfe5680000000001")
+	checkHex(t, "signature", tx.Signature, "68ae764f239bb9e3406ce091a04d2fbc0c24a19b1e746abfdf71d761303a141250b0edf8edfc9e9207861064b3e89f787cf2a89de083332d77a4293e0feb090e")
+	checkHex(t, "hash", tx.Hash(), "89bb509af6e90681fbe86681b6d1b6a0b9db1d1e00b4e4093249f79369e791b1")
+	if err := tx.Verify(testChainID); err != nil {
+		t.Errorf("підпис транзакції не пройшов перевірку: %v", err)
+	}
+}
+
+func TestValidatorSetVector(t *testing.T) {
+	_, set := testBlock(t)
+
+	data, err := set.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkHex(t, "set bytes", data, "010d000000010000002003a107bff3ce10be1d70dd18e74bc09967e4d6309ba50d5f1ddc8664125531b80000000005f5e100")
+	checkHex(t, "validators hash", set.Hash(), "b8b00855d59a1eb93dd59ae50cb9c3de2dd8ece152a4fe56bb8c81bf01004eda")
+
+	var decoded ValidatorSet
+	if err := decoded.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	checkHex(t, "validators hash після декодування", decoded.Hash(), "b8b00855d59a1eb93dd59ae50cb9c3de2dd8ece152a4fe56bb8c81bf01004eda")
+}
+
+func TestBlockHeaderVector(t *testing.T) {
+	b, _ := testBlock(t)
+
+	checkHex(t, "tx root", b.TxRoot, "28ca26e1c295ab922a1d007dbc149feb653aa868c6bade531298845bf63fe7d9")
+	checkHex(t, "evidence root", b.EvidenceRoot, "a7ffc6f8bf1ed76651c14756a061d662f580ff4de43b49fa82d80a4b80f8434a")
+	checkHex(t, "header bytes", b.BlockHeader.MarshalDeterministic(), "01020000000b50516c6974655f7465737400000001000000000000018bcfe56be80000001caaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa0000000000000020b8b00855d59a1eb93dd59ae50cb9c3de2dd8ece152a4fe56bb8c81bf01004eda0000002003a107bff3ce10be1d70dd18e74bc09967e4d6309ba50d5f1ddc8664125531b800000020cccccccccccccccccccccccccccccccccccccccccccccccccccccccccccccccc00000020dddddddddddddddddddddddddddddddddddddddddddddddddddddddddddddddd0000002028ca26e1c295ab922a1d007dbc149feb653aa868c6bade531298845bf63fe7d90000000100000020a7ffc6f8bf1ed76651c14756a061d662f580ff4de43b49fa82d80a4b80f8434a00000020bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb")
+	checkHex(t, "hash", b.Hash, "cf7e5005e938f291dff88aea00c054ad4638bb60eb104c4b0a332e08")
+	checkHex(t, "signature", b.Signature, "cf0d5732ad33b958d86c6f8952593215ca8fe06eed3b80d138a96223cbd9f91a59eb7d1bcb6dd9c140fbce88b3b0d59358a52bca46be3925afb322ccd9e91502")
+	if err := b.Verify(testChainID); err != nil {
+		t.Errorf("блок не пройшов перевірку: %v", err)
+	}
+}
+
+// testVotes precommit за блок з документу і precommit за nil того самого валідатора
+func testVotes(t *testing.T) (*Vote, *Vote) {
+	t.Helper()
+	priv, pub := testKey()
+	b, _ := testBlock(t)
+
+	v := &Vote{Type: VotePrecommit, Height: 1, Round: 0, BlockHash: b.Hash, Pub: pub}
+	if err := v.Sign(testChainID, priv); err != nil {
+		t.Fatal(err)
+	}
+	nv := &Vote{Type: VotePrecommit, Height: 1, Round: 0, Pub: pub}
+	if err := nv.Sign(testChainID, priv); err != nil {
+		t.Fatal(err)
+	}
+	return v, nv
+}
+
+func TestVoteVector(t *testing.T) {
+	v, _ := testVotes(t)
+
+	checkHex(t, "signing bytes", voteSigningBytes(testChainID, v), "01030000000b50516c6974655f746573740000000200000001000000000000001ccf7e5005e938f291dff88aea00c054ad4638bb60eb104c4b0a332e08")
+	checkHex(t, "signature", v.Signature, "f240411d0e00f05b35012f06312e50526ae836e34826294d9ccd24c5daf6715ec0d5adeadccd5adc2415cf6cbc331cf979d484c7d029ad271bbf3086fa22f204")
+}
+
+func TestNilVoteVector(t *testing.T) {
+	_, nv := testVotes(t)
+
+	checkHex(t, "signature", nv.Signature, "b14fc68867881719f094dafdb2e578e434d78b9802f7a0001db197ddd152a04339d232e5838d46ad8080b88856b825ff11f6654a36a4f9684989809f1521ba01")
+}
+
+func TestEvidenceVector(t *testing.T) {
+	v, nv := testVotes(t)
+
+	e := NewVoteEvidence(v, nv)
+	if err := e.Verify(testChainID); err != nil {
+		t.Fatalf("доказ не пройшов перевірку: %v", err)
+	}
+	checkHex(t, "evidence bytes", e.MarshalDeterministic(), "0108000000010000002003a107bff3ce10be1d70dd18e74bc09967e4d6309ba50d5f1ddc8664125531b80000000200000001000000000000000000000040b14fc68867881719f094dafdb2e578e434d78b9802f7a0001db197ddd152a04339d232e5838d46ad8080b88856b825ff11f6654a36a4f9684989809f1521ba010000001ccf7e5005e938f291dff88aea00c054ad4638bb60eb104c4b0a332e0800000040f240411d0e00f05b35012f06312e50526ae836e34826294d9ccd24c5daf6715ec0d5adeadccd5adc2415cf6cbc331cf979d484c7d029ad271bbf3086fa22f204")
+	checkHex(t, "hash", e.Hash(), "4e2455aec638beee553a9f07b339d180c0675323ed612c096017aeed212e9c89")
+}
+
+func TestProposalVector(t *testing.T) {
+	priv, pub := testKey()
+	b, _ := testBlock(t)
+
+	p := Proposal{Round: 2, POLRound: -1, Block: *b, Pub: pub}
+	if err := p.Sign(priv); err != nil {
+		t.Fatal(err)
+	}
+	checkHex(t, "signing bytes", proposalSigningBytes(testChainID, b.Height, p.Round, p.POLRound, b.Hash), "01070000000b50516c6974655f746573740000000100000002ffffffffffffffff0000001ccf7e5005e938f291dff88aea00c054ad4638bb60eb104c4b0a332e08")
+	checkHex(t, "signature", p.Signature, "e8eace2d158b7927ccc44748f149b4757b5ebcdd085cdfcc9f9d4e0df9b729d96d53970d1485fdcd62e766de85ec7783f1256917e2752c63dea2d44ef1ec6a03")
+}
@@ -0,0 +1,228 @@
+package chain
+
+import (
+	"bytes"
+	"crypto/sha3"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/PQlite/crypto"
+)
+
+type EvidenceType uint32
+
+const (
+	EvidenceDuplicateVote  EvidenceType = 1 // два різні голоси одного типу в одному раунді
+	EvidenceDuplicateBlock EvidenceType = 2 // два різні блоки від proposer в одному раунді
+)
+
+func (t EvidenceType) String() string {
+	switch t {
+	case EvidenceDuplicateVote:
+		return "duplicate_vote"
+	case EvidenceDuplicateBlock:
+		return "duplicate_block"
+	}
+	return fmt.Sprintf("EvidenceType(%d)", uint32(t))
+}
+
+// SignedHeader заголовок блоку разом з підписом proposer. Для доказу транзакції блоку не потрібні
+type SignedHeader struct {
+	BlockHeader
+	Signature []byte
+}
+
+// Evidence доказ того, що валідатор підписав дві суперечливі речі на одній висоті:
+// два голоси одного типу в одному раунді за різні блоки, або два різні блоки в одному раунді.
+// В різних раундах валідатор може чесно голосувати по-різному, тому раунд теж має збігатись.
+// Пара зберігаєтся впорядкованою (A < B), щоб той самий доказ мав один hash
+type Evidence struct {
+	Type    EvidenceType  `json:"type"`
+	VoteA   *Vote         `json:"vote_a,omitempty"`
+	VoteB   *Vote         `json:"vote_b,omitempty"`
+	HeaderA *SignedHeader `json:"header_a,omitempty"`
+	HeaderB *SignedHeader `json:"header_b,omitempty"`
+}
+
+// NewVoteEvidence створює доказ з двох голосів. Перевіряє його Verify
+func NewVoteEvidence(a *Vote, b *Vote) *Evidence {
+	if bytes.Compare(a.BlockHash, b.BlockHash) > 0 {
+		a, b = b, a
+	}
+	return &Evidence{Type: EvidenceDuplicateVote, VoteA: a, VoteB: b}
+}
+
+// NewBlockEvidence створює доказ з двох блоків. Перевіряє його Verify
+func NewBlockEvidence(a *Block, b *Block) *Evidence {
+	if bytes.Compare(a.Hash, b.Hash) > 0 {
+		a, b = b, a
+	}
+	return &Evidence{
+		Type:    EvidenceDuplicateBlock,
+		HeaderA: &SignedHeader{BlockHeader: a.BlockHeader, Signature: a.Signature},
+		HeaderB: &SignedHeader{BlockHeader: b.BlockHeader, Signature: b.Signature},
+	}
+}
+
+// Offender адреса валідатора, якого доводить доказ
+func (e *Evidence) Offender() []byte {
+	switch e.Type {
+	case EvidenceDuplicateVote:
+		if e.VoteA != nil {
+			return e.VoteA.Pub
+		}
+	case EvidenceDuplicateBlock:
+		if e.HeaderA != nil {
+			return e.HeaderA.Proposer
+		}
+	}
+	return nil
+}
+
+// Height висота, на якій валідатор підписав суперечливі речі
+func (e *Evidence) Height() uint32 {
+	switch e.Type {
+	case EvidenceDuplicateVote:
+		if e.VoteA != nil {
+			return e.VoteA.Height
+		}
+	case EvidenceDuplicateBlock:
+		if e.HeaderA != nil {
+			return e.HeaderA.Height
+		}
+	}
+	return 0
+}
+
+// Hash sha3-256 канонічного кодування доказу
+func (e *Evidence) Hash() []byte {
+	h := sha3.Sum256(e.MarshalDeterministic())
+	return h[:]
+}
+
+// Verify перевіряє, що обидві частини доказу підписані одним ключем, для однієї висоти і раунду,
+// і справді суперечать одна одній. Чи є підписант валідатором, перевіряєтся при виконанні блоку
+func (e *Evidence) Verify(chainID string) error {
+	switch e.Type {
+	case EvidenceDuplicateVote:
+		return e.verifyDuplicateVote(chainID)
+	case EvidenceDuplicateBlock:
+		return e.verifyDuplicateBlock(chainID)
+	}
+	return fmt.Errorf("невідомий тип доказу: %d", e.Type)
+}
+
+func (e *Evidence) verifyDuplicateVote(chainID string) error {
+	a, b := e.VoteA, e.VoteB
+	if a == nil || b == nil || e.HeaderA != nil || e.HeaderB != nil {
+		return errors.New("доказ подвійного голосу має містити тільки два голоси")
+	}
+	if !bytes.Equal(a.Pub, b.Pub) {
+		return errors.New("голоси доказу від різних валідаторів")
+	}
+	if a.Type != b.Type || a.Height != b.Height || a.Round != b.Round {
+		return errors.New("голоси доказу для різних кроків консенсусу")
+	}
+	if bytes.Compare(a.BlockHash, b.BlockHash) >= 0 {
+		return errors.New("голоси доказу не суперечать один одному або не впорядковані")
+	}
+	if err := a.Verify(chainID); err != nil {
+		return fmt.Errorf("не правельний підпис першого голосу: %w", err)
+	}
+	if err := b.Verify(chainID); err != nil {
+		return fmt.Errorf("не правельний підпис другого голосу: %w", err)
+	}
+	return nil
+}
+
+func (e *Evidence) verifyDuplicateBlock(chainID string) error {
+	a, b := e.HeaderA, e.HeaderB
+	if a == nil || b == nil || e.VoteA != nil || e.VoteB != nil {
+		return errors.New("доказ подвійного блоку має містити тільки два заголовки")
+	}
+	if a.ChainID != chainID || b.ChainID != chainID {
+		return errors.New("блоки доказу для іншої мережі")
+	}
+	if !bytes.Equal(a.Proposer, b.Proposer) {
+		return errors.New("блоки доказу від різних proposer")
+	}
+	if a.Height != b.Height || a.Round != b.Round {
+		return errors.New("блоки доказу для різних висот або раундів")
+	}
+
+	aBytes := a.BlockHeader.MarshalDeterministic()
+	bBytes := b.BlockHeader.MarshalDeterministic()
+	aHash, bHash := sha3.Sum224(aBytes), sha3.Sum224(bBytes)
+	if bytes.Compare(aHash[:], bHash[:]) >= 0 {
+		return errors.New("блоки доказу не суперечать один одному або не впорядковані")
+	}
+	if err := crypto.Verify(a.Proposer, aBytes, a.Signature); err != nil {
+		return fmt.Errorf("не правельний підпис першого блоку: %w", err)
+	}
+	if err := crypto.Verify(b.Proposer, bBytes, b.Signature); err != nil {
+		return fmt.Errorf("не правельний підпис другого блоку: %w", err)
+	}
+	return nil
+}
+
+// ComputeEvidenceRoot рахує Merkle root доказів блоку
+func (b *Block) ComputeEvidenceRoot() []byte {
+	hashes := make([][]byte, len(b.Evidence))
+	for i, e := range b.Evidence {
+		hashes[i] = e.Hash()
+	}
+	return MerkleRoot(hashes)
+}
+
+// EvidencePool докази, які ще не потрапили в блок. Як і mempool, приймає тільки перевірені докази
+type EvidencePool struct {
+	mu       sync.Mutex
+	ChainID  string
+	Evidence []*Evidence
+}
+
+// Add додає доказ і повертає false, якщо такий доказ вже є
+func (p *EvidencePool) Add(e *Evidence) (bool, error) {
+	if err := e.Verify(p.ChainID); err != nil {
+		return false, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	hash := e.Hash()
+	for _, local := range p.Evidence {
+		if bytes.Equal(local.Hash(), hash) {
+			return false, nil
+		}
+	}
+	p.Evidence = append(p.Evidence, e)
+	return true, nil
+}
+
+// Snapshot копія списку доказів, яку можна читати без блокування
+func (p *EvidencePool) Snapshot() []*Evidence {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	evidence := make([]*Evidence, len(p.Evidence))
+	copy(evidence, p.Evidence)
+	return evidence
+}
+
+// Remove видаляє докази, які вже в блоці або більше не можуть туди потрапити
+func (p *EvidencePool) Remove(evidence []*Evidence) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, e := range evidence {
+		hash := e.Hash()
+		for i, local := range p.Evidence {
+			if bytes.Equal(local.Hash(), hash) {
+				p.Evidence = append(p.Evidence[:i], p.Evidence[i+1:]...)
+				break
+			}
+		}
+	}
+}
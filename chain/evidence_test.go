@@ -0,0 +1,181 @@
+package chain
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"testing"
+)
+
+func testOtherKey() (ed25519.PrivateKey, ed25519.PublicKey) {
+	priv := ed25519.NewKeyFromSeed(bytes.Repeat([]byte{0x42}, ed25519.SeedSize))
+	return priv, priv.Public().(ed25519.PublicKey)
+}
+
+func testVote(t *testing.T, priv ed25519.PrivateKey, voteType VoteType, round uint32, hash byte) *Vote {
+	t.Helper()
+	v := &Vote{Type: voteType, Height: 10, Round: round, Pub: priv.Public().(ed25519.PublicKey)}
+	if hash != 0 {
+		v.BlockHash = bytes.Repeat([]byte{hash}, 28)
+	}
+	if err := v.Sign(testChainID, priv); err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func testSignedBlock(t *testing.T, priv ed25519.PrivateKey, height uint32, round uint32, timestamp int64) *Block {
+	t.Helper()
+	b := &Block{BlockHeader: BlockHeader{
+		ChainID:   testChainID,
+		Height:    height,
+		Round:     round,
+		Timestamp: timestamp,
+		Proposer:  priv.Public().(ed25519.PublicKey),
+	}}
+	if err := b.GenerateHash(); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Sign(priv); err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestDuplicateVoteEvidenceVerify(t *testing.T) {
+	priv, _ := testKey()
+	otherPriv, _ := testOtherKey()
+
+	tests := []struct {
+		name    string
+		e       func() *Evidence
+		wantErr bool
+	}{
+		{"два блоки", func() *Evidence {
+			return NewVoteEvidence(testVote(t, priv, VotePrevote, 0, 0xaa), testVote(t, priv, VotePrevote, 0, 0xbb))
+		}, false},
+		{"блок і nil", func() *Evidence {
+			return NewVoteEvidence(testVote(t, priv, VotePrecommit, 1, 0xaa), testVote(t, priv, VotePrecommit, 1, 0))
+		}, false},
+		{"той самий голос", func() *Evidence {
+			return NewVoteEvidence(testVote(t, priv, VotePrevote, 0, 0xaa), testVote(t, priv, VotePrevote, 0, 0xaa))
+		}, true},
+		{"різні раунди", func() *Evidence {
+			return NewVoteEvidence(testVote(t, priv, VotePrevote, 0, 0xaa), testVote(t, priv, VotePrevote, 1, 0xbb))
+		}, true},
+		{"різні типи", func() *Evidence {
+			return NewVoteEvidence(testVote(t, priv, VotePrevote, 0, 0xaa), testVote(t, priv, VotePrecommit, 0, 0xbb))
+		}, true},
+		{"різні валідатори", func() *Evidence {
+			return NewVoteEvidence(testVote(t, priv, VotePrevote, 0, 0xaa), testVote(t, otherPriv, VotePrevote, 0, 0xbb))
+		}, true},
+		{"не впорядковані", func() *Evidence {
+			e := NewVoteEvidence(testVote(t, priv, VotePrevote, 0, 0xaa), testVote(t, priv, VotePrevote, 0, 0xbb))
+			e.VoteA, e.VoteB = e.VoteB, e.VoteA
+			return e
+		}, true},
+		{"підроблений підпис", func() *Evidence {
+			e := NewVoteEvidence(testVote(t, priv, VotePrevote, 0, 0xaa), testVote(t, priv, VotePrevote, 0, 0xbb))
+			e.VoteB.BlockHash = bytes.Repeat([]byte{0xcc}, 28)
+			return e
+		}, true},
+		{"підпис іншим ключем", func() *Evidence {
+			b := testVote(t, otherPriv, VotePrevote, 0, 0xbb)
+			b.Pub = priv.Public().(ed25519.PublicKey)
+			return NewVoteEvidence(testVote(t, priv, VotePrevote, 0, 0xaa), b)
+		}, true},
+		{"тільки один голос", func() *Evidence {
+			return &Evidence{Type: EvidenceDuplicateVote, VoteA: testVote(t, priv, VotePrevote, 0, 0xaa)}
+		}, true},
+		{"голоси і заголовок", func() *Evidence {
+			e := NewVoteEvidence(testVote(t, priv, VotePrevote, 0, 0xaa), testVote(t, priv, VotePrevote, 0, 0xbb))
+			b := testSignedBlock(t, priv, 10, 0, 1)
+			e.HeaderA = &SignedHeader{BlockHeader: b.BlockHeader, Signature: b.Signature}
+			return e
+		}, true},
+		{"невідомий тип", func() *Evidence {
+			e := NewVoteEvidence(testVote(t, priv, VotePrevote, 0, 0xaa), testVote(t, priv, VotePrevote, 0, 0xbb))
+			e.Type = 3
+			return e
+		}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.e().Verify(testChainID); (err != nil) != tt.wantErr {
+				t.Errorf("помилка %v, очікувалась помилка: %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	e := NewVoteEvidence(testVote(t, priv, VotePrevote, 0, 0xaa), testVote(t, priv, VotePrevote, 0, 0xbb))
+	if e.Verify("other") == nil {
+		t.Error("доказ з голосів іншої мережі пройшов перевірку")
+	}
+}
+
+func TestDuplicateBlockEvidenceVerify(t *testing.T) {
+	priv, _ := testKey()
+	otherPriv, _ := testOtherKey()
+
+	tests := []struct {
+		name    string
+		e       func() *Evidence
+		wantErr bool
+	}{
+		{"два блоки в раунді", func() *Evidence {
+			return NewBlockEvidence(testSignedBlock(t, priv, 10, 0, 1), testSignedBlock(t, priv, 10, 0, 2))
+		}, false},
+		{"той самий блок", func() *Evidence {
+			return NewBlockEvidence(testSignedBlock(t, priv, 10, 0, 1), testSignedBlock(t, priv, 10, 0, 1))
+		}, true},
+		{"різні раунди", func() *Evidence {
+			return NewBlockEvidence(testSignedBlock(t, priv, 10, 0, 1), testSignedBlock(t, priv, 10, 1, 2))
+		}, true},
+		{"різні висоти", func() *Evidence {
+			return NewBlockEvidence(testSignedBlock(t, priv, 10, 0, 1), testSignedBlock(t, priv, 11, 0, 2))
+		}, true},
+		{"різні proposer", func() *Evidence {
+			return NewBlockEvidence(testSignedBlock(t, priv, 10, 0, 1), testSignedBlock(t, otherPriv, 10, 0, 2))
+		}, true},
+		{"не впорядковані", func() *Evidence {
+			e := NewBlockEvidence(testSignedBlock(t, priv, 10, 0, 1), testSignedBlock(t, priv, 10, 0, 2))
+			e.HeaderA, e.HeaderB = e.HeaderB, e.HeaderA
+			return e
+		}, true},
+		{"підроблений заголовок", func() *Evidence {
+			e := NewBlockEvidence(testSignedBlock(t, priv, 10, 0, 1), testSignedBlock(t, priv, 10, 0, 2))
+			e.HeaderB.Timestamp = 3
+			return e
+		}, true},
+		{"інша мережа", func() *Evidence {
+			a, b := testSignedBlock(t, priv, 10, 0, 1), testSignedBlock(t, priv, 10, 0, 2)
+			a.ChainID, b.ChainID = "other", "other"
+			return NewBlockEvidence(a, b)
+		}, true},
+		{"тільки один заголовок", func() *Evidence {
+			e := NewBlockEvidence(testSignedBlock(t, priv, 10, 0, 1), testSignedBlock(t, priv, 10, 0, 2))
+			e.HeaderB = nil
+			return e
+		}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.e().Verify(testChainID); (err != nil) != tt.wantErr {
+				t.Errorf("помилка %v, очікувалась помилка: %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// Той самий доказ, з якого б порядку його не зібрали, має один hash
+func TestEvidenceHashOrder(t *testing.T) {
+	priv, _ := testKey()
+	a, b := testVote(t, priv, VotePrevote, 0, 0xaa), testVote(t, priv, VotePrevote, 0, 0xbb)
+	if !bytes.Equal(NewVoteEvidence(a, b).Hash(), NewVoteEvidence(b, a).Hash()) {
+		t.Error("hash доказу з голосів залежить від порядку")
+	}
+
+	x, y := testSignedBlock(t, priv, 10, 0, 1), testSignedBlock(t, priv, 10, 0, 2)
+	if !bytes.Equal(NewBlockEvidence(x, y).Hash(), NewBlockEvidence(y, x).Hash()) {
+		t.Error("hash доказу з блоків залежить від порядку")
+	}
+}
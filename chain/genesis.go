@@ -0,0 +1,180 @@
+package chain
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// defaultGenesis genesis тестової мережі, використовуєтся, якщо файл genesis не вказано
+//
+//go:embed genesis_testnet.json
+var defaultGenesis []byte
+
+// maxChainIDLen обмеження довжини chain id, він входить в кожну транзакцію
+const maxChainIDLen = 64
+
+type GenesisAccount struct {
+	Address []byte `json:"address"` // base64
+	Balance int64  `json:"balance"` // в копійках
+}
+
+type GenesisValidator struct {
+	Address    []byte `json:"address"`    // base64, публічний ключ валідатора
+	Stake      int64  `json:"stake"`      // в копійках, власний stake валідатора
+	Commission int64  `json:"commission"` // з CommissionDenominator
+}
+
+// Genesis опис початкового стану мережі. Всі ноди з однаковим genesis отримуют однаковий genesis блок
+type Genesis struct {
+	ChainID     string             `json:"chain_id"`
+	GenesisTime int64              `json:"genesis_time"` // UNIX час в мілісекундах
+	Accounts    []GenesisAccount   `json:"accounts"`
+	Validators  []GenesisValidator `json:"validators"`
+	Params      ConsensusParams    `json:"consensus_params"`
+}
+
+// LoadGenesis читає і перевіряє genesis з файлу. Якщо path порожній, повертає genesis тестової мережі
+func LoadGenesis(path string) (*Genesis, error) {
+	if path == "" {
+		return ParseGenesis(defaultGenesis)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("помилка читання genesis файлу: %w", err)
+	}
+	return ParseGenesis(data)
+}
+
+func ParseGenesis(data []byte) (*Genesis, error) {
+	var g Genesis
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&g); err != nil {
+		return nil, fmt.Errorf("помилка розпаковки genesis: %w", err)
+	}
+
+	if err := g.Validate(); err != nil {
+		return nil, err
+	}
+	return &g, nil
+}
+
+func (g *Genesis) Validate() error {
+	if g.ChainID == "" || len(g.ChainID) > maxChainIDLen {
+		return fmt.Errorf("chain_id має бути від 1 до %d символів", maxChainIDLen)
+	}
+	if g.GenesisTime < 0 {
+		return fmt.Errorf("genesis_time не може бути від'ємним")
+	}
+	if len(g.Validators) == 0 {
+		return fmt.Errorf("genesis має мати хоча б одного валідатора")
+	}
+
+	var supply int64
+	seen := make(map[string]bool)
+	for _, acc := range g.Accounts {
+		if len(acc.Address) == 0 {
+			return fmt.Errorf("рахунок в genesis має порожню адресу")
+		}
+		if seen[string(acc.Address)] {
+			return fmt.Errorf("рахунок %x повторюєтся в genesis", acc.Address)
+		}
+		seen[string(acc.Address)] = true
+
+		if acc.Balance <= 0 {
+			return fmt.Errorf("рахунок %x має не додатній баланс", acc.Address)
+		}
+		var err error
+		if supply, err = AddAmount(supply, acc.Balance); err != nil {
+			return fmt.Errorf("сума балансів в genesis: %w", err)
+		}
+	}
+
+	seen = make(map[string]bool)
+	for _, v := range g.Validators {
+		if len(v.Address) != ed25519.PublicKeySize {
+			return fmt.Errorf("адреса валідатора %x має бути публічним ключем ed25519", v.Address)
+		}
+		if seen[string(v.Address)] {
+			return fmt.Errorf("валідатор %x повторюєтся в genesis", v.Address)
+		}
+		seen[string(v.Address)] = true
+
+		if v.Stake <= 0 {
+			return fmt.Errorf("валідатор %x має не додатній stake", v.Address)
+		}
+		if v.Commission < 0 || v.Commission > CommissionDenominator {
+			return fmt.Errorf("комісія валідатора %x має бути від 0 до %d", v.Address, CommissionDenominator)
+		}
+		var err error
+		if supply, err = AddAmount(supply, v.Stake); err != nil {
+			return fmt.Errorf("сума балансів і stake в genesis: %w", err)
+		}
+	}
+
+	return g.Params.Validate()
+}
+
+// Wallets гаманці, з яких починаєтся мережа
+func (g *Genesis) Wallets() []Wallet {
+	wallets := make([]Wallet, 0, len(g.Accounts))
+	for _, acc := range g.Accounts {
+		wallets = append(wallets, Wallet{
+			Address: acc.Address,
+			Balance: acc.Balance,
+		})
+	}
+	return wallets
+}
+
+// ValidatorSet валідатори, з яких починаєтся мережа
+func (g *Genesis) ValidatorSet() []Validator {
+	validators := make([]Validator, 0, len(g.Validators))
+	for _, v := range g.Validators {
+		validators = append(validators, Validator{
+			Address:         v.Address,
+			Amount:          v.Stake,
+			DelegatorShares: v.Stake,
+			Commission:      v.Commission,
+		})
+	}
+	return validators
+}
+
+// Delegations власні делегації валідаторів з ValidatorSet: весь stake в genesis належить самим валідаторам
+func (g *Genesis) Delegations() []Delegation {
+	delegations := make([]Delegation, 0, len(g.Validators))
+	for _, v := range g.Validators {
+		delegations = append(delegations, Delegation{
+			Validator: v.Address,
+			Delegator: v.Address,
+			Shares:    v.Stake,
+		})
+	}
+	return delegations
+}
+
+// Block створює genesis блок. stateRoot це корінь стану після запису Wallets, ValidatorSet, Delegations і Params
+func (g *Genesis) Block(stateRoot []byte) (*Block, error) {
+	b := &Block{
+		BlockHeader: BlockHeader{
+			ChainID:   g.ChainID,
+			Height:    0,
+			Timestamp: g.GenesisTime,
+			StateRoot: stateRoot,
+		},
+	}
+	b.TxRoot = b.ComputeTxRoot()
+	b.EvidenceRoot = b.ComputeEvidenceRoot()
+
+	if err := b.GenerateHash(); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
@@ -0,0 +1,96 @@
+package chain
+
+import (
+	"bytes"
+	"math"
+	"strings"
+	"testing"
+)
+
+// testGenesis genesis тестової мережі, який кожен тест може змінювати
+func testGenesis(t *testing.T) *Genesis {
+	t.Helper()
+	g, err := LoadGenesis("")
+	if err != nil {
+		t.Fatalf("genesis тестової мережі не валідний: %v", err)
+	}
+	return g
+}
+
+func TestGenesisValidate(t *testing.T) {
+	_, pub := testKey()
+	_, otherPub := testOtherKey()
+
+	tests := []struct {
+		name    string
+		modify  func(g *Genesis)
+		wantErr string
+	}{
+		{"тестова мережа", func(g *Genesis) {}, ""},
+		{"без рахунків", func(g *Genesis) { g.Accounts = nil }, ""},
+		{"два валідатори", func(g *Genesis) {
+			g.Validators = append(g.Validators, GenesisValidator{Address: otherPub, Stake: 1, Commission: CommissionDenominator})
+		}, ""},
+		{"chain_id на межі", func(g *Genesis) { g.ChainID = strings.Repeat("a", maxChainIDLen) }, ""},
+		{"порожній chain_id", func(g *Genesis) { g.ChainID = "" }, "chain_id"},
+		{"задовгий chain_id", func(g *Genesis) { g.ChainID = strings.Repeat("a", maxChainIDLen+1) }, "chain_id"},
+		{"від'ємний genesis_time", func(g *Genesis) { g.GenesisTime = -1 }, "genesis_time"},
+		{"без валідаторів", func(g *Genesis) { g.Validators = nil }, "валідатора"},
+		{"порожня адреса рахунку", func(g *Genesis) { g.Accounts[0].Address = nil }, "порожню адресу"},
+		{"рахунок повторюєтся", func(g *Genesis) { g.Accounts = append(g.Accounts, g.Accounts[0]) }, "повторюєтся"},
+		{"нульовий баланс", func(g *Genesis) { g.Accounts[0].Balance = 0 }, "баланс"},
+		{"від'ємний баланс", func(g *Genesis) { g.Accounts[0].Balance = -1 }, "баланс"},
+		{"переповнення балансів", func(g *Genesis) {
+			g.Accounts = append(g.Accounts, GenesisAccount{Address: otherPub, Balance: math.MaxInt64})
+		}, "сума балансів"},
+		{"переповнення з stake", func(g *Genesis) { g.Validators[0].Stake = math.MaxInt64 }, "сума балансів і stake"},
+		{"коротка адреса валідатора", func(g *Genesis) { g.Validators[0].Address = pub[:31] }, "ed25519"},
+		{"валідатор повторюєтся", func(g *Genesis) { g.Validators = append(g.Validators, g.Validators[0]) }, "повторюєтся"},
+		{"нульовий stake", func(g *Genesis) { g.Validators[0].Stake = 0 }, "stake"},
+		{"від'ємна комісія", func(g *Genesis) { g.Validators[0].Commission = -1 }, "комісія"},
+		{"завелика комісія", func(g *Genesis) { g.Validators[0].Commission = CommissionDenominator + 1 }, "комісія"},
+		{"не валідні параметри", func(g *Genesis) { g.Params.EpochLength = 0 }, "epoch_length"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := testGenesis(t)
+			tt.modify(g)
+			err := g.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("неочікувана помилка: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("помилка %v, очікувалась помилка з %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseGenesis(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		wantErr bool
+	}{
+		{"тестова мережа", string(defaultGenesis), false},
+		{"невідоме поле", strings.Replace(string(defaultGenesis), `"genesis_time"`, `"unknown": 1, "genesis_time"`, 1), true},
+		{"невідомий параметр", strings.Replace(string(defaultGenesis), `"block_reward"`, `"block_rewards": 1, "block_reward"`, 1), true},
+		{"не json", "genesis", true},
+		{"не валідний genesis", strings.Replace(string(defaultGenesis), `"PQlite_test"`, `""`, 1), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseGenesis([]byte(tt.data)); (err != nil) != tt.wantErr {
+				t.Errorf("помилка %v, очікувалась помилка: %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	g := testGenesis(t)
+	if g.ChainID != testChainID || len(g.Validators) != 1 || !bytes.Equal(g.Validators[0].Address, g.Accounts[0].Address) {
+		t.Errorf("genesis тестової мережі розпаковано не правельно: %+v", g)
+	}
+}
@@ -0,0 +1,67 @@
+package chain
+
+// LivenessInfo пропуски валідатора за останні SignedBlocksWindow висот. Зберігаєтся в стані,
+// тому всі ноди однаково вирішують, коли валідатора треба покарати
+type LivenessInfo struct {
+	Address          []byte
+	MissedVotes      []byte   // бітова мапа: біт height % window - чи немає його precommit в LastCommit цієї висоти
+	MissedVotesCount uint32   // кількість встановлених бітів в MissedVotes
+	MissedProposals  []uint32 // висоти у вікні, на яких валідатор мав створити блок, але не створив
+}
+
+func NewLivenessInfo(address []byte, window int64) *LivenessInfo {
+	return &LivenessInfo{
+		Address:     address,
+		MissedVotes: make([]byte, (window+7)/8),
+	}
+}
+
+// MarkVote записує, чи пропустив валідатор голос на висоті height. Біт висоти height-window
+// при цьому перезаписуєтся, тому рахуются тільки останні window висот
+func (l *LivenessInfo) MarkVote(height uint32, missed bool, window int64) {
+	if int64(len(l.MissedVotes)) != (window+7)/8 {
+		l.Reset(window)
+	}
+
+	i := int64(height) % window
+	mask := byte(1) << (i % 8)
+	was := l.MissedVotes[i/8]&mask != 0
+
+	switch {
+	case missed && !was:
+		l.MissedVotes[i/8] |= mask
+		l.MissedVotesCount++
+	case !missed && was:
+		l.MissedVotes[i/8] &^= mask
+		l.MissedVotesCount--
+	}
+}
+
+// AddMissedProposal записує пропущений блок на висоті height і забуває пропуски, старші за вікно
+func (l *LivenessInfo) AddMissedProposal(height uint32, window int64) {
+	kept := l.MissedProposals[:0]
+	for _, h := range l.MissedProposals {
+		if int64(height-h) < window {
+			kept = append(kept, h)
+		}
+	}
+	l.MissedProposals = append(kept, height)
+}
+
+// MissedProposalsCount кількість пропущених блоків у вікні, яке закінчуєтся на висоті height
+func (l *LivenessInfo) MissedProposalsCount(height uint32, window int64) int64 {
+	var count int64
+	for _, h := range l.MissedProposals {
+		if h <= height && int64(height-h) < window {
+			count++
+		}
+	}
+	return count
+}
+
+// Reset забуває всі пропуски. Так валідатор починає з нуля після покарання або unjail
+func (l *LivenessInfo) Reset(window int64) {
+	l.MissedVotes = make([]byte, (window+7)/8)
+	l.MissedVotesCount = 0
+	l.MissedProposals = nil
+}
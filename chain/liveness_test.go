@@ -0,0 +1,105 @@
+package chain
+
+import (
+	"math/bits"
+	"slices"
+	"testing"
+)
+
+func TestLivenessMarkVote(t *testing.T) {
+	type mark struct {
+		height uint32
+		missed bool
+	}
+	tests := []struct {
+		name      string
+		window    int64
+		marks     []mark
+		wantCount uint32
+	}{
+		{"без пропусків", 10, []mark{{1, false}, {2, false}}, 0},
+		{"пропуски", 10, []mark{{1, true}, {2, false}, {3, true}}, 2},
+		{"та сама висота двічі", 10, []mark{{1, true}, {1, true}}, 1},
+		{"пропуск виправлено", 10, []mark{{1, true}, {1, false}}, 0},
+		{"все вікно", 10, []mark{{1, true}, {2, true}, {3, true}, {4, true}, {5, true}, {6, true}, {7, true}, {8, true}, {9, true}, {10, true}}, 10},
+		{"висота за вікном перезаписує старий біт", 10, []mark{{3, true}, {4, true}, {13, false}}, 1},
+		{"пропуск за вікном замість старого", 10, []mark{{3, true}, {13, true}}, 1},
+		{"вікно не кратне 8", 9, []mark{{0, true}, {8, true}, {17, false}}, 1},
+		{"вікно з одного блоку", 1, []mark{{5, true}, {6, false}, {7, true}}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := NewLivenessInfo([]byte("validator"), tt.window)
+			for _, m := range tt.marks {
+				l.MarkVote(m.height, m.missed, tt.window)
+			}
+			if l.MissedVotesCount != tt.wantCount {
+				t.Errorf("MissedVotesCount %d, очікувалось %d", l.MissedVotesCount, tt.wantCount)
+			}
+
+			var set int
+			for _, b := range l.MissedVotes {
+				set += bits.OnesCount8(b)
+			}
+			if int64(len(l.MissedVotes)) != (tt.window+7)/8 || uint32(set) != l.MissedVotesCount {
+				t.Errorf("бітова мапа %08b не відповідає вікну %d і лічильнику %d", l.MissedVotes, tt.window, l.MissedVotesCount)
+			}
+		})
+	}
+}
+
+// Після зміни signed_blocks_window пропуски рахуются з нуля
+func TestLivenessMarkVoteWindowChange(t *testing.T) {
+	l := NewLivenessInfo([]byte("validator"), 10)
+	l.MarkVote(1, true, 10)
+	l.MarkVote(2, true, 10)
+
+	l.MarkVote(3, true, 20)
+	if l.MissedVotesCount != 1 || len(l.MissedVotes) != 3 {
+		t.Errorf("після зміни вікна: %d пропусків, мапа %d байт", l.MissedVotesCount, len(l.MissedVotes))
+	}
+}
+
+func TestLivenessMissedProposals(t *testing.T) {
+	tests := []struct {
+		name      string
+		window    int64
+		heights   []uint32
+		at        uint32
+		wantCount int64
+		wantKept  []uint32
+	}{
+		{"без пропусків", 5, nil, 10, 0, nil},
+		{"пропуски у вікні", 5, []uint32{6, 8, 10}, 10, 3, []uint32{6, 8, 10}},
+		{"старі пропуски забуваются", 5, []uint32{1, 3, 6}, 6, 2, []uint32{3, 6}},
+		{"пропуск на межі вікна", 5, []uint32{2, 6}, 6, 2, []uint32{2, 6}},
+		{"вікно закінчуєтся раніше", 5, []uint32{2, 6}, 2, 1, []uint32{2, 6}},
+		{"вікно пішло далі", 5, []uint32{2, 6}, 20, 0, []uint32{2, 6}},
+		{"кілька раундів на висоті", 5, []uint32{4, 4, 4}, 4, 3, []uint32{4, 4, 4}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := NewLivenessInfo([]byte("validator"), tt.window)
+			for _, h := range tt.heights {
+				l.AddMissedProposal(h, tt.window)
+			}
+			if got := l.MissedProposalsCount(tt.at, tt.window); got != tt.wantCount {
+				t.Errorf("MissedProposalsCount(%d) = %d, очікувалось %d", tt.at, got, tt.wantCount)
+			}
+			if !slices.Equal(l.MissedProposals, tt.wantKept) {
+				t.Errorf("збережено пропуски %v, очікувалось %v", l.MissedProposals, tt.wantKept)
+			}
+		})
+	}
+}
+
+func TestLivenessReset(t *testing.T) {
+	l := NewLivenessInfo([]byte("validator"), 10)
+	l.MarkVote(1, true, 10)
+	l.AddMissedProposal(1, 10)
+
+	l.Reset(10)
+	if l.MissedVotesCount != 0 || l.MissedProposalsCount(1, 10) != 0 || slices.ContainsFunc(l.MissedVotes, func(b byte) bool { return b != 0 }) {
+		t.Errorf("після Reset залишились пропуски: %+v", l)
+	}
+}
@@ -3,59 +3,291 @@
 package chain
 
 import (
-	"bytes"
 	"errors"
+	"fmt"
+	"sort"
 	"sync"
+	"time"
 
 	"github.com/rs/zerolog/log"
 )
 
+// Mempool зберігає транзакції окремо для кожного відправника:
+//   - pending: транзакції, які можна виконати одна за одною на останньому прийнятому стані. Nonce йдуть підряд
+//     від nonce гаманця, і баланс покриває їх всі разом
+//   - queued: транзакції з пропуском в nonce, або для яких поки не вистачає балансу
+//
+// В блок потрапляют тільки pending. Після кожного нового блоку Promote видаляє виконані транзакції,
+// переносить queued в pending, коли пропуск закрито або з'явився баланс, і видаляє pending, які вже не можна виконати.
+// Транзакцію можна замінити іншою з тим самим nonce і більшою fee
+
+const (
+	maxMempoolSize     = 1000 // скільки транзакцій (pending і queued разом) може бути в mempool
+	maxQueuedPerSender = 64   // скільки queued транзакцій може мати один відправник
+	maxQueued          = 250  // скільки queued транзакцій може бути в mempool від всіх відправників разом
+
+	maxQueuedAge = 10 * time.Minute // queued транзакція, пропуск перед якою не закрито за цей час, видаляєтся
+)
+
+// AccountReader останній прийнятий стан гаманців, з яким mempool перевіряє nonce і баланс
+type AccountReader interface {
+	GetWalletByAddress(addr []byte) (Wallet, error)
+}
+
+// TxValidator виконує транзакції одного відправника одна за одною на останньому прийнятому стані.
+// Повертає, скільки перших транзакцій валідні, і помилку першої невалідної
+type TxValidator func(txs []*Transaction) (int, error)
+
 type Mempool struct {
-	mu  sync.Mutex
-	TXs []*Transaction // NOTE: я маю список посилань на транзакції, а не посилання на список
+	mu         sync.Mutex
+	ChainID    string        // транзакції з іншим chain id не приймаются
+	MaxTxBytes int64         // транзакції, більші за MaxTxBytes, не приймаются, тому що їх не можна додати в блок
+	Accounts   AccountReader // стан, з яким порівнюются nonce і баланс відправника
+
+	senders map[string]*senderTxs
+	count   int
+}
+
+// senderTxs транзакції одного відправника
+type senderTxs struct {
+	nonce   uint32               // nonce гаманця в стані, з яким побудовано pending
+	balance int64                // баланс гаманця в цьому стані
+	spent   int64                // скільки всі pending разом знімают з балансу
+	pending []*Transaction       // nonce від nonce+1 підряд
+	queued  map[uint32]*queuedTx // nonce -> транзакція
+}
+
+type queuedTx struct {
+	tx    *Transaction
+	added time.Time // коли транзакцію додано в queued
 }
 
 func (m *Mempool) Add(tx *Transaction) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	if len(m.TXs) >= 1000 {
+	if tx.Amount < 0 || tx.Fee < 0 {
+		return fmt.Errorf("сума і fee транзакції не можуть бути від'ємними")
+	}
+
+	if size := tx.Size(); size > m.MaxTxBytes {
+		return fmt.Errorf("транзакція має %d байт, максимум %d", size, m.MaxTxBytes)
+	}
+
+	err := tx.Verify(m.ChainID)
+	if err != nil {
+		return err
+	}
+
+	s, err := m.sender(tx.From)
+	if err != nil {
+		return err
+	}
+	if tx.Nonce <= s.nonce {
+		return fmt.Errorf("nonce %d вже використано, nonce гаманця це: %d", tx.Nonce, s.nonce)
+	}
+	debit, err := tx.Debit()
+	if err != nil {
+		return err
+	}
+	if s.has(tx.Nonce) {
+		return s.replace(tx, debit)
+	}
+
+	if m.count >= maxMempoolSize {
 		return errors.New("OOM")
 	}
 
-	for _, txFromMem := range m.TXs {
-		if bytes.Equal(txFromMem.Signature, tx.Signature) {
-			return errors.New("tx is already exists")
+	if tx.Nonce == s.nextNonce() {
+		if s.spent+debit > s.balance {
+			return fmt.Errorf("недостатньо коштів: баланс %d, pending транзакції вже знімают %d, потрібно ще %d", s.balance, s.spent, debit)
+		}
+		s.pending = append(s.pending, tx)
+		s.spent += debit
+		s.promote()
+	} else {
+		if len(s.queued) >= maxQueuedPerSender {
+			return fmt.Errorf("відправник вже має %d транзакцій, які чекают попередній nonce", len(s.queued))
 		}
+		if queued := m.queuedLen(); queued >= maxQueued {
+			return fmt.Errorf("в mempool вже %d транзакцій, які чекают попередній nonce", queued)
+		}
+		s.queued[tx.Nonce] = &queuedTx{tx: tx, added: time.Now()}
 	}
+	m.count++
 
-	err := tx.Verify()
+	return nil
+}
+
+// sender транзакції відправника addr. Для нового відправника nonce і баланс беруться з Accounts
+func (m *Mempool) sender(addr []byte) (*senderTxs, error) {
+	if m.senders == nil {
+		m.senders = make(map[string]*senderTxs)
+	}
+	if s, ok := m.senders[string(addr)]; ok {
+		return s, nil
+	}
+
+	wallet, err := m.Accounts.GetWalletByAddress(addr)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	s := &senderTxs{nonce: wallet.Nonce, balance: wallet.Balance, queued: make(map[uint32]*queuedTx)}
+	m.senders[string(addr)] = s
+	return s, nil
+}
+
+func (s *senderTxs) nextNonce() uint32 {
+	return s.nonce + uint32(len(s.pending)) + 1
+}
+
+func (s *senderTxs) has(nonce uint32) bool {
+	if nonce < s.nextNonce() {
+		return true
 	}
+	_, ok := s.queued[nonce]
+	return ok
+}
 
-	m.TXs = append(m.TXs, tx)
+// replace замінює транзакцію з тим самим nonce на tx, якщо tx має більшу fee.
+// Для pending транзакції баланс має покривати всі pending разом з tx
+func (s *senderTxs) replace(tx *Transaction, debit int64) error {
+	if i := int(tx.Nonce - s.nonce - 1); i < len(s.pending) {
+		old := s.pending[i]
+		if tx.Fee <= old.Fee {
+			return fmt.Errorf("транзакція з nonce %d вже є в mempool, fee нової має бути більшою за %d", tx.Nonce, old.Fee)
+		}
+		oldDebit, err := old.Debit()
+		if err != nil {
+			return err
+		}
+		spent := s.spent - oldDebit + debit
+		if spent > s.balance {
+			return fmt.Errorf("недостатньо коштів: баланс %d, pending транзакції з новою знімают %d", s.balance, spent)
+		}
+		s.pending[i] = tx
+		s.spent = spent
+		s.promote()
+		return nil
+	}
 
+	q := s.queued[tx.Nonce]
+	if tx.Fee <= q.tx.Fee {
+		return fmt.Errorf("транзакція з nonce %d вже є в mempool, fee нової має бути більшою за %d", tx.Nonce, q.tx.Fee)
+	}
+	q.tx = tx
 	return nil
 }
 
+// promote переносить queued в pending, поки nonce йдуть підряд і вистачає балансу
+func (s *senderTxs) promote() {
+	for {
+		q, ok := s.queued[s.nextNonce()]
+		if !ok {
+			return
+		}
+		tx := q.tx
+		debit, err := tx.Debit()
+		if err != nil || s.spent+debit > s.balance {
+			return
+		}
+		delete(s.queued, tx.Nonce)
+		s.pending = append(s.pending, tx)
+		s.spent += debit
+	}
+}
+
+// queuedLen кількість queued транзакцій всіх відправників
+func (m *Mempool) queuedLen() int {
+	n := 0
+	for _, s := range m.senders {
+		n += len(s.queued)
+	}
+	return n
+}
+
+// Len кількість транзакцій в mempool, pending і queued разом
 func (m *Mempool) Len() int {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	return len(m.TXs)
+	return m.count
 }
 
-func (m *Mempool) ClearMempool(txs []*Transaction) {
+// Snapshot копія pending транзакцій всіх відправників, яку можна читати без блокування mempool.
+// Транзакції одного відправника йдуть в порядку nonce
+func (m *Mempool) Snapshot() []*Transaction {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	for _, tx := range txs {
-		for index, localTX := range m.TXs {
-			if bytes.Equal(localTX.Signature, tx.Signature) {
-				m.TXs = append(m.TXs[:index], m.TXs[index+1:]...) // видалити зі слайсу
-				log.Info().Hex("sig", tx.Signature).Msg("видалино транзакцію з mempool")
+	addrs := make([]string, 0, len(m.senders))
+	for addr := range m.senders {
+		addrs = append(addrs, addr)
+	}
+	sort.Strings(addrs)
+
+	var txs []*Transaction
+	for _, addr := range addrs {
+		txs = append(txs, m.senders[addr].pending...)
+	}
+	return txs
+}
+
+// Promote викликаєтся після нового блоку: видаляє транзакції, nonce яких вже використано, і queued, які чекают
+// довше за maxQueuedAge, і заново будує pending кожного відправника з його nonce і балансу в новому стані.
+// Pending перевіряются validate, і перша невалідна транзакція видаляєтся разом з усіма наступними цього відправника
+func (m *Mempool) Promote(validate TxValidator) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	for addr, s := range m.senders {
+		wallet, err := m.Accounts.GetWalletByAddress([]byte(addr))
+		if err != nil {
+			log.Error().Err(err).Hex("адреса", []byte(addr)).Msg("помилка отримання гаманця для mempool")
+			continue
+		}
+
+		for _, tx := range s.pending {
+			s.queued[tx.Nonce] = &queuedTx{tx: tx, added: now}
+		}
+		for nonce, q := range s.queued {
+			if nonce <= wallet.Nonce || now.Sub(q.added) > maxQueuedAge {
+				delete(s.queued, nonce)
+				m.count--
 			}
 		}
+
+		s.nonce = wallet.Nonce
+		s.balance = wallet.Balance
+		s.spent = 0
+		s.pending = nil
+		s.promote()
+
+		if valid, err := validate(s.pending); err != nil {
+			m.drop(s, valid)
+			log.Debug().Err(err).Hex("адреса", []byte(addr)).Uint32("nonce", s.nextNonce()).Msg("транзакцію видалено з mempool")
+		}
+		if len(s.pending) == 0 && len(s.queued) == 0 {
+			delete(m.senders, addr)
+		}
+	}
+	log.Debug().Int("транзакцій", m.count).Int("відправників", len(m.senders)).Msg("оновлено mempool після блоку")
+}
+
+// drop залишає тільки перші valid pending транзакції відправника, а решту pending і всі queued після них видаляє
+func (m *Mempool) drop(s *senderTxs, valid int) {
+	for _, tx := range s.pending[valid:] {
+		debit, _ := tx.Debit()
+		s.spent -= debit
+		m.count--
+	}
+	s.pending = s.pending[:valid]
+
+	for nonce := range s.queued {
+		if nonce >= s.nextNonce() {
+			delete(s.queued, nonce)
+			m.count--
+		}
 	}
 }
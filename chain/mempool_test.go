@@ -0,0 +1,368 @@
+package chain
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+)
+
+// testAccounts стан гаманців для mempool
+type testAccounts map[string]Wallet
+
+func (a testAccounts) GetWalletByAddress(addr []byte) (Wallet, error) {
+	w, ok := a[string(addr)]
+	if !ok {
+		return Wallet{Address: addr}, nil
+	}
+	return w, nil
+}
+
+func testMempool(balance int64, nonce uint32) (*Mempool, testAccounts) {
+	_, pub := testKey()
+	accounts := testAccounts{string(pub): {Address: pub, Balance: balance, Nonce: nonce}}
+	return &Mempool{ChainID: testChainID, MaxTxBytes: 1 << 10, Accounts: accounts}, accounts
+}
+
+func testTx(t *testing.T, nonce uint32, amount int64, fee int64) *Transaction {
+	t.Helper()
+	priv, pub := testKey()
+	tx := &Transaction{
+		ChainID: testChainID,
+		Kind:    TxTransfer,
+		From:    pub,
+		To:      bytes.Repeat([]byte{0x11}, 32),
+		Amount:  amount,
+		Fee:     fee,
+		Nonce:   nonce,
+	}
+	if err := tx.Sign(priv); err != nil {
+		t.Fatal(err)
+	}
+	return tx
+}
+
+// pendingNonces nonce pending і queued транзакцій тестового відправника
+func pendingNonces(m *Mempool) (pending []uint32, queued int) {
+	_, pub := testKey()
+	s, ok := m.senders[string(pub)]
+	if !ok {
+		return nil, 0
+	}
+	for _, tx := range s.pending {
+		pending = append(pending, tx.Nonce)
+	}
+	return pending, len(s.queued)
+}
+
+func equalNonces(a, b []uint32) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestMempoolAdd(t *testing.T) {
+	tests := []struct {
+		name        string
+		balance     int64
+		txs         []*Transaction
+		wantErr     []bool // для кожної транзакції
+		wantPending []uint32
+		wantQueued  int
+	}{
+		{
+			name:        "nonce підряд",
+			balance:     1000,
+			txs:         []*Transaction{testTx(t, 6, 100, 1), testTx(t, 7, 100, 1)},
+			wantErr:     []bool{false, false},
+			wantPending: []uint32{6, 7},
+		},
+		{
+			name:       "пропуск в nonce",
+			balance:    1000,
+			txs:        []*Transaction{testTx(t, 7, 100, 1), testTx(t, 9, 100, 1)},
+			wantErr:    []bool{false, false},
+			wantQueued: 2,
+		},
+		{
+			name:        "пропуск закрито",
+			balance:     1000,
+			txs:         []*Transaction{testTx(t, 8, 100, 1), testTx(t, 7, 100, 1), testTx(t, 6, 100, 1)},
+			wantErr:     []bool{false, false, false},
+			wantPending: []uint32{6, 7, 8},
+		},
+		{
+			name:    "використаний nonce",
+			balance: 1000,
+			txs:     []*Transaction{testTx(t, 5, 100, 1)},
+			wantErr: []bool{true},
+		},
+		{
+			name:        "баланс не покриває всі pending",
+			balance:     250,
+			txs:         []*Transaction{testTx(t, 6, 100, 1), testTx(t, 7, 100, 1), testTx(t, 8, 100, 1)},
+			wantErr:     []bool{false, false, true},
+			wantPending: []uint32{6, 7},
+		},
+		{
+			name:        "queued чекає баланс",
+			balance:     250,
+			txs:         []*Transaction{testTx(t, 8, 100, 1), testTx(t, 6, 100, 1), testTx(t, 7, 100, 1)},
+			wantErr:     []bool{false, false, false},
+			wantPending: []uint32{6, 7},
+			wantQueued:  1,
+		},
+		{
+			name:    "від'ємна сума",
+			balance: 1000,
+			txs:     []*Transaction{testTx(t, 6, -1, 1)},
+			wantErr: []bool{true},
+		},
+		{
+			name:    "переповнення суми",
+			balance: 1000,
+			txs:     []*Transaction{testTx(t, 6, 1<<62, 1<<62)},
+			wantErr: []bool{true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, _ := testMempool(tt.balance, 5)
+			for i, tx := range tt.txs {
+				if err := m.Add(tx); (err != nil) != tt.wantErr[i] {
+					t.Fatalf("транзакція %d: помилка %v, очікувалась помилка: %v", i, err, tt.wantErr[i])
+				}
+			}
+			pending, queued := pendingNonces(m)
+			if !equalNonces(pending, tt.wantPending) || queued != tt.wantQueued {
+				t.Errorf("pending %v, queued %d, очікувалось %v і %d", pending, queued, tt.wantPending, tt.wantQueued)
+			}
+			if m.Len() != len(tt.wantPending)+tt.wantQueued {
+				t.Errorf("Len %d, очікувалось %d", m.Len(), len(tt.wantPending)+tt.wantQueued)
+			}
+		})
+	}
+}
+
+func TestMempoolAddRejects(t *testing.T) {
+	m, _ := testMempool(1000, 0)
+
+	other := testTx(t, 1, 100, 1)
+	other.ChainID = "other"
+	if m.Add(other) == nil {
+		t.Error("транзакцію з іншим chain id прийнято")
+	}
+
+	forged := testTx(t, 1, 100, 1)
+	forged.Amount = 200
+	if m.Add(forged) == nil {
+		t.Error("транзакцію з не правельним підписом прийнято")
+	}
+
+	m.MaxTxBytes = testTx(t, 1, 100, 1).Size() - 1
+	if m.Add(testTx(t, 1, 100, 1)) == nil {
+		t.Error("транзакцію, більшу за MaxTxBytes, прийнято")
+	}
+}
+
+func TestMempoolReplaceByFee(t *testing.T) {
+	tests := []struct {
+		name        string
+		balance     int64
+		first       []*Transaction
+		replacement *Transaction
+		wantErr     bool
+		wantFee     int64 // fee транзакції з nonce заміни після неї
+	}{
+		{"pending з більшою fee", 1000, []*Transaction{testTx(t, 1, 100, 1)}, testTx(t, 1, 100, 5), false, 5},
+		{"pending з тією ж fee", 1000, []*Transaction{testTx(t, 1, 100, 5)}, testTx(t, 1, 100, 5), true, 5},
+		{"pending з меншою fee", 1000, []*Transaction{testTx(t, 1, 100, 5)}, testTx(t, 1, 100, 1), true, 5},
+		{"баланс не покриває заміну", 210, []*Transaction{testTx(t, 1, 100, 1), testTx(t, 2, 100, 1)}, testTx(t, 1, 100, 20), true, 1},
+		{"заміна з меншою сумою", 1000, []*Transaction{testTx(t, 1, 500, 1)}, testTx(t, 1, 10, 2), false, 2},
+		{"queued з більшою fee", 1000, []*Transaction{testTx(t, 3, 100, 1)}, testTx(t, 3, 100, 2), false, 2},
+		{"queued з тією ж fee", 1000, []*Transaction{testTx(t, 3, 100, 1)}, testTx(t, 3, 100, 1), true, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, _ := testMempool(tt.balance, 0)
+			for _, tx := range tt.first {
+				if err := m.Add(tx); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := m.Add(tt.replacement); (err != nil) != tt.wantErr {
+				t.Fatalf("помилка %v, очікувалась помилка: %v", err, tt.wantErr)
+			}
+			if m.Len() != len(tt.first) {
+				t.Errorf("Len %d після заміни, очікувалось %d", m.Len(), len(tt.first))
+			}
+
+			_, pub := testKey()
+			s := m.senders[string(pub)]
+			var got *Transaction
+			for _, tx := range s.pending {
+				if tx.Nonce == tt.replacement.Nonce {
+					got = tx
+				}
+			}
+			if q, ok := s.queued[tt.replacement.Nonce]; ok {
+				got = q.tx
+			}
+			if got == nil || got.Fee != tt.wantFee {
+				t.Fatalf("транзакція з nonce %d: %+v, очікувалась fee %d", tt.replacement.Nonce, got, tt.wantFee)
+			}
+
+			var spent int64
+			for _, tx := range s.pending {
+				debit, _ := tx.Debit()
+				spent += debit
+			}
+			if s.spent != spent {
+				t.Errorf("spent %d, сума pending %d", s.spent, spent)
+			}
+		})
+	}
+}
+
+func TestMempoolPromote(t *testing.T) {
+	errInvalid := errors.New("не валідна")
+
+	tests := []struct {
+		name        string
+		balance     int64
+		txs         []*Transaction
+		wallet      Wallet // стан тестового відправника після блоку
+		validate    TxValidator
+		wantPending []uint32
+		wantQueued  int
+	}{
+		{
+			name:        "виконані транзакції видаляются",
+			balance:     1000,
+			txs:         []*Transaction{testTx(t, 1, 100, 1), testTx(t, 2, 100, 1), testTx(t, 3, 100, 1)},
+			wallet:      Wallet{Balance: 899, Nonce: 1},
+			wantPending: []uint32{2, 3},
+		},
+		{
+			name:        "пропуск закрито в блоці",
+			balance:     1000,
+			txs:         []*Transaction{testTx(t, 2, 100, 1), testTx(t, 3, 100, 1)},
+			wallet:      Wallet{Balance: 899, Nonce: 1},
+			wantPending: []uint32{2, 3},
+		},
+		{
+			name:        "з'явився баланс",
+			balance:     150,
+			txs:         []*Transaction{testTx(t, 2, 100, 1), testTx(t, 1, 100, 1)},
+			wallet:      Wallet{Balance: 500},
+			wantPending: []uint32{1, 2},
+		},
+		{
+			name:        "баланс зменшився",
+			balance:     1000,
+			txs:         []*Transaction{testTx(t, 1, 100, 1), testTx(t, 2, 100, 1)},
+			wallet:      Wallet{Balance: 150},
+			wantPending: []uint32{1},
+			wantQueued:  1,
+		},
+		{
+			name:    "невалідна транзакція видаляєтся разом з наступними",
+			balance: 1000,
+			txs:     []*Transaction{testTx(t, 1, 100, 1), testTx(t, 2, 100, 1), testTx(t, 3, 100, 1), testTx(t, 5, 100, 1)},
+			wallet:  Wallet{Balance: 1000},
+			validate: func(txs []*Transaction) (int, error) {
+				return 1, errInvalid
+			},
+			wantPending: []uint32{1},
+		},
+		{
+			name:    "всі виконані",
+			balance: 1000,
+			txs:     []*Transaction{testTx(t, 1, 100, 1)},
+			wallet:  Wallet{Balance: 899, Nonce: 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, accounts := testMempool(tt.balance, 0)
+			for _, tx := range tt.txs {
+				if err := m.Add(tx); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			_, pub := testKey()
+			tt.wallet.Address = pub
+			accounts[string(pub)] = tt.wallet
+			validate := tt.validate
+			if validate == nil {
+				validate = func(txs []*Transaction) (int, error) { return len(txs), nil }
+			}
+			m.Promote(validate)
+
+			pending, queued := pendingNonces(m)
+			if !equalNonces(pending, tt.wantPending) || queued != tt.wantQueued {
+				t.Errorf("pending %v, queued %d, очікувалось %v і %d", pending, queued, tt.wantPending, tt.wantQueued)
+			}
+			if m.Len() != len(tt.wantPending)+tt.wantQueued {
+				t.Errorf("Len %d, очікувалось %d", m.Len(), len(tt.wantPending)+tt.wantQueued)
+			}
+			if len(tt.wantPending)+tt.wantQueued == 0 && len(m.senders) != 0 {
+				t.Error("відправник без транзакцій залишився в mempool")
+			}
+		})
+	}
+}
+
+func TestMempoolQueuedExpiry(t *testing.T) {
+	tests := []struct {
+		name       string
+		age        time.Duration
+		wantQueued int
+	}{
+		{"нова", time.Minute, 1},
+		{"майже стара", maxQueuedAge - time.Minute, 1},
+		{"стара", maxQueuedAge + time.Minute, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, _ := testMempool(1000, 0)
+			if err := m.Add(testTx(t, 3, 100, 1)); err != nil {
+				t.Fatal(err)
+			}
+			_, pub := testKey()
+			m.senders[string(pub)].queued[3].added = time.Now().Add(-tt.age)
+
+			m.Promote(func(txs []*Transaction) (int, error) { return len(txs), nil })
+			if _, queued := pendingNonces(m); queued != tt.wantQueued || m.Len() != tt.wantQueued {
+				t.Errorf("queued %d, Len %d, очікувалось %d", queued, m.Len(), tt.wantQueued)
+			}
+		})
+	}
+}
+
+func TestMempoolLimits(t *testing.T) {
+	m, _ := testMempool(1<<40, 0)
+	for i := range maxQueuedPerSender {
+		if err := m.Add(testTx(t, uint32(i+2), 1, 1)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if m.Add(testTx(t, maxQueuedPerSender+2, 1, 1)) == nil {
+		t.Errorf("відправник додав більше %d queued транзакцій", maxQueuedPerSender)
+	}
+
+	// nonce 1 закриває пропуск, і всі queued стають pending
+	if err := m.Add(testTx(t, 1, 1, 1)); err != nil {
+		t.Fatal(err)
+	}
+	if pending, queued := pendingNonces(m); len(pending) != maxQueuedPerSender+1 || queued != 0 {
+		t.Errorf("pending %d, queued %d після закриття пропуску", len(pending), queued)
+	}
+}
@@ -0,0 +1,151 @@
+package chain
+
+import (
+	"bytes"
+	"crypto/sha3"
+	"fmt"
+)
+
+// Merkle дерево транзакцій блоку.
+// Листок: sha3-256(0x00 || hash транзакції), вузол: sha3-256(0x01 || лівий || правий).
+// Якщо на рівні непарна кількість вузлів, останній переноситься на наступний рівень без змін.
+// Корінь порожнього дерева: sha3-256 від порожнього рядка.
+
+const (
+	merkleLeafPrefix byte = 0x00
+	merkleNodePrefix byte = 0x01
+)
+
+// MerkleProof доказ того, що транзакція з індексом Index входить в блок з Total транзакцій
+type MerkleProof struct {
+	Index    uint32   `json:"index"`
+	Total    uint32   `json:"total"`
+	Siblings [][]byte `json:"siblings"` // сусідні вузли від листка до кореня
+}
+
+func merkleLeaf(txHash []byte) []byte {
+	h := sha3.New256()
+	h.Write([]byte{merkleLeafPrefix})
+	h.Write(txHash)
+	return h.Sum(nil)
+}
+
+func merkleNode(left, right []byte) []byte {
+	h := sha3.New256()
+	h.Write([]byte{merkleNodePrefix})
+	h.Write(left)
+	h.Write(right)
+	return h.Sum(nil)
+}
+
+func merkleNextLevel(level [][]byte) [][]byte {
+	next := make([][]byte, 0, (len(level)+1)/2)
+	for i := 0; i < len(level); i += 2 {
+		if i+1 < len(level) {
+			next = append(next, merkleNode(level[i], level[i+1]))
+		} else {
+			next = append(next, level[i])
+		}
+	}
+	return next
+}
+
+func merkleLeaves(txs []*Transaction) [][]byte {
+	leaves := make([][]byte, len(txs))
+	for i, tx := range txs {
+		leaves[i] = merkleLeaf(tx.Hash())
+	}
+	return leaves
+}
+
+// MerkleRoot рахує корінь Merkle дерева для hash`ів
+func MerkleRoot(hashes [][]byte) []byte {
+	if len(hashes) == 0 {
+		empty := sha3.Sum256(nil)
+		return empty[:]
+	}
+
+	level := make([][]byte, len(hashes))
+	for i, h := range hashes {
+		level[i] = merkleLeaf(h)
+	}
+	for len(level) > 1 {
+		level = merkleNextLevel(level)
+	}
+	return level[0]
+}
+
+// ComputeTxRoot рахує Merkle root транзакцій блоку
+func (b *Block) ComputeTxRoot() []byte {
+	hashes := make([][]byte, len(b.Transactions))
+	for i, tx := range b.Transactions {
+		hashes[i] = tx.Hash()
+	}
+	return MerkleRoot(hashes)
+}
+
+// TxProof створює доказ входження транзакції з індексом index в блок
+func (b *Block) TxProof(index int) (*MerkleProof, error) {
+	if index < 0 || index >= len(b.Transactions) {
+		return nil, fmt.Errorf("транзакції з індексом %d немає в блоці", index)
+	}
+
+	proof := &MerkleProof{
+		Index: uint32(index),
+		Total: uint32(len(b.Transactions)),
+	}
+
+	level := merkleLeaves(b.Transactions)
+	idx := index
+	for len(level) > 1 {
+		sibling := idx ^ 1
+		if sibling < len(level) {
+			proof.Siblings = append(proof.Siblings, level[sibling])
+		}
+		level = merkleNextLevel(level)
+		idx /= 2
+	}
+
+	return proof, nil
+}
+
+// VerifyTxProof перевіряє, що транзакція входить в блок з цим заголовком. Total доказу має збігатись з TxCount
+// заголовку, інакше той самий корінь можна було б пройти як дерево іншого розміру з іншим індексом
+func VerifyTxProof(header *BlockHeader, tx *Transaction, proof *MerkleProof) error {
+	if proof.Total != header.TxCount {
+		return fmt.Errorf("доказ для блоку з %d транзакцій, а блок має %d", proof.Total, header.TxCount)
+	}
+	if proof.Total == 0 || proof.Index >= proof.Total {
+		return fmt.Errorf("не правельний індекс в доказі")
+	}
+
+	h := merkleLeaf(tx.Hash())
+	idx, size := proof.Index, proof.Total
+	siblings := proof.Siblings
+	for size > 1 {
+		switch {
+		case idx%2 == 1:
+			if len(siblings) == 0 {
+				return fmt.Errorf("доказу не вистачає вузлів")
+			}
+			h = merkleNode(siblings[0], h)
+			siblings = siblings[1:]
+		case idx+1 < size:
+			if len(siblings) == 0 {
+				return fmt.Errorf("доказу не вистачає вузлів")
+			}
+			h = merkleNode(h, siblings[0])
+			siblings = siblings[1:]
+		}
+		idx /= 2
+		size = (size + 1) / 2
+	}
+
+	if len(siblings) != 0 {
+		return fmt.Errorf("доказ має зайві вузли")
+	}
+	if !bytes.Equal(h, header.TxRoot) {
+		return fmt.Errorf("Merkle root з доказу не збігаєтся з TxRoot блоку")
+	}
+	return nil
+}
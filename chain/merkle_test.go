@@ -0,0 +1,143 @@
+package chain
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// testTxBlock блок з n різними транзакціями і заголовком, в якому вже є TxRoot і TxCount
+func testTxBlock(t *testing.T, n int) *Block {
+	t.Helper()
+	priv, pub := testKey()
+	b := &Block{}
+	for i := range n {
+		tx := &Transaction{ChainID: testChainID, From: pub, To: bytes.Repeat([]byte{0x11}, 32), Amount: 1, Nonce: uint32(i + 1)}
+		if err := tx.Sign(priv); err != nil {
+			t.Fatal(err)
+		}
+		b.Transactions = append(b.Transactions, tx)
+	}
+	b.TxRoot = b.ComputeTxRoot()
+	b.TxCount = uint32(n)
+	return b
+}
+
+func TestTxProof(t *testing.T) {
+	for _, n := range []int{1, 2, 3, 4, 5, 6, 7, 8, 9, 13, 16, 17} {
+		b := testTxBlock(t, n)
+		for i, tx := range b.Transactions {
+			proof, err := b.TxProof(i)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := VerifyTxProof(&b.BlockHeader, tx, proof); err != nil {
+				t.Errorf("%d транзакцій, індекс %d: %v", n, i, err)
+			}
+
+			// доказ не підходить до іншої транзакції того ж блоку
+			other := b.Transactions[(i+1)%n]
+			if n > 1 && VerifyTxProof(&b.BlockHeader, other, proof) == nil {
+				t.Errorf("%d транзакцій, індекс %d: доказ підійшов до іншої транзакції", n, i)
+			}
+		}
+	}
+
+	b := testTxBlock(t, 1)
+	for _, index := range []int{-1, 1} {
+		if _, err := b.TxProof(index); err == nil {
+			t.Errorf("створено доказ для індексу %d", index)
+		}
+	}
+}
+
+func TestVerifyTxProofRejects(t *testing.T) {
+	b := testTxBlock(t, 5)
+	tx := b.Transactions[2]
+
+	tests := []struct {
+		name  string
+		proof func(p MerkleProof) MerkleProof
+	}{
+		{"змінений вузол", func(p MerkleProof) MerkleProof {
+			p.Siblings = append([][]byte{bytes.Repeat([]byte{0xff}, 32)}, p.Siblings[1:]...)
+			return p
+		}},
+		{"не вистачає вузлів", func(p MerkleProof) MerkleProof {
+			p.Siblings = p.Siblings[:len(p.Siblings)-1]
+			return p
+		}},
+		{"зайвий вузол", func(p MerkleProof) MerkleProof {
+			p.Siblings = append(append([][]byte{}, p.Siblings...), bytes.Repeat([]byte{0xff}, 32))
+			return p
+		}},
+		{"інший індекс", func(p MerkleProof) MerkleProof {
+			p.Index = 3
+			return p
+		}},
+		{"індекс за межами", func(p MerkleProof) MerkleProof {
+			p.Index = 5
+			return p
+		}},
+		{"total більший за TxCount", func(p MerkleProof) MerkleProof {
+			p.Total = 6
+			return p
+		}},
+		{"total 0", func(p MerkleProof) MerkleProof {
+			p.Total = 0
+			return p
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			proof, err := b.TxProof(2)
+			if err != nil {
+				t.Fatal(err)
+			}
+			forged := tt.proof(*proof)
+			if VerifyTxProof(&b.BlockHeader, tx, &forged) == nil {
+				t.Error("підроблений доказ пройшов перевірку")
+			}
+		})
+	}
+}
+
+// Без перевірки Total доказ останньої транзакції з 3 можна видати за доказ дерева з 2 транзакцій з іншим індексом:
+// непарний вузол переноситься вгору без змін, тому корінь той самий
+func TestVerifyTxProofTotal(t *testing.T) {
+	b := testTxBlock(t, 3)
+	tx := b.Transactions[2]
+	proof, err := b.TxProof(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	forged := MerkleProof{Index: 1, Total: 2, Siblings: proof.Siblings}
+	if VerifyTxProof(&b.BlockHeader, tx, &forged) == nil {
+		t.Error("доказ з іншим total пройшов перевірку")
+	}
+
+	// той самий корінь з TxCount 2 - це інший заголовок, і доказ проходить тільки для нього
+	header := b.BlockHeader
+	header.TxCount = 2
+	if err := VerifyTxProof(&header, tx, &forged); err != nil {
+		t.Errorf("доказ не пройшов перевірку для заголовку з TxCount 2: %v", err)
+	}
+	if VerifyTxProof(&header, tx, proof) == nil {
+		t.Error("доказ для 3 транзакцій пройшов перевірку для заголовку з TxCount 2")
+	}
+}
+
+func TestBlockVerifyTxCount(t *testing.T) {
+	b := testTxBlock(t, 3)
+	b.ChainID = testChainID
+	b.EvidenceRoot = b.ComputeEvidenceRoot()
+	b.LastCommitHash = b.ComputeLastCommitHash()
+	b.TxCount = 2
+	if err := b.GenerateHash(); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Verify(testChainID); err == nil || !strings.Contains(err.Error(), "TxCount") {
+		t.Errorf("блок з не правельним TxCount: %v", err)
+	}
+}
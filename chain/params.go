@@ -0,0 +1,191 @@
+package chain
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// ConsensusParams параметри консенсусу. Задаются в genesis і зберігаются в стані, тому входять в state root
+type ConsensusParams struct {
+	BlockReward    int64 `json:"block_reward"`    // скільки нових монет випускаєтся в блоці до першого зменшення, в копійках
+	TimeoutPropose int64 `json:"timeout_propose"` // скільки чекати блок в раунді 0, в мілісекундах
+	TimeoutVote    int64 `json:"timeout_vote"`    // скільки чекати решту prevote/precommit після 2/3 голосів в раунді 0, в мілісекундах
+	TimeoutDelta   int64 `json:"timeout_delta"`   // на скільки збільшуєтся очікування з кожним наступним раундом, в мілісекундах
+
+	SlashFractionDoubleSign int64 `json:"slash_fraction_double_sign"` // яка частина stake спалюєтся за подвійний підпис, з SlashFractionDenominator
+	EvidenceMaxAge          int64 `json:"evidence_max_age"`           // скільки блоків після порушення доказ ще можна додати в блок
+
+	SignedBlocksWindow    int64 `json:"signed_blocks_window"`    // за скільки останніх висот рахуются пропуски валідатора
+	MaxMissedVotes        int64 `json:"max_missed_votes"`        // скільки precommit у вікні можна пропустити без покарання
+	MaxMissedProposals    int64 `json:"max_missed_proposals"`    // скільки блоків у вікні можна не створити без покарання
+	SlashFractionDowntime int64 `json:"slash_fraction_downtime"` // яка частина stake спалюєтся за пропуски, з SlashFractionDenominator
+	JailDuration          int64 `json:"jail_duration"`           // скільки блоків валідатор не може повернутись після покарання за пропуски
+
+	UnbondingPeriod int64 `json:"unbonding_period"` // скільки блоків stake після unbond заблокований і може бути покараний
+
+	RewardReductionInterval int64 `json:"reward_reduction_interval"` // кожні скільки блоків зменшуєтся BlockReward, 0 - ніколи
+	RewardReduction         int64 `json:"reward_reduction"`          // на яку частину зменшуєтся BlockReward, з RewardFractionDenominator (5000 - halving)
+	ProposerBonus           int64 `json:"proposer_bonus"`            // яку частину нагороди блоку proposer отримує до розподілу між підписантами, з RewardFractionDenominator
+
+	EpochLength int64 `json:"epoch_length"` // скільки блоків в епосі. Набір валідаторів змінюєтся тільки після блоку, висота якого ділиться на EpochLength
+
+	BlockTime     int64 `json:"block_time"`      // через скільки після попереднього блоку створюєтся блок без транзакцій, в мілісекундах
+	MaxClockDrift int64 `json:"max_clock_drift"` // на скільки timestamp блоку може бути попереду локального часу ноди, в мілісекундах
+
+	MaxBlockBytes int64 `json:"max_block_bytes"` // максимальний розмір блоку (Block.Size), в байтах
+	MaxBlockTxs   int64 `json:"max_block_txs"`   // максимальна кількість транзакцій в блоці
+	MaxTxBytes    int64 `json:"max_tx_bytes"`    // максимальний розмір транзакції (Transaction.Size), в байтах
+}
+
+// SlashFractionDenominator знаменник для SlashFractionDoubleSign і SlashFractionDowntime (базисні пункти: 500 - це 5%)
+const SlashFractionDenominator = 10000
+
+// RewardFractionDenominator знаменник для RewardReduction і ProposerBonus (базисні пункти: 500 - це 5%)
+const RewardFractionDenominator = 10000
+
+// maxSignedBlocksWindow обмежує розмір бітової мапи пропусків кожного валідатора в стані
+const maxSignedBlocksWindow = 100000
+
+func (p *ConsensusParams) Validate() error {
+	if p.BlockReward < 0 {
+		return fmt.Errorf("block_reward не може бути від'ємним")
+	}
+	if p.TimeoutPropose <= 0 {
+		return fmt.Errorf("timeout_propose має бути додатнім")
+	}
+	if p.TimeoutVote <= 0 {
+		return fmt.Errorf("timeout_vote має бути додатнім")
+	}
+	if p.TimeoutDelta < 0 {
+		return fmt.Errorf("timeout_delta не може бути від'ємним")
+	}
+	if p.SlashFractionDoubleSign < 0 || p.SlashFractionDoubleSign > SlashFractionDenominator {
+		return fmt.Errorf("slash_fraction_double_sign має бути від 0 до %d", SlashFractionDenominator)
+	}
+	if p.EvidenceMaxAge <= 0 {
+		return fmt.Errorf("evidence_max_age має бути додатнім")
+	}
+	if p.SignedBlocksWindow <= 0 || p.SignedBlocksWindow > maxSignedBlocksWindow {
+		return fmt.Errorf("signed_blocks_window має бути від 1 до %d", maxSignedBlocksWindow)
+	}
+	if p.MaxMissedVotes < 0 || p.MaxMissedVotes >= p.SignedBlocksWindow {
+		return fmt.Errorf("max_missed_votes має бути від 0 до signed_blocks_window")
+	}
+	if p.MaxMissedProposals < 0 {
+		return fmt.Errorf("max_missed_proposals не може бути від'ємним")
+	}
+	if p.SlashFractionDowntime < 0 || p.SlashFractionDowntime > SlashFractionDenominator {
+		return fmt.Errorf("slash_fraction_downtime має бути від 0 до %d", SlashFractionDenominator)
+	}
+	if p.JailDuration < 0 || p.JailDuration > math.MaxUint32 {
+		return fmt.Errorf("jail_duration не правельний")
+	}
+	if p.UnbondingPeriod < 0 || p.UnbondingPeriod > math.MaxUint32 {
+		return fmt.Errorf("unbonding_period не правельний")
+	}
+	// інакше stake можна забрати раніше, ніж доказ подвійного підпису перестане прийматись в блок
+	if p.UnbondingPeriod < p.EvidenceMaxAge {
+		return fmt.Errorf("unbonding_period не може бути меншим за evidence_max_age")
+	}
+	if p.RewardReductionInterval < 0 {
+		return fmt.Errorf("reward_reduction_interval не може бути від'ємним")
+	}
+	if p.RewardReduction < 0 || p.RewardReduction > RewardFractionDenominator {
+		return fmt.Errorf("reward_reduction має бути від 0 до %d", RewardFractionDenominator)
+	}
+	if p.ProposerBonus < 0 || p.ProposerBonus > RewardFractionDenominator {
+		return fmt.Errorf("proposer_bonus має бути від 0 до %d", RewardFractionDenominator)
+	}
+	if p.EpochLength <= 0 || p.EpochLength > math.MaxUint32 {
+		return fmt.Errorf("epoch_length має бути від 1 до %d", uint32(math.MaxUint32))
+	}
+	if p.BlockTime <= 0 {
+		return fmt.Errorf("block_time має бути додатнім")
+	}
+	if p.MaxClockDrift < 0 {
+		return fmt.Errorf("max_clock_drift не може бути від'ємним")
+	}
+	if p.MaxBlockBytes <= 0 {
+		return fmt.Errorf("max_block_bytes має бути додатнім")
+	}
+	if p.MaxBlockTxs <= 0 || p.MaxBlockTxs > math.MaxInt32 {
+		return fmt.Errorf("max_block_txs має бути від 1 до %d", math.MaxInt32)
+	}
+	if p.MaxTxBytes <= 0 || p.MaxTxBytes > p.MaxBlockBytes {
+		return fmt.Errorf("max_tx_bytes має бути від 1 до max_block_bytes")
+	}
+	return nil
+}
+
+// IsEpochEnd чи блок height останній в епосі. Після нього починає працювати новий набір валідаторів
+func (p *ConsensusParams) IsEpochEnd(height uint32) bool {
+	return int64(height)%p.EpochLength == 0
+}
+
+// EmptyBlockAt коли proposer створює блок без транзакцій, якщо попередній блок має timestamp prevTimestamp
+func (p *ConsensusParams) EmptyBlockAt(prevTimestamp int64) time.Time {
+	return time.UnixMilli(prevTimestamp + p.BlockTime)
+}
+
+// VerifyTimestamp перевіряє timestamp блоку: він має бути більшим за timestamp попереднього блоку
+// і не більше ніж на MaxClockDrift попереду now. Нижньої межі відносно now немає, тому що блок
+// попереднього раунду пропонуєтся знову з тим самим timestamp, а нода, яка синхронізуєтся, перевіряє старі блоки
+func (p *ConsensusParams) VerifyTimestamp(timestamp int64, prevTimestamp int64, now time.Time) error {
+	if timestamp <= prevTimestamp {
+		return fmt.Errorf("timestamp блоку %d не більший за timestamp попереднього блоку %d", timestamp, prevTimestamp)
+	}
+	if limit := now.UnixMilli() + p.MaxClockDrift; timestamp > limit {
+		return fmt.Errorf("timestamp блоку %d попереду локального часу більше ніж на %d мс", timestamp, p.MaxClockDrift)
+	}
+	return nil
+}
+
+// VerifyBlockSize перевіряє, що блок не перевищує MaxBlockTxs, MaxTxBytes і MaxBlockBytes
+func (p *ConsensusParams) VerifyBlockSize(b *Block) error {
+	if int64(len(b.Transactions)) > p.MaxBlockTxs {
+		return fmt.Errorf("блок має %d транзакцій, максимум %d", len(b.Transactions), p.MaxBlockTxs)
+	}
+	for _, tx := range b.Transactions {
+		if size := tx.Size(); size > p.MaxTxBytes {
+			return fmt.Errorf("транзакція має %d байт, максимум %d", size, p.MaxTxBytes)
+		}
+	}
+	if size := b.Size(); size > p.MaxBlockBytes {
+		return fmt.Errorf("блок має %d байт, максимум %d", size, p.MaxBlockBytes)
+	}
+	return nil
+}
+
+// BlockRewardAt скільки нових монет випускаєтся в блоці height. Кожні RewardReductionInterval блоків
+// нагорода зменшуєтся на RewardReduction від попередньої, з округленням вниз
+func (p *ConsensusParams) BlockRewardAt(height uint32) int64 {
+	reward := p.BlockReward
+	if p.RewardReductionInterval == 0 {
+		return reward
+	}
+	for i := int64(height) / p.RewardReductionInterval; i > 0; i-- {
+		reduction := MulDiv(reward, p.RewardReduction, RewardFractionDenominator)
+		if reduction == 0 {
+			break
+		}
+		reward -= reduction
+	}
+	return reward
+}
+
+// ProposeTimeout скільки валідатор чекає блок в раунді round, перш ніж голосувати за nil.
+// З кожним раундом очікування збільшуєтся, щоб повільна мережа теж могла домовитись
+func (p *ConsensusParams) ProposeTimeout(round uint32) time.Duration {
+	return time.Duration(p.TimeoutPropose+int64(round)*p.TimeoutDelta) * time.Millisecond
+}
+
+// VoteTimeout скільки валідатор чекає решту голосів, коли вже є більше 2/3 голосів раунду, але не за один блок
+func (p *ConsensusParams) VoteTimeout(round uint32) time.Duration {
+	return time.Duration(p.TimeoutVote+int64(round)*p.TimeoutDelta) * time.Millisecond
+}
+
+// SlashAmount скільки спалюєтся зі stake, якщо fraction - частина з SlashFractionDenominator
+func SlashAmount(stake int64, fraction int64) int64 {
+	return MulDiv(stake, fraction, SlashFractionDenominator)
+}
@@ -0,0 +1,120 @@
+package chain
+
+import (
+	"bytes"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestBlockRewardAt(t *testing.T) {
+	halving := ConsensusParams{BlockReward: 1000, RewardReductionInterval: 10, RewardReduction: 5000}
+	tests := []struct {
+		name   string
+		params ConsensusParams
+		height uint32
+		want   int64
+	}{
+		{"перший блок", halving, 1, 1000},
+		{"до першого зменшення", halving, 9, 1000},
+		{"перше зменшення", halving, 10, 500},
+		{"друге зменшення", halving, 25, 250},
+		{"округлення вниз зменшення", halving, 40, 63},
+		{"нагорода не стає нулем", halving, 1000, 1},
+		{"остання висота", halving, math.MaxUint32, 1},
+		{"без зменшення", ConsensusParams{BlockReward: 1000}, math.MaxUint32, 1000},
+		{"нульове зменшення", ConsensusParams{BlockReward: 1000, RewardReductionInterval: 10}, 100, 1000},
+		{"зменшення на 5%", ConsensusParams{BlockReward: 1000, RewardReductionInterval: 10, RewardReduction: 500}, 20, 903},
+		{"зменшення до нуля", ConsensusParams{BlockReward: 1000, RewardReductionInterval: 10, RewardReduction: RewardFractionDenominator}, 20, 0},
+		{"без нагороди", ConsensusParams{RewardReductionInterval: 10, RewardReduction: 5000}, 20, 0},
+		{"тестова мережа", ConsensusParams{BlockReward: 100000000, RewardReductionInterval: 2100000, RewardReduction: 5000}, 4200000, 25000000},
+		{"тестова мережа, остання висота", ConsensusParams{BlockReward: 100000000, RewardReductionInterval: 2100000, RewardReduction: 5000}, math.MaxUint32, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.params.BlockRewardAt(tt.height); got != tt.want {
+				t.Errorf("BlockRewardAt(%d) = %d, очікувалось %d", tt.height, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerifyTimestamp(t *testing.T) {
+	params := ConsensusParams{MaxClockDrift: 2000}
+	now := time.UnixMilli(100000)
+	tests := []struct {
+		name      string
+		timestamp int64
+		prev      int64
+		wantErr   bool
+	}{
+		{"звичайний блок", 99000, 95000, false},
+		{"на 1 мс пізніше попереднього", 95001, 95000, false},
+		{"той самий timestamp", 95000, 95000, true},
+		{"раніше попереднього", 94999, 95000, true},
+		{"попереду на MaxClockDrift", 102000, 95000, false},
+		{"попереду більше ніж на MaxClockDrift", 102001, 95000, true},
+		{"старий блок при синхронізації", 1000, 500, false},
+		{"від'ємний timestamp", -1, -2, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := params.VerifyTimestamp(tt.timestamp, tt.prev, now); (err != nil) != tt.wantErr {
+				t.Errorf("помилка %v, очікувалась помилка: %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	strict := ConsensusParams{}
+	if strict.VerifyTimestamp(100001, 95000, now) == nil {
+		t.Error("без MaxClockDrift прийнято блок з майбутнього")
+	}
+	if err := strict.VerifyTimestamp(100000, 95000, now); err != nil {
+		t.Errorf("без MaxClockDrift не прийнято блок з timestamp now: %v", err)
+	}
+}
+
+func TestVerifyBlockSize(t *testing.T) {
+	b := testTxBlock(t, 3)
+	var txSize int64
+	for _, tx := range b.Transactions {
+		txSize = max(txSize, tx.Size())
+	}
+	size := b.Size()
+
+	tests := []struct {
+		name    string
+		params  ConsensusParams
+		wantErr bool
+	}{
+		{"всі обмеження на межі", ConsensusParams{MaxBlockTxs: 3, MaxTxBytes: txSize, MaxBlockBytes: size}, false},
+		{"забагато транзакцій", ConsensusParams{MaxBlockTxs: 2, MaxTxBytes: txSize, MaxBlockBytes: size}, true},
+		{"завелика транзакція", ConsensusParams{MaxBlockTxs: 3, MaxTxBytes: txSize - 1, MaxBlockBytes: size}, true},
+		{"завеликий блок", ConsensusParams{MaxBlockTxs: 3, MaxTxBytes: txSize, MaxBlockBytes: size - 1}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.params.VerifyBlockSize(b); (err != nil) != tt.wantErr {
+				t.Errorf("помилка %v, очікувалась помилка: %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	// LastCommit і докази теж входять в розмір блоку
+	params := ConsensusParams{MaxBlockTxs: 3, MaxTxBytes: txSize, MaxBlockBytes: size}
+	priv, _ := testKey()
+	b.LastCommit = &CommitCertificate{ChainID: testChainID, Height: 1, BlockHash: bytes.Repeat([]byte{0xaa}, 28), Precommits: []Vote{*testVote(t, priv, VotePrecommit, 0, 0xaa)}}
+	if params.VerifyBlockSize(b) == nil {
+		t.Error("розмір LastCommit не враховано")
+	}
+	b.LastCommit = nil
+	b.Evidence = []*Evidence{NewVoteEvidence(testVote(t, priv, VotePrevote, 0, 0xaa), testVote(t, priv, VotePrevote, 0, 0xbb))}
+	if params.VerifyBlockSize(b) == nil {
+		t.Error("розмір доказів не враховано")
+	}
+
+	empty := &Block{}
+	if err := (&ConsensusParams{MaxBlockTxs: 1, MaxTxBytes: 1, MaxBlockBytes: empty.Size()}).VerifyBlockSize(empty); err != nil {
+		t.Errorf("порожній блок не пройшов перевірку: %v", err)
+	}
+}
@@ -0,0 +1,61 @@
+package chain
+
+import (
+	"bytes"
+	"crypto/sha3"
+	"encoding/binary"
+	"fmt"
+)
+
+// RANDAO: кожен proposer в своєму блоці відкриває секрет (RandaoReveal), hash якого він записав в стан своїм
+// попереднім блоком, і одразу записує hash наступного секрету (RandaoCommit). Секрети всіх блоків змішуются
+// в RandaoMix, з якого SelectProposer вибирає наступних proposer. Секрет зафіксований раніше, ніж стало відомо,
+// кого він вибере, тому proposer може тільки не створити блок (і отримати пропуск), а не підібрати наступного
+
+// RandaoSize довжина секрету, його hash і RandaoMix
+const RandaoSize = 32
+
+// RandaoCommit hash секрету, який валідатор відкриє в своєму наступному блоці. Записуєтся в стан з першим блоком валідатора
+type RandaoCommit struct {
+	Address []byte `json:"address"`
+	Commit  []byte `json:"commit"`
+	Count   uint32 `json:"count"` // номер секрету (RandaoSecret), hash якого в Commit
+}
+
+// RandaoSecret n-й секрет валідатора: hash з ключем key, приватним ключем валідатора з keys_file. Його знає
+// тільки власник ключа, а нода після перезапуску отримує ті самі секрети, нічого більше не зберігаючи.
+// Підпис для цього не підходить: секрет має бути завжди тим самим, а підпис детермінований тільки в деяких схемах.
+// Ключ стоїть на початку, а sha3 не має length extension, тому без key секрет не підібрати
+func RandaoSecret(chainID string, key []byte, n uint32) []byte {
+	msg := []byte("PQlite randao")
+	msg = binary.BigEndian.AppendUint32(msg, uint32(len(key)))
+	msg = append(msg, key...)
+	msg = binary.BigEndian.AppendUint32(msg, uint32(len(chainID)))
+	msg = append(msg, chainID...)
+	msg = binary.BigEndian.AppendUint32(msg, n)
+
+	h := sha3.Sum256(msg)
+	return h[:]
+}
+
+// RandaoHash hash секрету, який записуєтся в стан до його відкриття
+func RandaoHash(secret []byte) []byte {
+	h := sha3.Sum256(secret)
+	return h[:]
+}
+
+// VerifyReveal перевіряє, що reveal - це секрет, зафіксований в c
+func (c *RandaoCommit) VerifyReveal(reveal []byte) error {
+	if len(reveal) != RandaoSize || !bytes.Equal(RandaoHash(reveal), c.Commit) {
+		return fmt.Errorf("randao reveal не відповідає зафіксованому hash валідатора %x", c.Address)
+	}
+	return nil
+}
+
+// MixRandao додає reveal до mix
+func MixRandao(mix []byte, reveal []byte) []byte {
+	h := sha3.New256()
+	h.Write(mix)
+	h.Write(reveal)
+	return h.Sum(nil)
+}
@@ -0,0 +1,64 @@
+package chain
+
+import (
+	"bytes"
+	"testing"
+)
+
+// Секрет залежить тільки від ключа, chain id і номера, тому після перезапуску нода відкриває той самий секрет
+func TestRandaoSecret(t *testing.T) {
+	priv, _ := testKey()
+	otherPriv, _ := testOtherKey()
+
+	// формула з docs/encoding.md, розділ RANDAO
+	secret := RandaoSecret(testChainID, priv, 0)
+	checkHex(t, "секрет 0", secret, "bba891b5d547f13db196568eb0632ce87dba8333add18c3a7a3f9e9882989da8")
+	if !bytes.Equal(secret, RandaoSecret(testChainID, priv, 0)) {
+		t.Error("секрет не детермінований")
+	}
+
+	tests := []struct {
+		name    string
+		chainID string
+		key     []byte
+		n       uint32
+	}{
+		{"наступний номер", testChainID, priv, 1},
+		{"інша мережа", "other", priv, 0},
+		{"інший ключ", testChainID, otherPriv, 0},
+		{"межа між ключем і chain id", testChainID[1:], append(bytes.Clone(priv), testChainID[0]), 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if other := RandaoSecret(tt.chainID, tt.key, tt.n); len(other) != RandaoSize || bytes.Equal(other, secret) {
+				t.Errorf("секрет %x збігаєтся з секретом 0 або має не правельну довжину", other)
+			}
+		})
+	}
+}
+
+func TestRandaoVerifyReveal(t *testing.T) {
+	priv, pub := testKey()
+	secret := RandaoSecret(testChainID, priv, 3)
+	c := &RandaoCommit{Address: pub, Commit: RandaoHash(secret), Count: 3}
+
+	tests := []struct {
+		name    string
+		reveal  []byte
+		wantErr bool
+	}{
+		{"зафіксований секрет", secret, false},
+		{"попередній секрет", RandaoSecret(testChainID, priv, 2), true},
+		{"наступний секрет", RandaoSecret(testChainID, priv, 4), true},
+		{"hash замість секрету", RandaoHash(secret), true},
+		{"порожній", nil, true},
+		{"обрізаний", secret[:RandaoSize-1], true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := c.VerifyReveal(tt.reveal); (err != nil) != tt.wantErr {
+				t.Errorf("помилка %v, очікувалась помилка: %v", err, tt.wantErr)
+			}
+		})
+	}
+}
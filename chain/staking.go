@@ -0,0 +1,187 @@
+package chain
+
+import (
+	"errors"
+	"fmt"
+)
+
+// TxKind що робить транзакція. Від нього залежить, як трактуются To і Amount
+type TxKind uint32
+
+const (
+	TxTransfer   TxKind = 0 // переказ Amount з From на To
+	TxBond       TxKind = 1 // From делегує Amount зі свого балансу валідатору To. Порожній To - сам From, так стають валідатором
+	TxUnbond     TxKind = 2 // From забирає Amount з делегації валідатору To (порожній - сам From). Сума заблокована на UnbondingPeriod блоків
+	TxWithdraw   TxKind = 3 // From повертає на баланс всі суми, в яких закінчився UnbondingPeriod. Amount 0, To порожній
+	TxUnjail     TxKind = 4 // покараний валідатор From повертаєтся в консенсус. Amount 0, To порожній
+	TxCommission TxKind = 5 // валідатор From встановлює комісію Amount з CommissionDenominator. To порожній
+)
+
+func (k TxKind) String() string {
+	switch k {
+	case TxTransfer:
+		return "transfer"
+	case TxBond:
+		return "bond"
+	case TxUnbond:
+		return "unbond"
+	case TxWithdraw:
+		return "withdraw"
+	case TxUnjail:
+		return "unjail"
+	case TxCommission:
+		return "commission"
+	}
+	return fmt.Sprintf("TxKind(%d)", uint32(k))
+}
+
+// MaxUnbondingEntries скільки незавершених unbond може мати делегатор в одного валідатора. Обмежує розмір запису в стані
+const MaxUnbondingEntries = 7
+
+// CommissionDenominator знаменник для Validator.Commission (базисні пункти: 500 - це 5%)
+const CommissionDenominator = 10000
+
+var ErrNoStake = errors.New("валідатор не має stake")
+
+// Delegation частка делегатора в stake валідатора. Штраф зменшує тільки Validator.Amount, а Shares
+// залишаются, тому штраф і нагорода діляться між делегаторами пропорційно їх Shares
+type Delegation struct {
+	Validator []byte `json:"validator"`
+	Delegator []byte `json:"delegator"`
+	Shares    int64  `json:"shares"`
+}
+
+// TokensFor скільки stake зараз коштують shares
+func (v *Validator) TokensFor(shares int64) int64 {
+	if v.DelegatorShares == 0 {
+		return 0
+	}
+	if shares == v.DelegatorShares {
+		return v.Amount
+	}
+	return MulDiv(shares, v.Amount, v.DelegatorShares)
+}
+
+// AddTokens додає amount до stake валідатора і повертає shares, які за нього отримує делегатор
+func (v *Validator) AddTokens(amount int64) (int64, error) {
+	shares := amount
+	if v.DelegatorShares > 0 {
+		if v.Amount == 0 {
+			return 0, ErrNoStake
+		}
+		shares = MulDiv(amount, v.DelegatorShares, v.Amount)
+	}
+	if shares <= 0 {
+		return 0, fmt.Errorf("сума %d замала для делегації", amount)
+	}
+
+	newAmount, err := AddAmount(v.Amount, amount)
+	if err != nil {
+		return 0, err
+	}
+	newShares, err := AddAmount(v.DelegatorShares, shares)
+	if err != nil {
+		return 0, err
+	}
+	v.Amount, v.DelegatorShares = newAmount, newShares
+	return shares, nil
+}
+
+// RemoveTokens забирає amount зі stake валідатора з делегації d і повертає, скільки shares вона втратила.
+// Shares округлюются вгору, щоб округлення не зменшувало частку інших делегаторів
+func (v *Validator) RemoveTokens(d *Delegation, amount int64) (int64, error) {
+	tokens := v.TokensFor(d.Shares)
+	if amount <= 0 || amount > tokens {
+		return 0, fmt.Errorf("делегація має тільки %d stake", tokens)
+	}
+
+	shares := d.Shares
+	if amount < tokens {
+		shares = MulDiv(amount, v.DelegatorShares, v.Amount)
+		if MulDiv(shares, v.Amount, v.DelegatorShares) < amount {
+			shares++
+		}
+		if shares > d.Shares {
+			shares = d.Shares
+		}
+	}
+
+	v.Amount -= amount
+	v.DelegatorShares -= shares
+	d.Shares -= shares
+	return shares, nil
+}
+
+// SplitReward ділить нагороду валідатора: комісію він залишає собі, решта ділиться між делегаціями
+// пропорційно Shares. Повертає частку кожної делегації, а те, що залишилось від округлення, теж отримує валідатор
+func (v *Validator) SplitReward(amount int64, delegations []Delegation) (toValidator int64, parts []int64) {
+	if v.DelegatorShares == 0 {
+		return amount, make([]int64, len(delegations))
+	}
+
+	commission := MulDiv(amount, v.Commission, CommissionDenominator)
+	rest := amount - commission
+	toValidator = amount
+
+	parts = make([]int64, len(delegations))
+	for i, d := range delegations {
+		parts[i] = MulDiv(rest, d.Shares, v.DelegatorShares)
+		toValidator -= parts[i]
+	}
+	return toValidator, parts
+}
+
+// UnbondingEntry stake, який вже не бере участі в консенсусі, але ще заблокований і може бути покараний
+type UnbondingEntry struct {
+	Amount           int64  `json:"amount"`
+	CreationHeight   uint32 `json:"creation_height"`   // висота блоку з транзакцією unbond
+	CompletionHeight uint32 `json:"completion_height"` // з цієї висоти суму можна повернути транзакцією withdraw
+}
+
+// Unbonding всі незавершені unbond делегатора в одного валідатора
+type Unbonding struct {
+	Delegator []byte           `json:"delegator"`
+	Validator []byte           `json:"validator"`
+	Entries   []UnbondingEntry `json:"entries"`
+}
+
+// Matured сума, яку можна повернути на висоті height
+func (u *Unbonding) Matured(height uint32) (int64, error) {
+	var total int64
+	for _, e := range u.Entries {
+		if e.CompletionHeight > height {
+			continue
+		}
+		var err error
+		if total, err = AddAmount(total, e.Amount); err != nil {
+			return 0, err
+		}
+	}
+	return total, nil
+}
+
+// RemoveMatured видаляє суми, які можна повернути на висоті height
+func (u *Unbonding) RemoveMatured(height uint32) {
+	kept := u.Entries[:0]
+	for _, e := range u.Entries {
+		if e.CompletionHeight > height {
+			kept = append(kept, e)
+		}
+	}
+	u.Entries = kept
+}
+
+// Slash спалює частину fraction (з SlashFractionDenominator) сум, які були ще в stake на висоті порушення
+// infractionHeight і на висоті height ще не завершені, і повертає, скільки спалено
+func (u *Unbonding) Slash(fraction int64, infractionHeight uint32, height uint32) int64 {
+	var total int64
+	for i := range u.Entries {
+		if u.Entries[i].CreationHeight < infractionHeight || u.Entries[i].CompletionHeight <= height {
+			continue
+		}
+		slash := SlashAmount(u.Entries[i].Amount, fraction)
+		u.Entries[i].Amount -= slash
+		total += slash
+	}
+	return total
+}
@@ -0,0 +1,271 @@
+package chain
+
+import (
+	"errors"
+	"math"
+	"math/rand/v2"
+	"slices"
+	"testing"
+)
+
+func TestTokensFor(t *testing.T) {
+	tests := []struct {
+		name   string
+		v      Validator
+		shares int64
+		want   int64
+	}{
+		{"без делегацій", Validator{Amount: 100}, 100, 0},
+		{"1:1", Validator{Amount: 1000, DelegatorShares: 1000}, 400, 400},
+		{"після штрафу", Validator{Amount: 950, DelegatorShares: 1000}, 100, 95},
+		{"округлення вниз", Validator{Amount: 1000, DelegatorShares: 3}, 1, 333},
+		{"всі shares без округлення", Validator{Amount: 1000, DelegatorShares: 3}, 3, 1000},
+		{"великі суми", Validator{Amount: math.MaxInt64, DelegatorShares: math.MaxInt64}, math.MaxInt64 - 1, math.MaxInt64 - 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.v.TokensFor(tt.shares); got != tt.want {
+				t.Errorf("TokensFor(%d) = %d, очікувалось %d", tt.shares, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAddTokens(t *testing.T) {
+	tests := []struct {
+		name       string
+		v          Validator
+		amount     int64
+		wantShares int64
+		wantErr    bool
+	}{
+		{"новий валідатор", Validator{}, 100, 100, false},
+		{"1:1", Validator{Amount: 1000, DelegatorShares: 1000}, 100, 100, false},
+		{"після штрафу shares дешевші", Validator{Amount: 950, DelegatorShares: 1000}, 95, 100, false},
+		{"округлення вниз", Validator{Amount: 1000, DelegatorShares: 3}, 500, 1, false},
+		{"сума менша за один share", Validator{Amount: 1000, DelegatorShares: 1}, 999, 0, true},
+		{"нульова сума", Validator{}, 0, 0, true},
+		{"stake спалено повністю", Validator{DelegatorShares: 100}, 100, 0, true},
+		{"переповнення stake", Validator{Amount: math.MaxInt64, DelegatorShares: 1}, math.MaxInt64, 0, true},
+		{"переповнення shares", Validator{Amount: 1, DelegatorShares: math.MaxInt64}, 1, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := tt.v
+			shares, err := v.AddTokens(tt.amount)
+			if (err != nil) != tt.wantErr || shares != tt.wantShares {
+				t.Fatalf("AddTokens(%d) = %d, %v, очікувалось %d, помилка: %v", tt.amount, shares, err, tt.wantShares, tt.wantErr)
+			}
+			if err != nil {
+				// після помилки валідатор не змінюєтся
+				if v.Amount != tt.v.Amount || v.DelegatorShares != tt.v.DelegatorShares {
+					t.Errorf("валідатор змінено після помилки: %+v", v)
+				}
+				return
+			}
+			if v.Amount != tt.v.Amount+tt.amount || v.DelegatorShares != tt.v.DelegatorShares+shares {
+				t.Errorf("валідатор %+v після AddTokens(%d)", v, tt.amount)
+			}
+		})
+	}
+
+	v := Validator{DelegatorShares: 100}
+	if _, err := v.AddTokens(100); !errors.Is(err, ErrNoStake) {
+		t.Errorf("помилка %v, очікувалась ErrNoStake", err)
+	}
+}
+
+func TestRemoveTokens(t *testing.T) {
+	tests := []struct {
+		name       string
+		v          Validator
+		shares     int64 // shares делегації
+		amount     int64
+		wantShares int64
+		wantErr    bool
+	}{
+		{"частина делегації", Validator{Amount: 1000, DelegatorShares: 1000}, 400, 100, 100, false},
+		{"вся делегація", Validator{Amount: 1000, DelegatorShares: 1000}, 400, 400, 400, false},
+		{"вся делегація після штрафу", Validator{Amount: 950, DelegatorShares: 1000}, 1000, 950, 1000, false},
+		{"shares округлюются вгору", Validator{Amount: 1000, DelegatorShares: 3}, 3, 1, 1, false},
+		{"після штрафу shares дорожчі", Validator{Amount: 950, DelegatorShares: 1000}, 1000, 95, 100, false},
+		{"більше ніж є", Validator{Amount: 950, DelegatorShares: 1000}, 100, 96, 0, true},
+		{"нульова сума", Validator{Amount: 1000, DelegatorShares: 1000}, 400, 0, 0, true},
+		{"від'ємна сума", Validator{Amount: 1000, DelegatorShares: 1000}, 400, -1, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := tt.v
+			d := Delegation{Shares: tt.shares}
+			shares, err := v.RemoveTokens(&d, tt.amount)
+			if (err != nil) != tt.wantErr || shares != tt.wantShares {
+				t.Fatalf("RemoveTokens(%d) = %d, %v, очікувалось %d, помилка: %v", tt.amount, shares, err, tt.wantShares, tt.wantErr)
+			}
+			if err != nil {
+				if v.Amount != tt.v.Amount || v.DelegatorShares != tt.v.DelegatorShares || d.Shares != tt.shares {
+					t.Errorf("валідатор %+v і делегація %+v змінені після помилки", v, d)
+				}
+				return
+			}
+			if v.Amount != tt.v.Amount-tt.amount || v.DelegatorShares != tt.v.DelegatorShares-shares || d.Shares != tt.shares-shares {
+				t.Errorf("валідатор %+v і делегація %+v після RemoveTokens(%d)", v, d, tt.amount)
+			}
+		})
+	}
+}
+
+// Після будь-яких bond, unbond і штрафів сума shares делегацій дорівнює DelegatorShares валідатора,
+// а делегації разом не можуть забрати більше stake, ніж є у валідатора
+func TestSharesInvariant(t *testing.T) {
+	r := rand.New(rand.NewPCG(1, 2))
+	var v Validator
+	delegations := make([]Delegation, 5)
+
+	check := func(step int) {
+		t.Helper()
+		var shares, tokens int64
+		for _, d := range delegations {
+			shares += d.Shares
+			tokens += v.TokensFor(d.Shares)
+		}
+		if shares != v.DelegatorShares || tokens > v.Amount {
+			t.Fatalf("крок %d: shares %d з %d, stake %d з %d", step, shares, v.DelegatorShares, tokens, v.Amount)
+		}
+	}
+
+	for step := range 10000 {
+		d := &delegations[r.IntN(len(delegations))]
+		switch r.IntN(4) {
+		case 0, 1:
+			if v.DelegatorShares > 0 && v.Amount == 0 {
+				continue
+			}
+			shares, err := v.AddTokens(r.Int64N(1000) + 1)
+			if err == nil {
+				d.Shares += shares
+			}
+		case 2:
+			tokens := v.TokensFor(d.Shares)
+			if tokens == 0 {
+				continue
+			}
+			if _, err := v.RemoveTokens(d, r.Int64N(tokens)+1); err != nil {
+				t.Fatalf("крок %d: %v", step, err)
+			}
+		case 3:
+			v.Amount -= SlashAmount(v.Amount, r.Int64N(1000))
+		}
+		check(step)
+	}
+}
+
+func TestSplitReward(t *testing.T) {
+	tests := []struct {
+		name        string
+		v           Validator
+		delegations []int64 // shares делегацій
+		amount      int64
+		wantVal     int64
+		wantParts   []int64
+	}{
+		{"без делегацій", Validator{Amount: 100, Commission: 1000}, []int64{0}, 1000, 1000, []int64{0}},
+		{"без комісії", Validator{Amount: 1000, DelegatorShares: 1000}, []int64{600, 400}, 1000, 0, []int64{600, 400}},
+		{"комісія 10%", Validator{Amount: 1000, DelegatorShares: 1000, Commission: 1000}, []int64{600, 400}, 1000, 100, []int64{540, 360}},
+		{"комісія 100%", Validator{Amount: 1000, DelegatorShares: 1000, Commission: CommissionDenominator}, []int64{600, 400}, 1000, 1000, []int64{0, 0}},
+		{"залишок округлення валідатору", Validator{Amount: 3, DelegatorShares: 3}, []int64{1, 1, 1}, 100, 1, []int64{33, 33, 33}},
+		{"нагорода менша за кількість делегацій", Validator{Amount: 3, DelegatorShares: 3}, []int64{1, 1, 1}, 2, 2, []int64{0, 0, 0}},
+		{"нульова нагорода", Validator{Amount: 1000, DelegatorShares: 1000, Commission: 1000}, []int64{1000}, 0, 0, []int64{0}},
+		{"великі суми", Validator{Amount: math.MaxInt64, DelegatorShares: math.MaxInt64}, []int64{math.MaxInt64 - 1, 1}, math.MaxInt64, 0, []int64{math.MaxInt64 - 1, 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			delegations := make([]Delegation, len(tt.delegations))
+			for i, shares := range tt.delegations {
+				delegations[i].Shares = shares
+			}
+			toValidator, parts := tt.v.SplitReward(tt.amount, delegations)
+			if toValidator != tt.wantVal || !slices.Equal(parts, tt.wantParts) {
+				t.Errorf("SplitReward = %d, %v, очікувалось %d, %v", toValidator, parts, tt.wantVal, tt.wantParts)
+			}
+
+			// нагорода розподіляєтся повністю
+			total := toValidator
+			for _, p := range parts {
+				total += p
+			}
+			if total != tt.amount {
+				t.Errorf("розподілено %d з %d", total, tt.amount)
+			}
+		})
+	}
+}
+
+func TestUnbondingSlash(t *testing.T) {
+	// unbond на висотах 3, 8 і 12, кожен завершуєтся через 50 блоків
+	entries := []UnbondingEntry{
+		{Amount: 1000, CreationHeight: 3, CompletionHeight: 53},
+		{Amount: 2000, CreationHeight: 8, CompletionHeight: 58},
+		{Amount: 3000, CreationHeight: 12, CompletionHeight: 62},
+	}
+	tests := []struct {
+		name             string
+		fraction         int64
+		infractionHeight uint32
+		height           uint32
+		want             []int64
+		wantSlash        int64
+	}{
+		{"порушення до всіх unbond", 500, 1, 20, []int64{950, 1900, 2850}, 300},
+		{"unbond на висоті порушення карається", 500, 8, 20, []int64{1000, 1900, 2850}, 250},
+		{"порушення після всіх unbond", 500, 13, 20, []int64{1000, 2000, 3000}, 0},
+		{"завершені unbond не караются", 500, 1, 58, []int64{1000, 2000, 2850}, 150},
+		{"unbond на межі завершення", 500, 1, 57, []int64{1000, 1900, 2850}, 250},
+		{"без штрафу", 0, 1, 20, []int64{1000, 2000, 3000}, 0},
+		{"весь stake", SlashFractionDenominator, 1, 20, []int64{0, 0, 0}, 6000},
+		{"округлення вниз", 1, 1, 20, []int64{1000, 2000, 3000}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u := &Unbonding{Entries: slices.Clone(entries)}
+			if got := u.Slash(tt.fraction, tt.infractionHeight, tt.height); got != tt.wantSlash {
+				t.Errorf("спалено %d, очікувалось %d", got, tt.wantSlash)
+			}
+			for i, e := range u.Entries {
+				if e.Amount != tt.want[i] || e.CreationHeight != entries[i].CreationHeight || e.CompletionHeight != entries[i].CompletionHeight {
+					t.Errorf("unbond %d: %+v, очікувалась сума %d", i, e, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestUnbondingMatured(t *testing.T) {
+	u := &Unbonding{Entries: []UnbondingEntry{
+		{Amount: 100, CreationHeight: 3, CompletionHeight: 53},
+		{Amount: 200, CreationHeight: 8, CompletionHeight: 58},
+	}}
+	tests := []struct {
+		height   uint32
+		want     int64
+		wantKept int
+	}{
+		{52, 0, 2},
+		{53, 100, 1},
+		{58, 300, 0},
+	}
+	for _, tt := range tests {
+		c := &Unbonding{Entries: slices.Clone(u.Entries)}
+		got, err := c.Matured(tt.height)
+		if err != nil || got != tt.want {
+			t.Errorf("Matured(%d) = %d, %v, очікувалось %d", tt.height, got, err, tt.want)
+		}
+		if c.RemoveMatured(tt.height); len(c.Entries) != tt.wantKept {
+			t.Errorf("RemoveMatured(%d) залишив %d unbond, очікувалось %d", tt.height, len(c.Entries), tt.wantKept)
+		}
+	}
+
+	overflow := &Unbonding{Entries: []UnbondingEntry{{Amount: math.MaxInt64}, {Amount: 1}}}
+	if _, err := overflow.Matured(0); !errors.Is(err, ErrAmountOverflow) {
+		t.Errorf("помилка %v, очікувалось ErrAmountOverflow", err)
+	}
+}
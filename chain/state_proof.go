@@ -0,0 +1,124 @@
+package chain
+
+import (
+	"bytes"
+	"crypto/sha3"
+	"fmt"
+)
+
+// Дерево стану - sparse Merkle дерево глибиною 256: шлях ключа - біти sha3-256(ключ), від старшого.
+// Листок: sha3-256(0x00 || sha3-256(ключ) || sha3-256(значення)), вузол: sha3-256(0x01 || лівий || правий),
+// порожнє піддерево - 32 нульові байти. Піддерево з одним листком - це сам листок, тому листок стоїть
+// на найменшій глибині, на якій його шлях вже відрізняєтся від шляхів інших ключів. Корінь залежить тільки
+// від набору ключів і значень, а не від порядку змін
+
+const (
+	stateLeafPrefix byte = 0x00
+	stateNodePrefix byte = 0x01
+
+	// StateTreeDepth глибина дерева стану, в бітах hash`у ключа
+	StateTreeDepth = 256
+)
+
+// StateProof доказ того, що ключ Key має в стані значення Value, або що ключа в стані немає (порожній Value).
+// Siblings - сусідні піддерева від кореня вниз по шляху ключа. Шлях закінчуєтся листком Key, порожнім піддеревом,
+// або листком іншого ключа з таким самим початком шляху, тоді його hash`і в OtherKeyHash і OtherValueHash
+type StateProof struct {
+	Key            []byte   `json:"key"`
+	Value          []byte   `json:"value,omitempty"`
+	OtherKeyHash   []byte   `json:"other_key_hash,omitempty"`
+	OtherValueHash []byte   `json:"other_value_hash,omitempty"`
+	Siblings       [][]byte `json:"siblings"`
+}
+
+// StateKeyHash шлях ключа в дереві стану
+func StateKeyHash(key []byte) []byte {
+	h := sha3.Sum256(key)
+	return h[:]
+}
+
+// StateValueHash hash значення, який зберігає листок
+func StateValueHash(value []byte) []byte {
+	h := sha3.Sum256(value)
+	return h[:]
+}
+
+// StateLeafHash hash листка з ключем keyHash і значенням valueHash
+func StateLeafHash(keyHash, valueHash []byte) []byte {
+	h := sha3.New256()
+	h.Write([]byte{stateLeafPrefix})
+	h.Write(keyHash)
+	h.Write(valueHash)
+	return h.Sum(nil)
+}
+
+// StateNodeHash hash вузла з піддеревами left і right
+func StateNodeHash(left, right []byte) []byte {
+	h := sha3.New256()
+	h.Write([]byte{stateNodePrefix})
+	h.Write(left)
+	h.Write(right)
+	return h.Sum(nil)
+}
+
+// EmptyStateRoot корінь порожнього дерева стану, він же hash будь-якого порожнього піддерева
+func EmptyStateRoot() []byte {
+	return make([]byte, 32)
+}
+
+// StateKeyBit біт шляху keyHash на глибині depth: 0 - ліве піддерево, 1 - праве
+func StateKeyBit(keyHash []byte, depth int) byte {
+	return keyHash[depth/8] >> (7 - depth%8) & 1
+}
+
+// VerifyStateProof перевіряє доказ значення ключа (або його відсутності) в стані з коренем root
+func VerifyStateProof(root []byte, proof *StateProof) error {
+	if len(proof.Siblings) >= StateTreeDepth {
+		return fmt.Errorf("доказ довший за глибину дерева")
+	}
+	keyHash := StateKeyHash(proof.Key)
+
+	var h []byte
+	switch {
+	case len(proof.Value) > 0:
+		if len(proof.OtherKeyHash) != 0 || len(proof.OtherValueHash) != 0 {
+			return fmt.Errorf("доказ не може мати і значення, і інший листок")
+		}
+		h = StateLeafHash(keyHash, StateValueHash(proof.Value))
+	case len(proof.OtherKeyHash) != 0:
+		if len(proof.OtherKeyHash) != 32 || len(proof.OtherValueHash) != 32 {
+			return fmt.Errorf("не правельна довжина hash`у іншого листка")
+		}
+		if bytes.Equal(proof.OtherKeyHash, keyHash) {
+			return fmt.Errorf("інший листок має той самий ключ")
+		}
+		for depth := range proof.Siblings {
+			if StateKeyBit(proof.OtherKeyHash, depth) != StateKeyBit(keyHash, depth) {
+				return fmt.Errorf("інший листок не лежить на шляху ключа")
+			}
+		}
+		h = StateLeafHash(proof.OtherKeyHash, proof.OtherValueHash)
+	default:
+		if len(proof.OtherValueHash) != 0 {
+			return fmt.Errorf("hash значення іншого листка без його ключа")
+		}
+		h = EmptyStateRoot()
+	}
+
+	for depth := len(proof.Siblings) - 1; depth >= 0; depth-- {
+		sibling := proof.Siblings[depth]
+		if len(sibling) != 32 {
+			return fmt.Errorf("не правельна довжина вузла на глибині %d", depth)
+		}
+		if StateKeyBit(keyHash, depth) == 0 {
+			h = StateNodeHash(h, sibling)
+		} else {
+			h = StateNodeHash(sibling, h)
+		}
+	}
+
+	if !bytes.Equal(h, root) {
+		return fmt.Errorf("корінь з доказу не збігаєтся з state root")
+	}
+	return nil
+}
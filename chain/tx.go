@@ -4,16 +4,20 @@ package chain
 
 import (
 	"bytes"
-	"encoding/json"
+	"crypto/sha3"
+	"fmt"
+	"sort"
 
 	"github.com/PQlite/crypto"
-	"github.com/rs/zerolog/log"
 )
 
 type Transaction struct {
+	ChainID   string `json:"chain_id"` // ідентифікатор мережі, захист від повтору транзакції в іншій мережі
+	Kind      TxKind `json:"kind"`     // переказ, якщо не вказано
 	From      []byte `json:"from"`
 	To        []byte `json:"to"`
-	Amount    int64  `json:"amount"`
+	Amount    int64  `json:"amount"` // в копійках
+	Fee       int64  `json:"fee"`    // в копійках, комісія, яку отримує творець блоку
 	Timestamp int64  `json:"timestamp"`
 	Nonce     uint32 `json:"nonce"`
 	Signature []byte `json:"signature"`
@@ -21,21 +25,19 @@ type Transaction struct {
 
 func (t Transaction) GetUnsignTransaction() *Transaction {
 	return &Transaction{
+		ChainID:   t.ChainID,
+		Kind:      t.Kind,
 		From:      t.From,
 		To:        t.To,
 		Amount:    t.Amount,
+		Fee:       t.Fee,
 		Timestamp: t.Timestamp,
 		Nonce:     t.Nonce,
 	}
 }
 
 func (t *Transaction) Sign(priv []byte) error {
-	data, err := json.Marshal(t)
-	if err != nil {
-		return err
-	}
-
-	sign, err := crypto.Sign(priv, data)
+	sign, err := crypto.Sign(priv, t.SigningBytes())
 	if err != nil {
 		return err
 	}
@@ -44,29 +46,77 @@ func (t *Transaction) Sign(priv []byte) error {
 	return nil
 }
 
-// Verify якщо все ок, і транзакція пройшла перевірку, буде повернуто nil, в іншому випадку err з описом
-func (t *Transaction) Verify() error {
-	unTx := t.GetUnsignTransaction()
-	data, err := json.Marshal(unTx)
-	if err != nil {
-		log.Error().Err(err).Msg("Помилка під час серіалізації транзакції для перевірки")
-		return err
+// Hash ідентифікатор транзакції: sha3-256 від канонічного представлення разом з підписом
+func (t *Transaction) Hash() []byte {
+	h := sha3.Sum256(t.MarshalDeterministic())
+	return h[:]
+}
+
+// Size розмір транзакції для обмеження MaxTxBytes: довжина канонічного представлення разом з підписом
+func (t *Transaction) Size() int64 {
+	return int64(len(t.MarshalDeterministic()))
+}
+
+// Debit скільки транзакція знімає з балансу відправника. Unbond і withdraw платять тільки fee,
+// а сума йде зі stake
+func (t *Transaction) Debit() (int64, error) {
+	if t.Kind == TxTransfer || t.Kind == TxBond {
+		return AddAmount(t.Amount, t.Fee)
 	}
+	return t.Fee, nil
+}
 
-	// вийнятки для системних адрес.
-	var pubKey []byte
-	if bytes.Equal(t.From, []byte("reward")) || bytes.Equal(t.From, []byte("stake")) {
-		pubKey = t.To
-	} else {
-		pubKey = t.From
+// Verify якщо все ок, і транзакція пройшла перевірку, буде повернуто nil, в іншому випадку err з описом
+func (t *Transaction) Verify(chainID string) error {
+	if t.ChainID != chainID {
+		return fmt.Errorf("транзакція для іншої мережі: %q, очікувалось: %q", t.ChainID, chainID)
 	}
 
-	if err = crypto.Verify(pubKey, data, t.Signature); err != nil {
+	data := t.SigningBytes()
+
+	if err := crypto.Verify(t.From, data, t.Signature); err != nil {
 		return err
 	}
 	return nil
 }
 
+// SortByFee сортує транзакції від найбільшої fee до найменшої, але транзакції одного відправника
+// залишаются в порядку nonce: наступною йде транзакція з найбільшою fee серед перших (за nonce) транзакцій
+// кожного відправника. При однаковій fee порядок визначається підписом, щоб сортування було детерміністичним.
+func SortByFee(txs []*Transaction) {
+	bySender := make(map[string][]*Transaction)
+	for _, tx := range txs {
+		bySender[string(tx.From)] = append(bySender[string(tx.From)], tx)
+	}
+	for _, senderTxs := range bySender {
+		sort.SliceStable(senderTxs, func(i, j int) bool {
+			return senderTxs[i].Nonce < senderTxs[j].Nonce
+		})
+	}
+
+	for i := range txs {
+		var best []*Transaction
+		for _, senderTxs := range bySender {
+			if best == nil || higherFee(senderTxs[0], best[0]) {
+				best = senderTxs
+			}
+		}
+		txs[i] = best[0]
+		if len(best) == 1 {
+			delete(bySender, string(best[0].From))
+		} else {
+			bySender[string(best[0].From)] = best[1:]
+		}
+	}
+}
+
+func higherFee(a *Transaction, b *Transaction) bool {
+	if a.Fee != b.Fee {
+		return a.Fee > b.Fee
+	}
+	return bytes.Compare(a.Signature, b.Signature) < 0
+}
+
 // func VerifyAndAddValidators(t []*Transaction) error {
 // 	for _, tx := range t {
 // 		isValid, err := tx.Verify()
@@ -2,33 +2,84 @@ package chain
 
 import (
 	"crypto/sha256"
+	"crypto/sha3"
+	"encoding/binary"
 	"errors"
 	"math/big"
 )
 
 type Validator struct {
 	Address []byte
-	Amount  int64
+	Amount  int64 // stake в копійках: власний і делегований разом, саме він визначає вагу валідатора
+	Jailed  bool  // валідатор покараний і не бере участі в консенсусі
+
+	JailedUntil uint32 // з якої висоти валідатор може повернутись в консенсус транзакцією unjail
+	Tombstoned  bool   // покараний за подвійний підпис і вже ніколи не повернеться
+
+	DelegatorShares int64 // сума Shares всіх делегацій валідатора, разом з його власною
+	Commission      int64 // яку частину нагороди валідатор залишає собі до розподілу, з CommissionDenominator
+}
+
+// ValidatorSet валідатори, які створюють блоки і голосують протягом епохи, з їх stake на її початок.
+// Bond, unbond, штрафи і unjail змінюют тільки валідаторів в стані, а набір оновлюєтся з них на межі епохи,
+// тому для будь-якої висоти всі ноди однаково вибирають proposer і рахують кворум
+type ValidatorSet struct {
+	Validators []Validator // тільки Address і Amount, в порядку адрес
 }
 
-func SelectNextProposer(blockHash []byte, validators []Validator) (*Validator, error) {
+// NewValidatorSet набір з активних валідаторів з ненульовим stake
+func NewValidatorSet(validators []Validator) *ValidatorSet {
+	set := &ValidatorSet{}
+	for _, v := range ActiveValidators(validators) {
+		if v.Amount > 0 {
+			set.Validators = append(set.Validators, Validator{Address: v.Address, Amount: v.Amount})
+		}
+	}
+	return set
+}
+
+// Hash sha3-256 канонічного кодування набору
+func (s *ValidatorSet) Hash() []byte {
+	data, _ := s.MarshalBinary()
+	h := sha3.Sum256(data)
+	return h[:]
+}
+
+// ActiveValidators валідатори, які беруть участь в консенсусі: створюють блоки і голосують
+func ActiveValidators(validators []Validator) []Validator {
+	active := make([]Validator, 0, len(validators))
+	for _, v := range validators {
+		if !v.Jailed {
+			active = append(active, v)
+		}
+	}
+	return active
+}
+
+// SelectProposer вибирає творця блоку для висоти height і раунду round, пропорційно до stake.
+// seed - RandaoMix після попереднього блоку. Якщо proposer раунду не зробив блок, в наступному раунді
+// вибираєтся інший (з тими самими валідаторами, але іншим seed)
+func SelectProposer(seed []byte, height uint32, round uint32, validators []Validator) (*Validator, error) {
 	if len(validators) == 0 {
 		return nil, errors.New("empty validator set")
 	}
 
-	var totalAmount int64
-	for _, v := range validators {
-		totalAmount += v.Amount
+	totalAmount, err := TotalStake(validators)
+	if err != nil {
+		return nil, err
 	}
 
 	if totalAmount == 0 {
-		// If total stake is 0, we can just pick the first validator
-		return &validators[0], nil
+		// If total stake is 0, we can just pick the validator by round
+		return &validators[int(round)%len(validators)], nil
 	}
 
-	// Use the block hash to deterministically select a proposer
-	seed := sha256.Sum256(blockHash)
-	hashInt := new(big.Int).SetBytes(seed[:])
+	// sha256(seed || height || round)
+	h := sha256.New()
+	h.Write(seed)
+	h.Write(binary.BigEndian.AppendUint32(nil, height))
+	h.Write(binary.BigEndian.AppendUint32(nil, round))
+	hashInt := new(big.Int).SetBytes(h.Sum(nil))
 	pick := new(big.Int).Mod(hashInt, big.NewInt(totalAmount))
 
 	var cumulativeAmount int64
@@ -42,3 +93,26 @@ func SelectNextProposer(blockHash []byte, validators []Validator) (*Validator, e
 	// This part should not be reached if logic is correct, but as a fallback
 	return &validators[0], nil
 }
+
+// TotalStake сума stake всіх валідаторів
+func TotalStake(validators []Validator) (int64, error) {
+	var total int64
+	for _, v := range validators {
+		var err error
+		if total, err = AddAmount(total, v.Amount); err != nil {
+			return 0, err
+		}
+	}
+	return total, nil
+}
+
+// HasTwoThirds чи power більше 2/3 від total. Стільки голосів потрібно для рішення в консенсусі
+func HasTwoThirds(power int64, total int64) bool {
+	// power*3 > total*2 без переповнення int64
+	return new(big.Int).Mul(big.NewInt(power), big.NewInt(3)).Cmp(new(big.Int).Mul(big.NewInt(total), big.NewInt(2))) > 0
+}
+
+// HasOneThird чи power більше 1/3 від total. Серед стількох валідаторів точно є хоча б один чесний
+func HasOneThird(power int64, total int64) bool {
+	return new(big.Int).Mul(big.NewInt(power), big.NewInt(3)).Cmp(big.NewInt(total)) > 0
+}
@@ -1,24 +1,123 @@
 package chain
 
 import (
+	"bytes"
+	"fmt"
+
 	"github.com/PQlite/crypto"
 )
 
 type VoteCh chan Vote
 
+type VoteType uint32
+
+const (
+	VotePrevote   VoteType = 1
+	VotePrecommit VoteType = 2
+)
+
+func (t VoteType) String() string {
+	switch t {
+	case VotePrevote:
+		return "prevote"
+	case VotePrecommit:
+		return "precommit"
+	}
+	return fmt.Sprintf("VoteType(%d)", uint32(t))
+}
+
+// Vote голос валідатора в раунді Round на висоті Height. Порожній BlockHash - голос за nil,
+// тобто за те, що в цьому раунді не вдалось вчасно отримати валідний блок
 type Vote struct {
+	Type      VoteType `json:"type"`
+	Height    uint32   `json:"height"`
+	Round     uint32   `json:"round"`
+	BlockHash []byte   `json:"block_hash"`
+	Pub       []byte   `json:"pub"`
+	Signature []byte   `json:"signature"`
+}
+
+func (v *Vote) IsNil() bool {
+	return len(v.BlockHash) == 0
+}
+
+// Sign підписує голос. Підписуєтся chain id, тип, висота, раунд і hash блоку, а не весь блок
+func (v *Vote) Sign(chainID string, priv []byte) error {
+	sig, err := crypto.Sign(priv, voteSigningBytes(chainID, v))
+	if err != nil {
+		return err
+	}
+
+	v.Signature = sig
+	return nil
+}
+
+// SigningBytes байти, які підписує валідатор, коли голосує в мережі chainID
+func (v *Vote) SigningBytes(chainID string) []byte {
+	return voteSigningBytes(chainID, v)
+}
+
+func (v *Vote) Verify(chainID string) error {
+	if v.Type != VotePrevote && v.Type != VotePrecommit {
+		return fmt.Errorf("невідомий тип голосу: %d", v.Type)
+	}
+	return crypto.Verify(v.Pub, voteSigningBytes(chainID, v), v.Signature)
+}
+
+// Proposal блок, який proposer пропонує в раунді Round. Якщо POLRound не -1, це блок, за який
+// більше 2/3 stake вже проголосували (prevote) в раунді POLRound, і proposer пропонує його знову
+type Proposal struct {
+	Round     uint32 `json:"round"`
+	POLRound  int32  `json:"pol_round"`
+	Block     Block  `json:"block"`
 	Pub       []byte `json:"pub"`
 	Signature []byte `json:"signature"`
 }
 
-func (v *Vote) Verify(b *Block) error {
-	blockBytes, err := b.MarshalDeterministic()
+func (p *Proposal) Sign(priv []byte) error {
+	data, err := p.SigningBytes()
 	if err != nil {
 		return err
 	}
 
-	if err = crypto.Verify(v.Pub, blockBytes, v.Signature); err != nil {
+	sig, err := crypto.Sign(priv, data)
+	if err != nil {
 		return err
 	}
+
+	p.Signature = sig
 	return nil
 }
+
+// Verify перевіряє підпис proposal і що Hash блоку відповідає його заголовку
+func (p *Proposal) Verify() error {
+	if p.POLRound < -1 || p.POLRound >= int32(p.Round) {
+		return fmt.Errorf("не правельний pol_round %d для раунду %d", p.POLRound, p.Round)
+	}
+	if p.Block.Round > p.Round {
+		return fmt.Errorf("блок з раунду %d запропоновано в раунді %d", p.Block.Round, p.Round)
+	}
+
+	blockHash, err := p.Block.computeHash()
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(blockHash, p.Block.Hash) {
+		return fmt.Errorf("hash блоку не збігаєтся з заголовком")
+	}
+
+	data, err := p.SigningBytes()
+	if err != nil {
+		return err
+	}
+	return crypto.Verify(p.Pub, data, p.Signature)
+}
+
+// SigningBytes байти, які підписує proposer: висота, раунди і hash блоку
+func (p *Proposal) SigningBytes() ([]byte, error) {
+	blockHash, err := p.Block.computeHash()
+	if err != nil {
+		return nil, err
+	}
+	return proposalSigningBytes(p.Block.ChainID, p.Block.Height, p.Round, p.POLRound, blockHash), nil
+}
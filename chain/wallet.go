@@ -2,6 +2,29 @@ package chain
 
 type Wallet struct {
 	Address []byte `json:"address"`
-	Balance int64  `json:"balance"`
+	Balance int64  `json:"balance"` // в копійках
 	Nonce   uint32 `json:"nonce"`
 }
+
+// Credit додає суму до балансу з перевіркою на переповнення
+func (w *Wallet) Credit(amount int64) error {
+	balance, err := AddAmount(w.Balance, amount)
+	if err != nil {
+		return err
+	}
+	w.Balance = balance
+	return nil
+}
+
+// Debit знімає суму з балансу. Баланс не може стати від'ємним
+func (w *Wallet) Debit(amount int64) error {
+	balance, err := SubAmount(w.Balance, amount)
+	if err != nil {
+		return err
+	}
+	if balance < 0 {
+		return ErrInsufficientFunds
+	}
+	w.Balance = balance
+	return nil
+}
@@ -0,0 +1,212 @@
+// Package config налаштування ноди. Значення беруться по черзі з налаштувань за замовчуванням,
+// json файлу конфігурації, змінних оточення і флагів командного рядка, кожне наступне перезаписує попереднє.
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/rs/zerolog"
+)
+
+type Config struct {
+	DataDir     string   `json:"data_dir"`      // тут база даних і, за замовчуванням, ключі
+	ListenAddrs []string `json:"listen_addrs"`  // multiaddr для p2p
+	APIAddr     string   `json:"api_addr"`      // адреса http API
+	Bootstrap   []string `json:"bootstrap"`     // multiaddr bootstrap нод
+	KeysFile    string   `json:"keys_file"`     // ключі валідатора. відносний шлях рахуєтся від DataDir
+	NodeKeyFile string   `json:"node_key_file"` // libp2p ключ ноди. відносний шлях рахуєтся від DataDir
+	SignState   string   `json:"sign_state"`    // останнє, що підписав валідатор. відносний шлях рахуєтся від DataDir
+	LogLevel    string   `json:"log_level"`     // trace, debug, info, warn, error
+	GenesisFile string   `json:"genesis_file"`  // якщо порожній, використовуєтся genesis тестової мережі
+}
+
+// Змінні оточення
+const (
+	EnvConfig      = "PQLITE_CONFIG"
+	EnvDataDir     = "PQLITE_DATA_DIR"
+	EnvListen      = "PQLITE_LISTEN" // через кому
+	EnvAPI         = "PQLITE_API"
+	EnvBootstrap   = "PQLITE_BOOTSTRAP" // через кому
+	EnvKeysFile    = "PQLITE_KEYS_FILE"
+	EnvNodeKeyFile = "PQLITE_NODE_KEY_FILE"
+	EnvSignState   = "PQLITE_SIGN_STATE"
+	EnvLogLevel    = "PQLITE_LOG_LEVEL"
+	EnvGenesisFile = "PQLITE_GENESIS"
+)
+
+func Default() Config {
+	dataDir := ".pqlite"
+	if home, err := os.UserHomeDir(); err == nil {
+		dataDir = filepath.Join(home, ".pqlite")
+	}
+
+	return Config{
+		DataDir:     dataDir,
+		ListenAddrs: []string{"/ip6/::/tcp/4003", "/ip4/0.0.0.0/tcp/4003"},
+		APIAddr:     ":8081",
+		Bootstrap: []string{
+			"/ip6/2603:c020:8020:57e:0:ad30:3238:982b/tcp/4003/p2p/12D3KooWC3MYiZuijTDt18e29z8Fh1S7ZzkWzU9JCY7kDpm83RMY",
+			"/ip4/158.101.175.51/tcp/4003/p2p/12D3KooWC3MYiZuijTDt18e29z8Fh1S7ZzkWzU9JCY7kDpm83RMY",
+		},
+		KeysFile:    "keys.json",
+		NodeKeyFile: "node.key",
+		SignState:   "sign_state.json",
+		LogLevel:    "debug",
+	}
+}
+
+// Load збирає конфігурацію з args (без назви програми), змінних оточення і файлу,
+// на який вказує -config або PQLITE_CONFIG
+func Load(args []string) (*Config, error) {
+	cfg := Default()
+
+	fs := flag.NewFlagSet("pqlite", flag.ContinueOnError)
+	configPath := fs.String("config", os.Getenv(EnvConfig), "шлях до json файлу конфігурації")
+	dataDir := fs.String("datadir", "", "директорія для бази даних і ключів")
+	listen := fs.String("listen", "", "p2p multiaddr через кому")
+	apiAddr := fs.String("api", "", "адреса http API, наприклад :8081")
+	bootstrap := fs.String("bootstrap", "", "multiaddr bootstrap нод через кому")
+	keysFile := fs.String("keys", "", "файл ключів валідатора")
+	nodeKeyFile := fs.String("node-key", "", "файл libp2p ключа ноди")
+	signState := fs.String("sign-state", "", "файл з останнім, що підписав валідатор")
+	logLevel := fs.String("log-level", "", "рівень логування")
+	genesisFile := fs.String("genesis", "", "json файл genesis")
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	if *configPath != "" {
+		if err := cfg.loadFile(*configPath); err != nil {
+			return nil, err
+		}
+	}
+
+	cfg.loadEnv()
+
+	// флаги перезаписуют тільки якщо їх явно вказали
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "datadir":
+			cfg.DataDir = *dataDir
+		case "listen":
+			cfg.ListenAddrs = splitList(*listen)
+		case "api":
+			cfg.APIAddr = *apiAddr
+		case "bootstrap":
+			cfg.Bootstrap = splitList(*bootstrap)
+		case "keys":
+			cfg.KeysFile = *keysFile
+		case "node-key":
+			cfg.NodeKeyFile = *nodeKeyFile
+		case "sign-state":
+			cfg.SignState = *signState
+		case "log-level":
+			cfg.LogLevel = *logLevel
+		case "genesis":
+			cfg.GenesisFile = *genesisFile
+		}
+	})
+
+	cfg.KeysFile = cfg.resolvePath(cfg.KeysFile)
+	cfg.NodeKeyFile = cfg.resolvePath(cfg.NodeKeyFile)
+	cfg.SignState = cfg.resolvePath(cfg.SignState)
+
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// DBDir директорія бази даних
+func (c *Config) DBDir() string {
+	return filepath.Join(c.DataDir, "db")
+}
+
+// loadFile читає конфігурацію з json файлу. Невідоме поле - помилка, як і в genesis: інакше поле з
+// опечаткою мовчки ігнорується, і нода запускаєтся з налаштуваннями за замовчуванням
+func (c *Config) loadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("помилка читання файлу конфігурації: %w", err)
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err = dec.Decode(c); err != nil {
+		return fmt.Errorf("помилка розпаковки файлу конфігурації: %w", err)
+	}
+	return nil
+}
+
+func (c *Config) loadEnv() {
+	if v, ok := os.LookupEnv(EnvDataDir); ok {
+		c.DataDir = v
+	}
+	if v, ok := os.LookupEnv(EnvListen); ok {
+		c.ListenAddrs = splitList(v)
+	}
+	if v, ok := os.LookupEnv(EnvAPI); ok {
+		c.APIAddr = v
+	}
+	if v, ok := os.LookupEnv(EnvBootstrap); ok {
+		c.Bootstrap = splitList(v)
+	}
+	if v, ok := os.LookupEnv(EnvKeysFile); ok {
+		c.KeysFile = v
+	}
+	if v, ok := os.LookupEnv(EnvNodeKeyFile); ok {
+		c.NodeKeyFile = v
+	}
+	if v, ok := os.LookupEnv(EnvSignState); ok {
+		c.SignState = v
+	}
+	if v, ok := os.LookupEnv(EnvLogLevel); ok {
+		c.LogLevel = v
+	}
+	if v, ok := os.LookupEnv(EnvGenesisFile); ok {
+		c.GenesisFile = v
+	}
+}
+
+func (c *Config) resolvePath(path string) string {
+	if path == "" || filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(c.DataDir, path)
+}
+
+func (c *Config) validate() error {
+	if c.DataDir == "" {
+		return errors.New("data_dir не може бути порожнім")
+	}
+	if len(c.ListenAddrs) == 0 {
+		return errors.New("потрібна хоча б одна адреса в listen_addrs")
+	}
+	if c.APIAddr == "" {
+		return errors.New("api_addr не може бути порожнім")
+	}
+	if c.KeysFile == "" || c.NodeKeyFile == "" || c.SignState == "" {
+		return errors.New("keys_file, node_key_file і sign_state не можуть бути порожніми")
+	}
+	if _, err := zerolog.ParseLevel(c.LogLevel); err != nil {
+		return fmt.Errorf("не правельний log_level: %w", err)
+	}
+	return nil
+}
+
+func splitList(s string) []string {
+	var res []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			res = append(res, item)
+		}
+	}
+	return res
+}
@@ -0,0 +1,44 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		wantErr bool
+	}{
+		{"всі поля", `{"data_dir": "/data", "api_addr": ":9000", "sign_state": "sign.json"}`, false},
+		{"порожній обʼєкт", `{}`, false},
+		{"невідоме поле", `{"data_dir": "/data", "unknown": 1}`, true},
+		{"опечатка в назві поля", `{"keys_fle": "keys.json"}`, true},
+		{"не json", `data_dir=/data`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config.json")
+			if err := os.WriteFile(path, []byte(tt.data), 0600); err != nil {
+				t.Fatal(err)
+			}
+			cfg := Default()
+			if err := cfg.loadFile(path); (err != nil) != tt.wantErr {
+				t.Errorf("помилка %v, очікувалась помилка: %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// config.example.json має завантажуватись без помилок, тобто містити тільки відомі поля
+func TestLoadExample(t *testing.T) {
+	cfg, err := Load([]string{"-config", "../config.example.json", "-log-level", "debug"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.DataDir != "/var/lib/pqlite/node1" || cfg.KeysFile != "/var/lib/pqlite/node1/keys.json" || cfg.SignState != "/var/lib/pqlite/node1/sign_state.json" {
+		t.Errorf("конфігурація з прикладу: %+v", cfg)
+	}
+}
@@ -3,22 +3,41 @@
 package database
 
 import (
+	"bytes"
+	"encoding/binary"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"strconv"
-	"strings"
+	"math"
 
 	"github.com/PQlite/core/chain"
 	"github.com/dgraph-io/badger/v4"
 	"github.com/dgraph-io/badger/v4/options"
 )
 
+var (
+	// blockPrefix блоки зберігаются під ключем blockPrefix + висота (uint32 big-endian),
+	// тому badger ітерує їх в порядку висоти
+	blockPrefix = []byte("block:")
+	// commitPrefix сертифікат прийняття блоку під ключем commitPrefix + висота (uint32 big-endian)
+	commitPrefix = []byte("commit:")
+	// lastHeightKey висота останнього застосованого блоку (uint32 big-endian)
+	lastHeightKey = []byte("lastHeight")
+
+	ErrNoBlocks = errors.New("no blocks found")
+	// ErrNoCommit блок є, але сертифікату для нього немає (genesis блок)
+	ErrNoCommit = errors.New("commit not found")
+	// ErrTxNotFound транзакції з таким hash немає в жодному блоці
+	ErrTxNotFound = errors.New("transaction not found")
+)
+
 type BlockStorage struct {
 	db *badger.DB
 }
 
-func InitDB() (*BlockStorage, error) {
-	opts := badger.DefaultOptions("/tmp/badger")
+// InitDB відкриває (або створює) базу даних в директорії dir
+func InitDB(dir string) (*BlockStorage, error) {
+	opts := badger.DefaultOptions(dir)
 	opts.Compression = options.Snappy
 	db, err := badger.Open(opts)
 	bs := &BlockStorage{db: db}
@@ -32,115 +51,147 @@ func (bs *BlockStorage) Close() error {
 	return bs.db.Close()
 }
 
-func (bs *BlockStorage) SaveBlock(block *chain.Block) error {
-	height := fmt.Sprintf("block:%d", block.Height)
+// ApplyBlock однією badger транзакцією зберігає блок, його сертифікат, вказівник на останню висоту,
+// індекс транзакцій і всі зміни стану (гаманці, валідатори) з st. Якщо нода впаде, то або буде збережено
+// все, або нічого. cert nil тільки для genesis блоку. Після ApplyBlock st вже не можна використовувати
+func (bs *BlockStorage) ApplyBlock(block *chain.Block, cert *chain.CommitCertificate, st *StateTxn) error {
+	// дерево стану має бути оновлене до останньої зміни, інакше збережений корінь не буде коренем збереженого стану
+	stateRoot, err := st.StateRoot()
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(stateRoot, block.StateRoot) {
+		return fmt.Errorf("state root блоку %d не збігаєтся зі станом, який зберігаєтся", block.Height)
+	}
+
 	data, err := json.Marshal(block)
 	if err != nil {
 		return err
 	}
 
-	txn := bs.db.NewTransaction(true)
-	defer txn.Discard()
+	if err = st.txn.Set(getBlockKey(block.Height), data); err != nil {
+		return err
+	}
 
-	err = txn.Set([]byte(height), data)
-	if err != nil {
+	if cert != nil {
+		certData, err := json.Marshal(cert)
+		if err != nil {
+			return err
+		}
+		if err = st.txn.Set(getCommitKey(block.Height), certData); err != nil {
+			return err
+		}
+	}
+
+	if err = st.txn.Set(lastHeightKey, binary.BigEndian.AppendUint32(nil, block.Height)); err != nil {
 		return err
 	}
 
-	if err = txn.Commit(); err != nil {
+	if err = indexBlockTxs(st.txn, block); err != nil {
 		return err
 	}
-	return bs.db.Sync()
+
+	return st.commit()
 }
 
 func (bs *BlockStorage) GetBlock(height uint32) (*chain.Block, error) {
-	var block chain.Block
-	key := fmt.Sprintf("block:%d", height)
+	var block *chain.Block
 
 	err := bs.db.View(func(txn *badger.Txn) error {
-		data, err := txn.Get([]byte(key))
+		var err error
+		block, err = getBlock(txn, height)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return block, nil
+}
+
+// GetCommit повертає сертифікат прийняття блоку на висоті height, або ErrNoCommit для genesis
+func (bs *BlockStorage) GetCommit(height uint32) (*chain.CommitCertificate, error) {
+	var cert chain.CommitCertificate
+
+	err := bs.db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(getCommitKey(height))
+		if isNotFound(err) {
+			return ErrNoCommit
+		}
 		if err != nil {
 			return err
 		}
 
-		return data.Value(func(val []byte) error {
-			return json.Unmarshal(val, &block)
+		return item.Value(func(val []byte) error {
+			return json.Unmarshal(val, &cert)
 		})
 	})
 	if err != nil {
 		return nil, err
 	}
 
-	return &block, nil
+	return &cert, nil
 }
 
+// GetLastBlock повертає блок, на який вказує lastHeightKey, або ErrNoBlocks, якщо база порожня
 func (bs *BlockStorage) GetLastBlock() (*chain.Block, error) {
 	var lastBlock *chain.Block
-	var maxBlockNumber int64 = -1
 
 	err := bs.db.View(func(txn *badger.Txn) error {
-		opts := badger.DefaultIteratorOptions
-		opts.Prefix = []byte("block:")
-		it := txn.NewIterator(opts)
-		defer it.Close()
-
-		for it.Rewind(); it.Valid(); it.Next() {
-			item := it.Item()
-			key := item.Key()
-			keyStr := string(key)
-
-			numStr := strings.TrimPrefix(keyStr, "block:")
-			blockNumber, err := strconv.ParseInt(numStr, 10, 64)
-			if err != nil {
-				continue
-			}
-
-			if blockNumber > maxBlockNumber {
-				maxBlockNumber = blockNumber
-			}
-		}
+		var err error
+		lastBlock, err = getLastBlock(txn)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
 
-		if maxBlockNumber != -1 {
-			keyToFetch := []byte("block:" + strconv.FormatInt(maxBlockNumber, 10))
-			item, err := txn.Get(keyToFetch)
-			if err != nil {
-				return err
-			}
+	return lastBlock, nil
+}
 
-			return item.Value(func(val []byte) error {
-				var block chain.Block
-				if err := json.Unmarshal(val, &block); err != nil {
-					return err
-				}
-				lastBlock = &block
-				return nil
-			})
-		}
+func getLastBlock(txn *badger.Txn) (*chain.Block, error) {
+	item, err := txn.Get(lastHeightKey)
+	if isNotFound(err) {
+		return nil, ErrNoBlocks
+	}
+	if err != nil {
+		return nil, err
+	}
 
+	var height uint32
+	err = item.Value(func(val []byte) error {
+		height = binary.BigEndian.Uint32(val)
 		return nil
 	})
 	if err != nil {
 		return nil, err
 	}
 
-	if lastBlock == nil {
-		return nil, fmt.Errorf("no blocks found")
-	}
-
-	return lastBlock, nil
+	return getBlock(txn, height)
 }
 
+// GetAllBlocks повертає всі блоки в порядку зростання висоти
 func (bs *BlockStorage) GetAllBlocks() ([]*chain.Block, error) {
+	return bs.GetBlocksRange(0, math.MaxUint32)
+}
+
+// GetBlocksRange повертає блоки з висотою від from до to включно, в порядку зростання висоти
+func (bs *BlockStorage) GetBlocksRange(from uint32, to uint32) ([]*chain.Block, error) {
 	var blocks []*chain.Block
 
 	err := bs.db.View(func(txn *badger.Txn) error {
 		opts := badger.DefaultIteratorOptions
-		opts.Prefix = []byte("block:")
+		opts.Prefix = blockPrefix
 		it := txn.NewIterator(opts)
 		defer it.Close()
 
-		for it.Rewind(); it.Valid(); it.Next() {
+		toKey := getBlockKey(to)
+		for it.Seek(getBlockKey(from)); it.Valid(); it.Next() {
 			item := it.Item()
+			if bytes.Compare(item.Key(), toKey) > 0 {
+				break
+			}
+
 			err := item.Value(func(val []byte) error {
 				var block chain.Block
 				if err := json.Unmarshal(val, &block); err != nil {
@@ -161,3 +212,27 @@ func (bs *BlockStorage) GetAllBlocks() ([]*chain.Block, error) {
 
 	return blocks, nil
 }
+
+func getBlock(txn *badger.Txn, height uint32) (*chain.Block, error) {
+	item, err := txn.Get(getBlockKey(height))
+	if err != nil {
+		return nil, err
+	}
+
+	var block chain.Block
+	err = item.Value(func(val []byte) error {
+		return json.Unmarshal(val, &block)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &block, nil
+}
+
+func getBlockKey(height uint32) []byte {
+	return binary.BigEndian.AppendUint32(append([]byte{}, blockPrefix...), height)
+}
+
+func getCommitKey(height uint32) []byte {
+	return binary.BigEndian.AppendUint32(append([]byte{}, commitPrefix...), height)
+}
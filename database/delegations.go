@@ -0,0 +1,99 @@
+package database
+
+import (
+	"bytes"
+
+	"github.com/PQlite/core/chain"
+	"github.com/dgraph-io/badger/v4"
+)
+
+// delegationPrefix делегації. Ключ - d_<валідатор><делегатор>, тому всі делегації валідатора йдуть підряд
+var delegationPrefix = []byte("d_")
+
+func getDelegations(txn *badger.Txn, prefix []byte) ([]chain.Delegation, error) {
+	var res []chain.Delegation
+
+	opts := badger.DefaultIteratorOptions
+	opts.Prefix = prefix
+
+	it := txn.NewIterator(opts)
+	defer it.Close()
+
+	for it.Rewind(); it.Valid(); it.Next() {
+		err := it.Item().Value(func(v []byte) error {
+			var delegation chain.Delegation
+			if err := delegation.UnmarshalBinary(v); err != nil {
+				return err
+			}
+			res = append(res, delegation)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+// GetDelegation повертає nil без помилки, якщо делегації немає
+func (s *StateTxn) GetDelegation(validator []byte, delegator []byte) (*chain.Delegation, error) {
+	item, err := s.txn.Get(getDelegationKey(validator, delegator))
+	if isNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var delegation chain.Delegation
+	err = item.Value(func(val []byte) error {
+		return delegation.UnmarshalBinary(val)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &delegation, nil
+}
+
+// GetDelegations всі делегації валідатора в порядку адрес делегаторів
+func (s *StateTxn) GetDelegations(validator []byte) ([]chain.Delegation, error) {
+	return getDelegations(s.txn, getDelegationKey(validator, nil))
+}
+
+// SetDelegation зберігає делегацію. Делегація без Shares видаляєтся
+func (s *StateTxn) SetDelegation(delegation *chain.Delegation) error {
+	key := getDelegationKey(delegation.Validator, delegation.Delegator)
+	if delegation.Shares == 0 {
+		return s.delete(key)
+	}
+	data, err := delegation.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	return s.set(key, data)
+}
+
+// GetDelegatorDelegations всі делегації адреси. Проходить по всіх делегаціях, тому тільки для API
+func (bs *BlockStorage) GetDelegatorDelegations(delegator []byte) ([]chain.Delegation, error) {
+	var res []chain.Delegation
+
+	err := bs.db.View(func(txn *badger.Txn) error {
+		all, err := getDelegations(txn, delegationPrefix)
+		if err != nil {
+			return err
+		}
+		for _, d := range all {
+			if bytes.Equal(d.Delegator, delegator) {
+				res = append(res, d)
+			}
+		}
+		return nil
+	})
+	return res, err
+}
+
+// додає d_ до адрес валідатора і делегатора
+func getDelegationKey(validator []byte, delegator []byte) []byte {
+	key := append(append([]byte{}, delegationPrefix...), validator...)
+	return append(key, delegator...)
+}
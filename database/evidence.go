@@ -0,0 +1,27 @@
+package database
+
+import (
+	"github.com/PQlite/core/chain"
+)
+
+// evidencePrefix докази, які вже потрапили в блок. Зберігаются в стані, щоб той самий доказ
+// не покарав валідатора вдруге
+var evidencePrefix = []byte("ev_")
+
+// HasEvidence чи доказ вже був в якомусь блоці
+func (s *StateTxn) HasEvidence(e *chain.Evidence) (bool, error) {
+	_, err := s.txn.Get(getEvidenceKey(e.Hash()))
+	if isNotFound(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (s *StateTxn) SetEvidence(e *chain.Evidence) error {
+	return s.set(getEvidenceKey(e.Hash()), e.MarshalDeterministic())
+}
+
+// додає ev_ до hash доказу
+func getEvidenceKey(hash []byte) []byte {
+	return append(append([]byte{}, evidencePrefix...), hash...)
+}
@@ -0,0 +1,103 @@
+package database
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/PQlite/core/chain"
+	"github.com/dgraph-io/badger/v4"
+)
+
+// InitGenesis записує стан і блок з genesis, якщо база порожня. Якщо в базі вже є блоки,
+// перевіряє, що її genesis блок такий самий, як з g, щоб нода не працювала з базою іншої мережі
+func (bs *BlockStorage) InitGenesis(g *chain.Genesis) (*chain.Block, error) {
+	_, err := bs.GetLastBlock()
+	if errors.Is(err, ErrNoBlocks) {
+		return bs.createGenesis(g)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	stored, err := bs.GetBlock(0)
+	if err != nil {
+		return nil, fmt.Errorf("помилка отримання genesis блоку: %w", err)
+	}
+
+	expected, err := GenesisBlock(g)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(stored.Hash, expected.Hash) {
+		return nil, fmt.Errorf("genesis блок в базі (%x) не збігаєтся з genesis файлом (%x)", stored.Hash, expected.Hash)
+	}
+
+	return stored, nil
+}
+
+// GenesisBlock рахує genesis блок для g, не змінюючи жодної бази. Для цього стан записуєтся в тимчасову базу в памʼяті
+func GenesisBlock(g *chain.Genesis) (*chain.Block, error) {
+	db, err := badger.Open(badger.DefaultOptions("").WithInMemory(true).WithLogger(nil))
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	bs := &BlockStorage{db: db}
+	st := bs.NewStateTxn()
+	defer st.Discard()
+
+	return writeGenesisState(st, g)
+}
+
+func (bs *BlockStorage) createGenesis(g *chain.Genesis) (*chain.Block, error) {
+	st := bs.NewStateTxn()
+	defer st.Discard()
+
+	b, err := writeGenesisState(st, g)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = bs.ApplyBlock(b, nil, st); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// writeGenesisState записує в st стан з genesis і повертає genesis блок з його state root
+func writeGenesisState(st *StateTxn, g *chain.Genesis) (*chain.Block, error) {
+	for _, wallet := range g.Wallets() {
+		if err := st.SetWallet(&wallet); err != nil {
+			return nil, err
+		}
+	}
+	for _, validator := range g.ValidatorSet() {
+		if err := st.SetValidator(&validator); err != nil {
+			return nil, err
+		}
+	}
+	for _, delegation := range g.Delegations() {
+		if err := st.SetDelegation(&delegation); err != nil {
+			return nil, err
+		}
+	}
+	if err := st.SetValidatorSet(chain.NewValidatorSet(g.ValidatorSet())); err != nil {
+		return nil, err
+	}
+	if err := st.SetParams(&g.Params); err != nil {
+		return nil, err
+	}
+	// до першого reveal RandaoMix - нулі
+	if err := st.SetRandaoMix(make([]byte, chain.RandaoSize)); err != nil {
+		return nil, err
+	}
+
+	stateRoot, err := st.StateRoot()
+	if err != nil {
+		return nil, err
+	}
+
+	return g.Block(stateRoot)
+}
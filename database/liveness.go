@@ -0,0 +1,45 @@
+package database
+
+import (
+	"github.com/PQlite/core/chain"
+)
+
+// livenessPrefix пропуски валідаторів за останнє вікно висот
+var livenessPrefix = []byte("live_")
+
+// GetLiveness повертає nil без помилки, якщо пропусків валідатора ще не записано
+func (s *StateTxn) GetLiveness(addr []byte) (*chain.LivenessInfo, error) {
+	item, err := s.txn.Get(getLivenessKey(addr))
+	if isNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var info chain.LivenessInfo
+	err = item.Value(func(val []byte) error {
+		return info.UnmarshalBinary(val)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+func (s *StateTxn) SetLiveness(info *chain.LivenessInfo) error {
+	data, err := info.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	return s.set(getLivenessKey(info.Address), data)
+}
+
+func (s *StateTxn) DeleteLiveness(addr []byte) error {
+	return s.delete(getLivenessKey(addr))
+}
+
+// додає live_ до адреси
+func getLivenessKey(a []byte) []byte {
+	return append(append([]byte{}, livenessPrefix...), a...)
+}
@@ -0,0 +1,40 @@
+package database
+
+import (
+	"github.com/PQlite/core/chain"
+	"github.com/dgraph-io/badger/v4"
+)
+
+// paramsKey параметри консенсусу, записуются з genesis
+var paramsKey = []byte("params")
+
+func getParams(txn *badger.Txn) (*chain.ConsensusParams, error) {
+	item, err := txn.Get(paramsKey)
+	if err != nil {
+		return nil, err
+	}
+
+	var params chain.ConsensusParams
+	err = item.Value(func(val []byte) error {
+		return params.UnmarshalBinary(val)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &params, nil
+}
+
+func (bs *BlockStorage) GetParams() (*chain.ConsensusParams, error) {
+	var params *chain.ConsensusParams
+
+	err := bs.db.View(func(txn *badger.Txn) error {
+		var err error
+		params, err = getParams(txn)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return params, nil
+}
@@ -0,0 +1,90 @@
+package database
+
+import (
+	"github.com/PQlite/core/chain"
+	"github.com/dgraph-io/badger/v4"
+)
+
+// randaoMixKey RandaoMix після останнього виконаного блоку, з нього вибираєтся proposer наступного
+// randaoCommitPrefix hash секрету, який кожен валідатор відкриє в своєму наступному блоці
+var (
+	randaoMixKey       = []byte("randao")
+	randaoCommitPrefix = []byte("rc_")
+)
+
+func getRandaoMix(txn *badger.Txn) ([]byte, error) {
+	item, err := txn.Get(randaoMixKey)
+	if err != nil {
+		return nil, err
+	}
+	return item.ValueCopy(nil)
+}
+
+func (s *StateTxn) GetRandaoMix() ([]byte, error) {
+	return getRandaoMix(s.txn)
+}
+
+func (s *StateTxn) SetRandaoMix(mix []byte) error {
+	return s.set(randaoMixKey, mix)
+}
+
+// GetRandaoMix RandaoMix після останнього виконаного блоку
+func (bs *BlockStorage) GetRandaoMix() ([]byte, error) {
+	var mix []byte
+
+	err := bs.db.View(func(txn *badger.Txn) error {
+		var err error
+		mix, err = getRandaoMix(txn)
+		return err
+	})
+	return mix, err
+}
+
+// getRandaoCommit повертає nil без помилки, якщо валідатор ще не створив жодного блоку
+func getRandaoCommit(txn *badger.Txn, addr []byte) (*chain.RandaoCommit, error) {
+	item, err := txn.Get(getRandaoCommitKey(addr))
+	if isNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var commit chain.RandaoCommit
+	err = item.Value(func(val []byte) error {
+		return commit.UnmarshalBinary(val)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &commit, nil
+}
+
+func (s *StateTxn) GetRandaoCommit(addr []byte) (*chain.RandaoCommit, error) {
+	return getRandaoCommit(s.txn, addr)
+}
+
+func (s *StateTxn) SetRandaoCommit(commit *chain.RandaoCommit) error {
+	data, err := commit.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	return s.set(getRandaoCommitKey(commit.Address), data)
+}
+
+// GetRandaoCommit повертає nil без помилки, якщо валідатор ще не створив жодного блоку
+func (bs *BlockStorage) GetRandaoCommit(addr []byte) (*chain.RandaoCommit, error) {
+	var commit *chain.RandaoCommit
+
+	err := bs.db.View(func(txn *badger.Txn) error {
+		var err error
+		commit, err = getRandaoCommit(txn, addr)
+		return err
+	})
+	return commit, err
+}
+
+// додає rc_ до адреси
+func getRandaoCommitKey(a []byte) []byte {
+	return append(append([]byte{}, randaoCommitPrefix...), a...)
+}
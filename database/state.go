@@ -1,54 +1,45 @@
 package database
 
 import (
-	"encoding/json"
-
 	"github.com/PQlite/core/chain"
 	"github.com/dgraph-io/badger/v4"
 )
 
 var walletPrefix = []byte("wallet")
 
-func (bs *BlockStorage) GetWalletByAddress(addr []byte) (chain.Wallet, error) {
-	// TODO: треба щоб повертала структура wallet, якщо є помилка "Key not found"
-	var wallet chain.Wallet
-	key := append(walletPrefix, addr...)
-
-	if err := bs.db.View(func(txn *badger.Txn) error {
-		data, err := txn.Get(key)
-		if err != nil {
-			return err
-		}
-
-		return data.Value(func(val []byte) error {
-			return json.Unmarshal(val, &wallet)
-		})
-	}); err != nil {
-		if err.Error() == "Key not found" {
-			return chain.Wallet{
-				Address: addr,
-				Balance: 0,
-				Nonce:   0,
-			}, nil
-		}
-		return wallet, err
-	}
-
-	return wallet, nil
+func getWalletKey(addr []byte) []byte {
+	return append(append([]byte{}, walletPrefix...), addr...)
 }
 
-func (bs *BlockStorage) UpdateBalance(wallet *chain.Wallet) error {
-	key := append(walletPrefix, wallet.Address...)
-	data, err := json.Marshal(wallet)
+// getWallet повертає гаманець з адресою addr. Якщо гаманця ще немає, повертає порожній
+func getWallet(txn *badger.Txn, addr []byte) (chain.Wallet, error) {
+	item, err := txn.Get(getWalletKey(addr))
+	if isNotFound(err) {
+		return chain.Wallet{
+			Address: addr,
+			Balance: 0,
+			Nonce:   0,
+		}, nil
+	}
 	if err != nil {
-		return err
+		return chain.Wallet{}, err
 	}
 
-	if err := bs.db.Update(func(txn *badger.Txn) error {
-		return txn.Set(key, data)
-	}); err != nil {
+	var wallet chain.Wallet
+	err = item.Value(func(val []byte) error {
+		return wallet.UnmarshalBinary(val)
+	})
+	return wallet, err
+}
+
+func (bs *BlockStorage) GetWalletByAddress(addr []byte) (chain.Wallet, error) {
+	var wallet chain.Wallet
+
+	err := bs.db.View(func(txn *badger.Txn) error {
+		var err error
+		wallet, err = getWallet(txn, addr)
 		return err
-	}
+	})
 
-	return bs.db.Sync()
+	return wallet, err
 }
@@ -0,0 +1,244 @@
+package database
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+
+	"github.com/PQlite/core/chain"
+	"github.com/dgraph-io/badger/v4"
+)
+
+var (
+	// stateNodePrefix вузли дерева стану під ключем stateNodePrefix + hash вузла. Значення - те, з чого рахуєтся hash:
+	// 0x00 || hash ключа || hash значення для листка, 0x01 || лівий || правий для вузла.
+	// Старі вузли не видаляются, тому дерево кожного попереднього state root теж залишаєтся в базі
+	stateNodePrefix = []byte("smt:")
+	// stateRootKey корінь дерева стану після останнього застосованого блоку
+	stateRootKey = []byte("stateRoot")
+)
+
+// stateTree sparse Merkle дерево стану (див. chain/state_proof.go) у badger транзакції.
+// Змінюются тільки вузли на шляхах змінених ключів, тому блок коштує O(змін * log(стан)), а не O(стан)
+type stateTree struct {
+	txn *badger.Txn
+}
+
+// stateNode вузол дерева. Для листка left - hash ключа, right - hash значення
+type stateNode struct {
+	leaf  bool
+	left  []byte
+	right []byte
+}
+
+// stateChange зміна одного ключа стану. Порожній valueHash - ключ видалено
+type stateChange struct {
+	keyHash   []byte
+	valueHash []byte
+}
+
+func isEmptyStateHash(hash []byte) bool {
+	return bytes.Equal(hash, chain.EmptyStateRoot())
+}
+
+func getStateRoot(txn *badger.Txn) ([]byte, error) {
+	item, err := txn.Get(stateRootKey)
+	if isNotFound(err) {
+		return chain.EmptyStateRoot(), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return item.ValueCopy(nil)
+}
+
+func (t *stateTree) getNode(hash []byte) (*stateNode, error) {
+	item, err := t.txn.Get(append(append([]byte{}, stateNodePrefix...), hash...))
+	if err != nil {
+		return nil, fmt.Errorf("вузол дерева стану %x: %w", hash, err)
+	}
+	data, err := item.ValueCopy(nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) != 65 || data[0] > 1 {
+		return nil, fmt.Errorf("не правельне кодування вузла дерева стану %x", hash)
+	}
+	return &stateNode{leaf: data[0] == 0, left: data[1:33], right: data[33:]}, nil
+}
+
+func (t *stateTree) putNode(n *stateNode) ([]byte, error) {
+	var hash []byte
+	var kind byte
+	if n.leaf {
+		hash = chain.StateLeafHash(n.left, n.right)
+	} else {
+		hash = chain.StateNodeHash(n.left, n.right)
+		kind = 1
+	}
+
+	data := make([]byte, 0, 65)
+	data = append(data, kind)
+	data = append(data, n.left...)
+	data = append(data, n.right...)
+	if err := t.txn.Set(append(append([]byte{}, stateNodePrefix...), hash...), data); err != nil {
+		return nil, err
+	}
+	return hash, nil
+}
+
+// update застосовує changes до піддерева hash на глибині depth і повертає нове піддерево і чи воно листок.
+// changes відсортовані за keyHash і всі лежать в цьому піддереві
+func (t *stateTree) update(hash []byte, depth int, changes []stateChange) ([]byte, bool, error) {
+	if len(changes) == 0 {
+		if isEmptyStateHash(hash) {
+			return hash, false, nil
+		}
+		n, err := t.getNode(hash)
+		if err != nil {
+			return nil, false, err
+		}
+		return hash, n.leaf, nil
+	}
+
+	if isEmptyStateHash(hash) {
+		return t.build(depth, changes)
+	}
+	n, err := t.getNode(hash)
+	if err != nil {
+		return nil, false, err
+	}
+
+	// листок, який ще не змінено, будуєтся разом зі змінами, як ще одна зміна
+	if n.leaf {
+		i := sort.Search(len(changes), func(i int) bool { return bytes.Compare(changes[i].keyHash, n.left) >= 0 })
+		if i == len(changes) || !bytes.Equal(changes[i].keyHash, n.left) {
+			changes = append(changes[:i:i], append([]stateChange{{keyHash: n.left, valueHash: n.right}}, changes[i:]...)...)
+		}
+		return t.build(depth, changes)
+	}
+
+	split := splitStateChanges(changes, depth)
+	left, leftLeaf, err := t.update(n.left, depth+1, changes[:split])
+	if err != nil {
+		return nil, false, err
+	}
+	right, rightLeaf, err := t.update(n.right, depth+1, changes[split:])
+	if err != nil {
+		return nil, false, err
+	}
+	return t.join(left, leftLeaf, right, rightLeaf)
+}
+
+// build будує піддерево на глибині depth тільки з changes. Видалені ключі пропускаются
+func (t *stateTree) build(depth int, changes []stateChange) ([]byte, bool, error) {
+	leaves := make([]stateChange, 0, len(changes))
+	for _, c := range changes {
+		if len(c.valueHash) != 0 {
+			leaves = append(leaves, c)
+		}
+	}
+
+	switch len(leaves) {
+	case 0:
+		return chain.EmptyStateRoot(), false, nil
+	case 1:
+		hash, err := t.putNode(&stateNode{leaf: true, left: leaves[0].keyHash, right: leaves[0].valueHash})
+		return hash, true, err
+	}
+
+	split := splitStateChanges(leaves, depth)
+	left, leftLeaf, err := t.build(depth+1, leaves[:split])
+	if err != nil {
+		return nil, false, err
+	}
+	right, rightLeaf, err := t.build(depth+1, leaves[split:])
+	if err != nil {
+		return nil, false, err
+	}
+	return t.join(left, leftLeaf, right, rightLeaf)
+}
+
+// join вузол з двох піддерев. Якщо одне з них порожнє, а інше листок, то вузла немає, а листок піднімаєтся вище
+func (t *stateTree) join(left []byte, leftLeaf bool, right []byte, rightLeaf bool) ([]byte, bool, error) {
+	leftEmpty, rightEmpty := isEmptyStateHash(left), isEmptyStateHash(right)
+	switch {
+	case leftEmpty && rightEmpty:
+		return left, false, nil
+	case leftEmpty && rightLeaf:
+		return right, true, nil
+	case rightEmpty && leftLeaf:
+		return left, true, nil
+	}
+	hash, err := t.putNode(&stateNode{left: left, right: right})
+	return hash, false, err
+}
+
+// splitStateChanges індекс першої зміни, шлях якої на глибині depth йде вправо
+func splitStateChanges(changes []stateChange, depth int) int {
+	return sort.Search(len(changes), func(i int) bool { return chain.StateKeyBit(changes[i].keyHash, depth) == 1 })
+}
+
+// proof доказ значення key (або його відсутності) в дереві з коренем root. Значення береться з txn,
+// тому root має бути коренем стану цієї транзакції
+func (t *stateTree) proof(root []byte, key []byte) (*chain.StateProof, error) {
+	keyHash := chain.StateKeyHash(key)
+	proof := &chain.StateProof{Key: key}
+
+	hash := root
+	for depth := 0; !isEmptyStateHash(hash); depth++ {
+		n, err := t.getNode(hash)
+		if err != nil {
+			return nil, err
+		}
+
+		if n.leaf {
+			if !bytes.Equal(n.left, keyHash) {
+				proof.OtherKeyHash, proof.OtherValueHash = n.left, n.right
+				return proof, nil
+			}
+			item, err := t.txn.Get(key)
+			if err != nil {
+				return nil, err
+			}
+			if proof.Value, err = item.ValueCopy(nil); err != nil {
+				return nil, err
+			}
+			return proof, nil
+		}
+
+		if chain.StateKeyBit(keyHash, depth) == 0 {
+			proof.Siblings = append(proof.Siblings, n.right)
+			hash = n.left
+		} else {
+			proof.Siblings = append(proof.Siblings, n.left)
+			hash = n.right
+		}
+	}
+	return proof, nil
+}
+
+// GetWalletProof повертає останній блок і доказ гаманця addr в стані після нього. Перевірити доказ можна через
+// chain.VerifyStateProof з StateRoot блоку, а сам блок - через його сертифікат
+func (bs *BlockStorage) GetWalletProof(addr []byte) (*chain.Block, *chain.StateProof, error) {
+	var block *chain.Block
+	var proof *chain.StateProof
+
+	err := bs.db.View(func(txn *badger.Txn) error {
+		var err error
+		if block, err = getLastBlock(txn); err != nil {
+			return err
+		}
+		root, err := getStateRoot(txn)
+		if err != nil {
+			return err
+		}
+		t := &stateTree{txn: txn}
+		proof, err = t.proof(root, getWalletKey(addr))
+		return err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return block, proof, nil
+}
@@ -0,0 +1,351 @@
+package database
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/PQlite/core/chain"
+	"github.com/dgraph-io/badger/v4"
+)
+
+func testStorage(t *testing.T) *BlockStorage {
+	t.Helper()
+	// не в памʼяті, тому що commit робить Sync, а в базі в памʼяті його немає
+	db, err := badger.Open(badger.DefaultOptions(t.TempDir()).WithLogger(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return &BlockStorage{db: db}
+}
+
+// stateOp зміна ключа стану, порожнє value - видалення
+type stateOp struct {
+	key   string
+	value string
+}
+
+func testKeys(n int) []stateOp {
+	ops := make([]stateOp, n)
+	for i := range ops {
+		ops[i] = stateOp{key: fmt.Sprintf("wallet%03d", i), value: fmt.Sprintf("balance %d", i)}
+	}
+	return ops
+}
+
+// applyOps виконує зміни пачками по batch і після кожної пачки оновлює дерево
+func applyOps(t *testing.T, st *StateTxn, ops []stateOp, batch int) []byte {
+	t.Helper()
+	var root []byte
+	for i, op := range ops {
+		var err error
+		if op.value == "" {
+			err = st.delete([]byte(op.key))
+		} else {
+			err = st.set([]byte(op.key), []byte(op.value))
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if (i+1)%batch == 0 {
+			if root, err = st.StateRoot(); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	root, err := st.StateRoot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return root
+}
+
+// finalState стан після ops: ключ -> значення
+func finalState(ops []stateOp) map[string]string {
+	state := make(map[string]string)
+	for _, op := range ops {
+		if op.value == "" {
+			delete(state, op.key)
+		} else {
+			state[op.key] = op.value
+		}
+	}
+	return state
+}
+
+// rootFromScratch корінь стану state, побудованого в новій базі однією пачкою
+func rootFromScratch(t *testing.T, state map[string]string) []byte {
+	t.Helper()
+	st := testStorage(t).NewStateTxn()
+	defer st.Discard()
+
+	var ops []stateOp
+	for k, v := range state {
+		ops = append(ops, stateOp{k, v})
+	}
+	return applyOps(t, st, ops, len(ops)+1)
+}
+
+func TestStateRootDeterministic(t *testing.T) {
+	keys := testKeys(50)
+
+	var overwrite []stateOp
+	overwrite = append(overwrite, keys...)
+	for _, op := range keys[:20] {
+		overwrite = append(overwrite, stateOp{op.key, op.value + " new"})
+	}
+
+	var deleteSome []stateOp
+	deleteSome = append(deleteSome, keys...)
+	for _, op := range keys[10:40] {
+		deleteSome = append(deleteSome, stateOp{key: op.key})
+	}
+
+	var deleteAll []stateOp
+	deleteAll = append(deleteAll, keys...)
+	for _, op := range keys {
+		deleteAll = append(deleteAll, stateOp{key: op.key})
+	}
+
+	tests := []struct {
+		name string
+		ops  []stateOp
+	}{
+		{"один ключ", keys[:1]},
+		{"два ключі", keys[:2]},
+		{"багато ключів", keys},
+		{"перезапис значень", overwrite},
+		{"видалення частини ключів", deleteSome},
+		{"видалення всіх ключів", deleteAll},
+		{"видалення ключа, якого немає", append([]stateOp{{key: "wallet999"}}, keys[:5]...)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want := rootFromScratch(t, finalState(tt.ops))
+			for _, batch := range []int{1, 3, 7, len(tt.ops) + 1} {
+				st := testStorage(t).NewStateTxn()
+				if got := applyOps(t, st, tt.ops, batch); !bytes.Equal(got, want) {
+					t.Errorf("пачки по %d: корінь %x, очікувалось %x", batch, got, want)
+				}
+				st.Discard()
+			}
+
+			// порядок змін різних ключів не впливає на корінь
+			shuffled := finalStateOps(tt.ops)
+			rand.New(rand.NewSource(1)).Shuffle(len(shuffled), func(i, j int) { shuffled[i], shuffled[j] = shuffled[j], shuffled[i] })
+			st := testStorage(t).NewStateTxn()
+			defer st.Discard()
+			if got := applyOps(t, st, shuffled, 2); !bytes.Equal(got, want) {
+				t.Errorf("перемішаний порядок: корінь %x, очікувалось %x", got, want)
+			}
+		})
+	}
+
+	empty := rootFromScratch(t, nil)
+	if !bytes.Equal(empty, chain.EmptyStateRoot()) {
+		t.Errorf("корінь порожнього стану %x", empty)
+	}
+}
+
+func finalStateOps(ops []stateOp) []stateOp {
+	var res []stateOp
+	for k, v := range finalState(ops) {
+		res = append(res, stateOp{k, v})
+	}
+	return res
+}
+
+// Зміни, які не потрапили в закомічений блок, не впливают на збережене дерево
+func TestStateRootDiscard(t *testing.T) {
+	bs := testStorage(t)
+	keys := testKeys(10)
+
+	st := bs.NewStateTxn()
+	root := applyOps(t, st, keys, 4)
+	if err := st.commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	st = bs.NewStateTxn()
+	applyOps(t, st, []stateOp{{key: keys[0].key}, {"wallet100", "new"}}, 1)
+	st.Discard()
+
+	st = bs.NewStateTxn()
+	defer st.Discard()
+	got, err := st.StateRoot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, root) {
+		t.Errorf("корінь після відкинутих змін %x, очікувалось %x", got, root)
+	}
+}
+
+func TestStateProof(t *testing.T) {
+	bs := testStorage(t)
+	keys := testKeys(40)
+
+	st := bs.NewStateTxn()
+	defer st.Discard()
+	root := applyOps(t, st, keys, 5)
+	tree := &stateTree{txn: st.txn}
+
+	tests := []struct {
+		name  string
+		key   string
+		value string
+	}{
+		{"перший ключ", keys[0].key, keys[0].value},
+		{"останній ключ", keys[39].key, keys[39].value},
+		{"ключа немає", "wallet999", ""},
+		{"ключа немає, інший префікс", "v_missing", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			proof, err := tree.proof(root, []byte(tt.key))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(proof.Value) != tt.value {
+				t.Fatalf("значення в доказі %q, очікувалось %q", proof.Value, tt.value)
+			}
+			if err := chain.VerifyStateProof(root, proof); err != nil {
+				t.Fatalf("доказ не пройшов перевірку: %v", err)
+			}
+
+			// будь-яка зміна доказу робить його не валідним
+			forged := *proof
+			if tt.value == "" {
+				forged.Value = []byte("підроблене значення")
+				forged.OtherKeyHash, forged.OtherValueHash = nil, nil
+			} else {
+				forged.Value = nil
+			}
+			if chain.VerifyStateProof(root, &forged) == nil {
+				t.Error("підроблене значення пройшло перевірку")
+			}
+
+			if len(proof.Siblings) > 0 {
+				forged = *proof
+				forged.Siblings = append([][]byte{}, proof.Siblings...)
+				forged.Siblings[0] = bytes.Repeat([]byte{0xff}, 32)
+				if chain.VerifyStateProof(root, &forged) == nil {
+					t.Error("змінений вузол пройшов перевірку")
+				}
+			}
+
+			if chain.VerifyStateProof(chain.EmptyStateRoot(), proof) == nil {
+				t.Error("доказ пройшов перевірку з іншим коренем")
+			}
+		})
+	}
+}
+
+func TestStateProofAfterCommit(t *testing.T) {
+	bs := testStorage(t)
+
+	st := bs.NewStateTxn()
+	defer st.Discard()
+	wallet := chain.Wallet{Address: bytes.Repeat([]byte{0x11}, 32), Balance: 150, Nonce: 2}
+	if err := st.SetWallet(&wallet); err != nil {
+		t.Fatal(err)
+	}
+	root, err := st.StateRoot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	block := &chain.Block{BlockHeader: chain.BlockHeader{Height: 0, StateRoot: root}}
+	if err := bs.ApplyBlock(block, nil, st); err != nil {
+		t.Fatal(err)
+	}
+
+	got, proof, err := bs.GetWalletProof(wallet.Address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := chain.VerifyStateProof(got.StateRoot, proof); err != nil {
+		t.Fatal(err)
+	}
+	var proved chain.Wallet
+	if err := proved.UnmarshalBinary(proof.Value); err != nil {
+		t.Fatal(err)
+	}
+	if proved.Balance != wallet.Balance || proved.Nonce != wallet.Nonce {
+		t.Errorf("гаманець з доказу %+v, очікувалось %+v", proved, wallet)
+	}
+}
+
+func TestStateTxnRevertToSnapshot(t *testing.T) {
+	keys := testKeys(10)
+
+	tests := []struct {
+		name     string
+		reverted []stateOp
+	}{
+		{"нові ключі", []stateOp{{"wallet100", "new"}, {"wallet101", "new"}}},
+		{"перезапис", []stateOp{{keys[0].key, "new"}, {keys[0].key, "newer"}}},
+		{"видалення", []stateOp{{key: keys[1].key}, {key: keys[2].key}}},
+		{"ключ, змінений до snapshot", []stateOp{{keys[9].key, "new"}, {key: keys[9].key}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			st := testStorage(t).NewStateTxn()
+			defer st.Discard()
+			applyOps(t, st, keys[:9], 4)
+			// keys[9] змінено після останнього StateRoot, тому він ще в changes
+			if err := st.set([]byte(keys[9].key), []byte(keys[9].value)); err != nil {
+				t.Fatal(err)
+			}
+
+			snapshot := st.Snapshot()
+			for _, op := range tt.reverted {
+				var err error
+				if op.value == "" {
+					err = st.delete([]byte(op.key))
+				} else {
+					err = st.set([]byte(op.key), []byte(op.value))
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := st.RevertToSnapshot(snapshot); err != nil {
+				t.Fatal(err)
+			}
+
+			for _, op := range append(keys, tt.reverted...) {
+				want := finalState(keys)[op.key]
+				var got string
+				item, err := st.txn.Get([]byte(op.key))
+				if err == nil {
+					value, err := item.ValueCopy(nil)
+					if err != nil {
+						t.Fatal(err)
+					}
+					got = string(value)
+				} else if !isNotFound(err) {
+					t.Fatal(err)
+				}
+				if got != want {
+					t.Errorf("%s після відкату: %q, очікувалось %q", op.key, got, want)
+				}
+			}
+
+			root, err := st.StateRoot()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := rootFromScratch(t, finalState(keys)); !bytes.Equal(root, want) {
+				t.Errorf("корінь після відкату %x, очікувалось %x", root, want)
+			}
+		})
+	}
+
+	st := testStorage(t).NewStateTxn()
+	defer st.Discard()
+	if st.RevertToSnapshot(1) == nil {
+		t.Error("відкат до snapshot, якого не було, пройшов")
+	}
+}
@@ -0,0 +1,210 @@
+package database
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+
+	"github.com/PQlite/core/chain"
+	"github.com/dgraph-io/badger/v4"
+)
+
+// StateTxn це зміни стану (гаманці, валідатори, набори валідаторів епохи, RANDAO, делегації, unbond, параметри консенсусу, докази, пропуски валідаторів) в одній badger транзакції.
+// Поки блок не застосовано через BlockStorage.ApplyBlock, зміни бачить тільки ця транзакція,
+// тому її можна використовувати і для перевірки блоку (Discard), і для його застосування.
+// Всі ключі стану записуются тільки через set і delete, щоб StateRoot знав, що змінилось
+type StateTxn struct {
+	bs      *BlockStorage
+	txn     *badger.Txn
+	changes map[string][]byte // ключі стану, змінені після останнього StateRoot: ключ -> нове значення, nil - видалено
+	journal []stateUndo       // попередні значення ключів для RevertToSnapshot, в порядку змін
+}
+
+// stateUndo як повернути ключ стану до значення перед однією зміною
+type stateUndo struct {
+	key       []byte
+	value     []byte // значення в транзакції перед зміною, nil - ключа не було
+	change    []byte // запис в changes перед зміною
+	hadChange bool   // чи був ключ в changes
+}
+
+func (bs *BlockStorage) NewStateTxn() *StateTxn {
+	return &StateTxn{
+		bs:      bs,
+		txn:     bs.db.NewTransaction(true),
+		changes: make(map[string][]byte),
+	}
+}
+
+// set записує ключ стану. Значення не може бути порожнім, тому що порожнє значення в доказі - це відсутній ключ
+func (s *StateTxn) set(key []byte, value []byte) error {
+	if len(value) == 0 {
+		return fmt.Errorf("порожнє значення ключа стану %q", key)
+	}
+	if err := s.record(key); err != nil {
+		return err
+	}
+	if err := s.txn.Set(key, value); err != nil {
+		return err
+	}
+	s.changes[string(key)] = append([]byte{}, value...)
+	return nil
+}
+
+// delete видаляє ключ стану
+func (s *StateTxn) delete(key []byte) error {
+	if err := s.record(key); err != nil {
+		return err
+	}
+	if err := s.txn.Delete(key); err != nil {
+		return err
+	}
+	s.changes[string(key)] = nil
+	return nil
+}
+
+// record зберігає в journal поточне значення key перед його зміною
+func (s *StateTxn) record(key []byte) error {
+	undo := stateUndo{key: append([]byte{}, key...)}
+	item, err := s.txn.Get(key)
+	switch {
+	case isNotFound(err):
+	case err != nil:
+		return err
+	default:
+		if undo.value, err = item.ValueCopy(nil); err != nil {
+			return err
+		}
+	}
+	undo.change, undo.hadChange = s.changes[string(key)]
+	s.journal = append(s.journal, undo)
+	return nil
+}
+
+// Snapshot позначає поточний стан транзакції, до якого можна повернутись через RevertToSnapshot.
+// Snapshot дійсний до наступного StateRoot
+func (s *StateTxn) Snapshot() int {
+	return len(s.journal)
+}
+
+// RevertToSnapshot відкидає всі зміни стану після Snapshot, який повернув snapshot. Так транзакція, яка не
+// виконалась до кінця, не залишає частину своїх змін
+func (s *StateTxn) RevertToSnapshot(snapshot int) error {
+	if snapshot < 0 || snapshot > len(s.journal) {
+		return fmt.Errorf("не відомий snapshot стану %d", snapshot)
+	}
+	for i := len(s.journal) - 1; i >= snapshot; i-- {
+		undo := s.journal[i]
+		var err error
+		if undo.value == nil {
+			err = s.txn.Delete(undo.key)
+		} else {
+			err = s.txn.Set(undo.key, undo.value)
+		}
+		if err != nil {
+			return err
+		}
+		if undo.hadChange {
+			s.changes[string(undo.key)] = undo.change
+		} else {
+			delete(s.changes, string(undo.key))
+		}
+	}
+	s.journal = s.journal[:snapshot]
+	return nil
+}
+
+// Discard відкидає всі зміни. Можна викликати після ApplyBlock
+func (s *StateTxn) Discard() {
+	s.txn.Discard()
+}
+
+// commit зберігає зміни. Стан зберігаєтся тільки разом з блоком, тому це не експортуєтся
+func (s *StateTxn) commit() error {
+	if err := s.txn.Commit(); err != nil {
+		return err
+	}
+	return s.bs.db.Sync()
+}
+
+func (s *StateTxn) GetWallet(addr []byte) (chain.Wallet, error) {
+	return getWallet(s.txn, addr)
+}
+
+func (s *StateTxn) SetWallet(wallet *chain.Wallet) error {
+	data, err := wallet.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	return s.set(getWalletKey(wallet.Address), data)
+}
+
+// GetValidator повертає nil без помилки, якщо валідатора немає
+func (s *StateTxn) GetValidator(addr []byte) (*chain.Validator, error) {
+	validator, err := getValidator(s.txn, addr)
+	if isNotFound(err) {
+		return nil, nil
+	}
+	return validator, err
+}
+
+func (s *StateTxn) SetValidator(validator *chain.Validator) error {
+	data, err := validator.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	return s.set(getValidatorKey(validator.Address), data)
+}
+
+func (s *StateTxn) DeleteValidator(addr []byte) error {
+	return s.delete(getValidatorKey(addr))
+}
+
+func (s *StateTxn) GetParams() (*chain.ConsensusParams, error) {
+	return getParams(s.txn)
+}
+
+func (s *StateTxn) SetParams(params *chain.ConsensusParams) error {
+	data, err := params.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	return s.set(paramsKey, data)
+}
+
+func (s *StateTxn) GetValidatorsList() ([]chain.Validator, error) {
+	return getValidatorsList(s.txn)
+}
+
+// StateRoot застосовує до дерева стану зміни після попереднього виклику і повертає його корінь.
+// Нові вузли дерева записуются в цю ж транзакцію, тому зберігаются разом з блоком, або відкидаются разом з нею
+func (s *StateTxn) StateRoot() ([]byte, error) {
+	root, err := getStateRoot(s.txn)
+	if err != nil {
+		return nil, err
+	}
+	if len(s.changes) == 0 {
+		return root, nil
+	}
+
+	changes := make([]stateChange, 0, len(s.changes))
+	for key, value := range s.changes {
+		c := stateChange{keyHash: chain.StateKeyHash([]byte(key))}
+		if value != nil {
+			c.valueHash = chain.StateValueHash(value)
+		}
+		changes = append(changes, c)
+	}
+	sort.Slice(changes, func(i, j int) bool { return bytes.Compare(changes[i].keyHash, changes[j].keyHash) < 0 })
+
+	t := &stateTree{txn: s.txn}
+	if root, _, err = t.update(root, 0, changes); err != nil {
+		return nil, err
+	}
+	if err = s.txn.Set(stateRootKey, root); err != nil {
+		return nil, err
+	}
+	s.changes = make(map[string][]byte)
+	s.journal = nil
+	return root, nil
+}
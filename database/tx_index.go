@@ -0,0 +1,175 @@
+package database
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/PQlite/core/chain"
+	"github.com/dgraph-io/badger/v4"
+)
+
+// Індекс транзакцій. Записуєтся разом з блоком в ApplyBlock і не входить в state root
+//
+//	txh: + hash транзакції                                       -> висота (uint32) + позиція в блоці (uint32)
+//	txa: + довжина адреси (uint32) + адреса + висота + позиція -> hash транзакції
+//
+// Довжина адреси потрібна, щоб адреса не могла бути префіксом іншої адреси
+var (
+	txHashPrefix = []byte("txh:")
+	txAddrPrefix = []byte("txa:")
+)
+
+// TxLocation де знаходится транзакція: висота блоку і позиція в ньому
+type TxLocation struct {
+	Height uint32 `json:"height"`
+	Index  uint32 `json:"index"`
+}
+
+// IndexedTx транзакція разом з її hash і місцем в ланцюжку
+type IndexedTx struct {
+	Hash []byte             `json:"hash"`
+	Tx   *chain.Transaction `json:"tx"`
+	TxLocation
+}
+
+func getTxHashKey(hash []byte) []byte {
+	return append(append([]byte{}, txHashPrefix...), hash...)
+}
+
+func getTxAddrPrefix(addr []byte) []byte {
+	key := binary.BigEndian.AppendUint32(append([]byte{}, txAddrPrefix...), uint32(len(addr)))
+	return append(key, addr...)
+}
+
+func getTxAddrKey(addr []byte, loc TxLocation) []byte {
+	key := getTxAddrPrefix(addr)
+	key = binary.BigEndian.AppendUint32(key, loc.Height)
+	return binary.BigEndian.AppendUint32(key, loc.Index)
+}
+
+// indexBlockTxs додає транзакції блоку в індекс
+func indexBlockTxs(txn *badger.Txn, block *chain.Block) error {
+	for i, tx := range block.Transactions {
+		hash := tx.Hash()
+		loc := TxLocation{Height: block.Height, Index: uint32(i)}
+
+		value := binary.BigEndian.AppendUint32(nil, loc.Height)
+		value = binary.BigEndian.AppendUint32(value, loc.Index)
+		if err := txn.Set(getTxHashKey(hash), value); err != nil {
+			return err
+		}
+
+		if err := txn.Set(getTxAddrKey(tx.From, loc), hash); err != nil {
+			return err
+		}
+		// в транзакцій stake (bond, unbond, ...) To порожній
+		if len(tx.To) > 0 && !bytes.Equal(tx.From, tx.To) {
+			if err := txn.Set(getTxAddrKey(tx.To, loc), hash); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// GetTransaction шукає транзакцію за її hash, або повертає ErrTxNotFound
+func (bs *BlockStorage) GetTransaction(hash []byte) (*IndexedTx, error) {
+	var res *IndexedTx
+
+	err := bs.db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(getTxHashKey(hash))
+		if isNotFound(err) {
+			return ErrTxNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		var loc TxLocation
+		err = item.Value(func(val []byte) error {
+			if len(val) != 8 {
+				return fmt.Errorf("не правельний запис в індексі транзакцій")
+			}
+			loc.Height = binary.BigEndian.Uint32(val[:4])
+			loc.Index = binary.BigEndian.Uint32(val[4:])
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		res, err = getIndexedTx(txn, hash, loc)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// GetAddressTxs повертає транзакції, де адреса є відправником або отримувачем, від нових до старих.
+// offset пропускає перші транзакції, limit обмежує кількість
+func (bs *BlockStorage) GetAddressTxs(addr []byte, offset int, limit int) ([]*IndexedTx, error) {
+	res := []*IndexedTx{}
+
+	err := bs.db.View(func(txn *badger.Txn) error {
+		prefix := getTxAddrPrefix(addr)
+
+		opts := badger.DefaultIteratorOptions
+		opts.Prefix = prefix
+		opts.Reverse = true
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		// при зворотньому порядку треба почати з ключа, більшого за всі ключі з цим префіксом
+		seek := append(append([]byte{}, prefix...), bytes.Repeat([]byte{0xff}, 8)...)
+
+		skipped := 0
+		for it.Seek(seek); it.Valid() && len(res) < limit; it.Next() {
+			if skipped < offset {
+				skipped++
+				continue
+			}
+
+			key := it.Item().Key()
+			loc := TxLocation{
+				Height: binary.BigEndian.Uint32(key[len(prefix):]),
+				Index:  binary.BigEndian.Uint32(key[len(prefix)+4:]),
+			}
+			hash, err := it.Item().ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+
+			indexed, err := getIndexedTx(txn, hash, loc)
+			if err != nil {
+				return err
+			}
+			res = append(res, indexed)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+func getIndexedTx(txn *badger.Txn, hash []byte, loc TxLocation) (*IndexedTx, error) {
+	block, err := getBlock(txn, loc.Height)
+	if err != nil {
+		return nil, err
+	}
+	if int(loc.Index) >= len(block.Transactions) {
+		return nil, fmt.Errorf("транзакції %d немає в блоці %d", loc.Index, loc.Height)
+	}
+
+	return &IndexedTx{
+		Hash:       hash,
+		Tx:         block.Transactions[loc.Index],
+		TxLocation: loc,
+	}, nil
+}
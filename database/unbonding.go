@@ -0,0 +1,114 @@
+package database
+
+import (
+	"bytes"
+
+	"github.com/PQlite/core/chain"
+	"github.com/dgraph-io/badger/v4"
+)
+
+// unbondingPrefix stake, який чекає кінця UnbondingPeriod. Ключ - ub_<делегатор><валідатор>,
+// тому всі unbond делегатора йдуть підряд
+var unbondingPrefix = []byte("ub_")
+
+// getUnbonding повертає порожній запис, якщо в делегатора немає незавершених unbond у валідатора
+func getUnbonding(txn *badger.Txn, delegator []byte, validator []byte) (*chain.Unbonding, error) {
+	item, err := txn.Get(getUnbondingKey(delegator, validator))
+	if isNotFound(err) {
+		return &chain.Unbonding{Delegator: delegator, Validator: validator}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var unbonding chain.Unbonding
+	err = item.Value(func(val []byte) error {
+		return unbonding.UnmarshalBinary(val)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &unbonding, nil
+}
+
+// getUnbondings повертає всі записи з ключем, що починаєтся з prefix
+func getUnbondings(txn *badger.Txn, prefix []byte) ([]*chain.Unbonding, error) {
+	var res []*chain.Unbonding
+
+	opts := badger.DefaultIteratorOptions
+	opts.Prefix = prefix
+
+	it := txn.NewIterator(opts)
+	defer it.Close()
+
+	for it.Rewind(); it.Valid(); it.Next() {
+		err := it.Item().Value(func(v []byte) error {
+			var unbonding chain.Unbonding
+			if err := unbonding.UnmarshalBinary(v); err != nil {
+				return err
+			}
+			res = append(res, &unbonding)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+func (s *StateTxn) GetUnbonding(delegator []byte, validator []byte) (*chain.Unbonding, error) {
+	return getUnbonding(s.txn, delegator, validator)
+}
+
+// GetUnbondings всі незавершені unbond делегатора
+func (s *StateTxn) GetUnbondings(delegator []byte) ([]*chain.Unbonding, error) {
+	return getUnbondings(s.txn, getUnbondingKey(delegator, nil))
+}
+
+// GetValidatorUnbondings всі незавершені unbond з валідатора. Проходить по всіх unbond в стані,
+// але потрібно тільки для штрафів, а вони рідкі
+func (s *StateTxn) GetValidatorUnbondings(validator []byte) ([]*chain.Unbonding, error) {
+	all, err := getUnbondings(s.txn, unbondingPrefix)
+	if err != nil {
+		return nil, err
+	}
+	res := all[:0]
+	for _, u := range all {
+		if bytes.Equal(u.Validator, validator) {
+			res = append(res, u)
+		}
+	}
+	return res, nil
+}
+
+// SetUnbonding зберігає незавершені unbond. Порожній запис видаляєтся, щоб не залишатись в стані
+func (s *StateTxn) SetUnbonding(unbonding *chain.Unbonding) error {
+	key := getUnbondingKey(unbonding.Delegator, unbonding.Validator)
+	if len(unbonding.Entries) == 0 {
+		return s.delete(key)
+	}
+	data, err := unbonding.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	return s.set(key, data)
+}
+
+// GetUnbondings всі незавершені unbond делегатора
+func (bs *BlockStorage) GetUnbondings(delegator []byte) ([]*chain.Unbonding, error) {
+	var res []*chain.Unbonding
+
+	err := bs.db.View(func(txn *badger.Txn) error {
+		var err error
+		res, err = getUnbondings(txn, getUnbondingKey(delegator, nil))
+		return err
+	})
+	return res, err
+}
+
+// додає ub_ до адрес делегатора і валідатора
+func getUnbondingKey(delegator []byte, validator []byte) []byte {
+	key := append(append([]byte{}, unbondingPrefix...), delegator...)
+	return append(key, validator...)
+}
@@ -0,0 +1,72 @@
+package database
+
+import (
+	"github.com/PQlite/core/chain"
+	"github.com/dgraph-io/badger/v4"
+)
+
+// validatorSetPrefix набори валідаторів епохи: vs_current створює і підписує наступний блок,
+// vs_last створив і підписав останній виконаний блок (потрібен, щоб перевірити LastCommit наступного)
+var (
+	validatorSetPrefix  = []byte("vs_")
+	currentValidatorSet = []byte("vs_current")
+	lastValidatorSet    = []byte("vs_last")
+)
+
+// getValidatorSet повертає порожній набір, якщо його немає (vs_last до першого блоку)
+func getValidatorSet(txn *badger.Txn, key []byte) (*chain.ValidatorSet, error) {
+	item, err := txn.Get(key)
+	if isNotFound(err) {
+		return &chain.ValidatorSet{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var set chain.ValidatorSet
+	err = item.Value(func(val []byte) error {
+		return set.UnmarshalBinary(val)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &set, nil
+}
+
+func (s *StateTxn) setValidatorSet(key []byte, set *chain.ValidatorSet) error {
+	data, err := set.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	return s.set(key, data)
+}
+
+// GetValidatorSet набір, який створює і підписує наступний блок
+func (s *StateTxn) GetValidatorSet() (*chain.ValidatorSet, error) {
+	return getValidatorSet(s.txn, currentValidatorSet)
+}
+
+func (s *StateTxn) SetValidatorSet(set *chain.ValidatorSet) error {
+	return s.setValidatorSet(currentValidatorSet, set)
+}
+
+// GetLastValidatorSet набір, який створив і підписав останній виконаний блок
+func (s *StateTxn) GetLastValidatorSet() (*chain.ValidatorSet, error) {
+	return getValidatorSet(s.txn, lastValidatorSet)
+}
+
+func (s *StateTxn) SetLastValidatorSet(set *chain.ValidatorSet) error {
+	return s.setValidatorSet(lastValidatorSet, set)
+}
+
+// GetValidatorSet набір, який створює і підписує наступний блок
+func (bs *BlockStorage) GetValidatorSet() (*chain.ValidatorSet, error) {
+	var set *chain.ValidatorSet
+
+	err := bs.db.View(func(txn *badger.Txn) error {
+		var err error
+		set, err = getValidatorSet(txn, currentValidatorSet)
+		return err
+	})
+	return set, err
+}
@@ -3,108 +3,89 @@
 package database
 
 import (
-	"encoding/binary"
+	"errors"
 
 	"github.com/PQlite/core/chain"
 	"github.com/dgraph-io/badger/v4"
 )
 
-func (bs *BlockStorage) AddValidator(validator *chain.Validator) error {
-	txn := bs.db.NewTransaction(true)
-	defer txn.Discard()
+var validatorPrefix = []byte("v_")
 
-	key := getValidatorKey(validator.Address)
+// getValidator повертає badger.ErrKeyNotFound, якщо валідатора немає
+func getValidator(txn *badger.Txn, addr []byte) (*chain.Validator, error) {
+	item, err := txn.Get(getValidatorKey(addr))
+	if err != nil {
+		return nil, err
+	}
 
-	err := txn.Set(key, int64ToBytes(validator.Amount))
+	var validator chain.Validator
+	err = item.Value(func(val []byte) error {
+		return validator.UnmarshalBinary(val)
+	})
 	if err != nil {
-		return err
+		return nil, err
 	}
-	return txn.Commit()
+	return &validator, nil
 }
 
-func (bs *BlockStorage) DeleteValidator(validator *chain.Validator) error {
-	txn := bs.db.NewTransaction(true)
-	defer txn.Discard()
+func getValidatorsList(txn *badger.Txn) ([]chain.Validator, error) {
+	var res []chain.Validator
 
-	key := getValidatorKey(validator.Address)
+	opts := badger.DefaultIteratorOptions
+	opts.PrefetchValues = true
+	opts.Prefix = validatorPrefix
 
-	err := txn.Delete(key)
-	if err != nil {
-		return err
-	}
+	it := txn.NewIterator(opts)
+	defer it.Close()
 
-	return txn.Commit()
+	for it.Rewind(); it.Valid(); it.Next() {
+		err := it.Item().Value(func(v []byte) error {
+			var validator chain.Validator
+			if err := validator.UnmarshalBinary(v); err != nil {
+				return err
+			}
+			res = append(res, validator)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
 }
 
 func (bs *BlockStorage) GetValidatorsList() (*[]chain.Validator, error) {
 	var res []chain.Validator
 
 	err := bs.db.View(func(txn *badger.Txn) error {
-		opts := badger.DefaultIteratorOptions
-		opts.PrefetchValues = true
-		opts.Prefix = []byte("v_")
-
-		it := txn.NewIterator(opts)
-		defer it.Close()
-
-		for it.Rewind(); it.Valid(); it.Next() {
-			item := it.Item()
-			key := item.Key()
-
-			err := item.Value(func(v []byte) error {
-				address := getValidatorAddress(key)
-				amount := bytesToInt64(v)
-				res = append(res, chain.Validator{Address: address, Amount: amount})
-				return nil
-			})
-			if err != nil {
-				return err
-			}
-		}
-		return nil
+		var err error
+		res, err = getValidatorsList(txn)
+		return err
 	})
 	return &res, err
 }
 
 func (bs *BlockStorage) GetValidator(addr []byte) (*chain.Validator, error) {
-	var validator chain.Validator
+	var validator *chain.Validator
 
 	err := bs.db.View(func(txn *badger.Txn) error {
-		key := getValidatorKey(addr)
-		item, err := txn.Get(key)
-		if err != nil {
-			return err
-		}
-
-		err = item.Value(func(val []byte) error {
-			validator.Address = addr
-			validator.Amount = bytesToInt64(val)
-			return nil
-		})
+		var err error
+		validator, err = getValidator(txn, addr)
 		return err
 	})
 	if err != nil {
 		return nil, err
 	}
 
-	return &validator, nil
-}
-
-func int64ToBytes(i int64) []byte {
-	iBytes := make([]byte, 8)
-	binary.BigEndian.PutUint64(iBytes, uint64(i))
-	return iBytes
-}
-
-func bytesToInt64(b []byte) int64 {
-	return int64(binary.BigEndian.Uint64(b))
+	return validator, nil
 }
 
 // додає v_ до адреси
 func getValidatorKey(a []byte) []byte {
-	return append([]byte("v_"), a...)
+	return append(append([]byte{}, validatorPrefix...), a...)
 }
 
-func getValidatorAddress(a []byte) []byte {
-	return a[len([]byte("v_")):]
+// isNotFound true, якщо помилка означає, що ключа немає в базі
+func isNotFound(err error) bool {
+	return errors.Is(err, badger.ErrKeyNotFound)
 }
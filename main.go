@@ -10,40 +10,53 @@ import (
 
 	"github.com/PQlite/core/api"
 	"github.com/PQlite/core/chain"
+	"github.com/PQlite/core/config"
 	"github.com/PQlite/core/database"
 	"github.com/PQlite/core/p2p"
+	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 )
 
 func main() {
-	bs, err := database.InitDB()
+	cfg, err := config.Load(os.Args[1:])
+	if err != nil {
+		log.Fatal().Err(err).Msg("помилка конфігурації")
+	}
+
+	level, _ := zerolog.ParseLevel(cfg.LogLevel) // рівень вже перевірено в config.Load
+	zerolog.SetGlobalLevel(level)
+
+	if err := os.MkdirAll(cfg.DataDir, 0700); err != nil {
+		log.Fatal().Err(err).Msg("помилка створення data dir")
+	}
+
+	bs, err := database.InitDB(cfg.DBDir())
 	if err != nil {
 		log.Fatal().Err(err).Msg("помилка initdb")
 	}
 
-	_, err = bs.GetLastBlock()
+	g, err := chain.LoadGenesis(cfg.GenesisFile)
+	if err != nil {
+		log.Fatal().Err(err).Msg("помилка завантаження genesis")
+	}
+
+	genesis, err := bs.InitGenesis(g)
 	if err != nil {
-		if err.Error() == "no blocks found" {
-			log.Info().Msg("база даних порожня, початок створення genesis блоку")
-			b, val, wallet := chain.CreateGenesisBlock()
-			bs.SaveBlock(&b)
-			bs.AddValidator(&val)
-			bs.UpdateBalance(&wallet)
-			log.Info().Msg("genesis блок створено")
-		}
+		log.Fatal().Err(err).Msg("помилка ініціалізації genesis")
 	}
+	log.Info().Str("chain_id", genesis.ChainID).Hex("hash", genesis.Hash).Msg("genesis блок")
 
-	mempool := chain.Mempool{}
+	mempool := chain.Mempool{ChainID: genesis.ChainID, MaxTxBytes: g.Params.MaxTxBytes, Accounts: bs}
 	ctx := context.Background()
 
-	node, err := p2p.NewNode(ctx, &mempool, bs)
+	node, err := p2p.NewNode(ctx, cfg, &mempool, bs)
 	if err != nil {
 		log.Fatal().Err(err).Msg("помилка створення p2p ноди")
 	}
 
 	server := api.NewServer(&node, &mempool, bs)
 
-	go server.Start()
+	go server.Start(cfg.APIAddr)
 	go node.Start()
 
 	// wait for a SIGINT or SIGTERM signal
@@ -1,21 +1,16 @@
 package p2p
 
-// TODO: зробити механізм відмови від блоку. коли блок не пройшов перевірку, треба щоб мережа не зупинялась, а вибрала іншого валідатора
-
 import (
 	"bytes"
 	"encoding/json"
 	"time"
 
 	"github.com/PQlite/core/chain"
-	"github.com/PQlite/crypto"
 	"github.com/rs/zerolog/log"
 )
 
 // Читання вхідних повідомлень
 func (n *Node) handleBroadcastMessages() {
-	// NOTE: знаю, але вже так як є. треба робити адекватно, але потім
-	go n.processBlockProposalCommit()
 	for {
 		data, err := n.topic.sub.Next(n.ctx)
 		if err != nil {
@@ -50,28 +45,15 @@ func (n *Node) handleBroadcastMessages() {
 			go n.handleMsgNewTransaction(message.Data)
 		case MsgVote:
 			go n.handleMsgVote(message.Data)
-		default: // NOTE: ну тут гача якась
+		case MsgEvidence:
+			go n.handleMsgEvidence(message.Data)
+		default: // proposal і commit обробляются по черзі в consensusLoop
 			n.messagesQueue <- message
 		}
 
 	}
 }
 
-func (n *Node) processBlockProposalCommit() {
-	for {
-		message := <-n.messagesQueue
-
-		switch message.Type {
-		case MsgBlockProposal:
-			n.handleMsgBlockProposal(message.Data)
-		case MsgCommit:
-			n.handleMsgCommit(message.Data)
-		case MsgReject:
-			n.handleMsgReject()
-		}
-	}
-}
-
 func (n *Node) handleMsgNewTransaction(data []byte) {
 	var tx chain.Transaction
 	err := json.Unmarshal(data, &tx)
@@ -88,83 +70,15 @@ func (n *Node) handleMsgNewTransaction(data []byte) {
 }
 
 func (n *Node) handleMsgBlockProposal(data []byte) {
-	var block chain.Block
-	err := json.Unmarshal(data, &block)
+	var proposal chain.Proposal
+	err := json.Unmarshal(data, &proposal)
 	if err != nil {
 		log.Error().Err(err).Msg("помилка розпаковки blockProposal")
 		return
 	}
-	log.Info().Uint32("height", block.Height).Int64("latency", time.Now().UnixMilli()-block.Timestamp).Msg("отримано новий блок")
-
-	blockBytes, err := block.MarshalDeterministic()
-	if err != nil {
-		log.Error().Err(err).Msg("помилка перетворення блоку на []byte")
-		return
-	}
-
-	if err := n.fullBlockVerefication(&block); err != nil {
-		return
-	}
-
-	msg, err := n.getVoteMsg(blockBytes)
-	if err != nil {
-		log.Error().Err(err).Msg("помилка створення повідомлення для голосування")
-		return
-	}
-
-	if err = n.topic.broadcast(msg, n.ctx); err != nil {
-		log.Error().Err(err).Msg("помилка розсилання повідомлення голосування")
-		return
-	}
-
-	// якщо це не я роблю блок
-	if !bytes.Equal(block.Proposer, n.keys.Pub) {
-		return
-	}
-
-	// OPTIMIZE: я думаю зробити список, в якому будуть ставитись галочки чи щось таке
-	// TODO: додати обробку сценарію, коли не проголосували в достатній кількості
-	var votersList []chain.Vote
-	allValidators, err := n.bs.GetValidatorsList()
-	if err != nil {
-		panic(err)
-	}
-
-	var accceptedAmount int64
-	var stakeAmount int64
-	for _, validator := range *allValidators {
-		stakeAmount += validator.Amount
-	}
-
-	for {
-		v := <-n.vote
-		if err = crypto.Verify(v.Pub, blockBytes, v.Signature); err != nil {
-			log.Info().Msg("голос не є вілідним")
-			continue
-		}
+	log.Info().Uint32("height", proposal.Block.Height).Uint32("round", proposal.Round).Int64("latency", time.Now().UnixMilli()-proposal.Block.Timestamp).Msg("отримано новий блок")
 
-		contais, validator := containsInValidators(v.Pub, allValidators)
-		if contais {
-			accceptedAmount += validator.Amount
-
-			votersList = append(votersList, v)
-		}
-
-		// NOTE: для релізу погано, але зараз ок
-		if (stakeAmount / 2) < accceptedAmount { // >50%
-			break
-		}
-	}
-
-	commitMsg, err := n.getCommitMsg(&votersList, &block)
-	if err != nil {
-		panic(err)
-	}
-
-	if err = n.topic.broadcast(commitMsg, n.ctx); err != nil {
-		panic(err)
-	}
-	log.Debug().Msg("повідомлення commit відправлено")
+	n.addProposal(&proposal)
 }
 
 func (n *Node) handleMsgVote(data []byte) {
@@ -176,93 +90,62 @@ func (n *Node) handleMsgVote(data []byte) {
 	n.vote <- vote
 }
 
-func (n *Node) handleMsgCommit(data []byte) {
-	go drainChannel(n.vote)
-
-	var commit Commit
-	if err := json.Unmarshal(data, &commit); err != nil {
-		panic(err)
+// handleMsgEvidence додає доказ подвійного підпису в пул, звідки його додасть в блок proposer
+func (n *Node) handleMsgEvidence(data []byte) {
+	var evidence chain.Evidence
+	if err := json.Unmarshal(data, &evidence); err != nil {
+		log.Error().Err(err).Msg("помилка розпаковки доказу")
+		return
 	}
 
-	// TODO: перевірити дані з commit
-	allValidators, err := n.bs.GetValidatorsList()
+	added, err := n.evidence.Add(&evidence)
 	if err != nil {
-		panic(err)
-	}
-	for _, v := range commit.Voters {
-		if err := v.Verify(&commit.Block); err != nil {
-			log.Error().Hex("voter", v.Pub).Msg("помилка підтвердження підпису голосу")
-			return
-		}
-
-		contains, _ := containsInValidators(v.Pub, allValidators)
-		if !contains {
-			log.Error().Hex("voter", v.Pub).Msg("голос не був в списку валідаторів")
-			return
-		}
-	}
-
-	if err := n.bs.SaveBlock(&commit.Block); err != nil {
-		panic(err)
-	}
-	log.Info().Hex("block hash", commit.Block.Hash).Uint32("height", commit.Block.Height).Msg("додано новий блок до ланцюжка")
-
-	go n.mempool.ClearMempool(commit.Block.Transactions)
-
-	if err := n.addValidatorsToDB(&commit.Block); err != nil {
-		panic(err)
+		log.Warn().Err(err).Msg("отриманий доказ не є валідним")
+		return
 	}
-
-	if err := n.deleteValidatorsFromDB(&commit.Block); err != nil {
-		panic(err)
+	if added {
+		log.Warn().Stringer("type", evidence.Type).Hex("валідатор", evidence.Offender()).Uint32("height", evidence.Height()).Msg("отримано доказ подвійного підпису")
 	}
+}
 
-	if err := n.updateBalancesNonces(&commit.Block); err != nil {
-		panic(err)
+// handleMsgCommit приймає блок з commit, якщо його сертифікат має precommit більше ніж 2/3 stake
+// поточних валідаторів і сам блок проходить повну перевірку
+func (n *Node) handleMsgCommit(data []byte) {
+	var commit Commit
+	if err := json.Unmarshal(data, &commit); err != nil {
+		log.Error().Err(err).Msg("помилка розпаковки commit")
+		return
 	}
 
-	if err := n.setNextProposer(); err != nil {
-		panic(err)
+	if commit.Block.ChainID != n.chainID {
+		log.Error().Str("chain_id", commit.Block.ChainID).Msg("commit для іншої мережі")
+		return
 	}
-
-	// я і є настпуний валідатор!
-	if bytes.Equal(n.nextProposer.Address, n.keys.Pub) {
-		blockProposalMsg, err := n.getMsgBlockProposalMsg()
-		if err != nil {
-			panic(err)
-		}
-
-		if err = n.topic.broadcast(blockProposalMsg, n.ctx); err != nil {
-			log.Error().Err(err).Msg("помилка трансляції нового блоку")
+	if commit.Block.Height != n.cs.height {
+		log.Debug().Uint32("height", commit.Block.Height).Uint32("очікувана висота", n.cs.height).Msg("commit не для поточної висоти")
+		if commit.Block.Height > n.cs.height {
+			n.syncBlockchain()
 		}
+		return
 	}
-}
 
-func (n *Node) handleMsgReject() {
-	validator, err := n.bs.GetValidator(n.nextProposer.Address)
-	if err != nil {
-		panic(err)
+	if err := n.verifyCommit(&commit, n.cs.validators); err != nil {
+		log.Error().Err(err).Uint32("height", commit.Block.Height).Msg("commit не є валідним")
+		return
 	}
-
-	if err := n.bs.DeleteValidator(validator); err != nil {
-		panic(err)
+	if !n.isValidBlock(&commit.Block) {
+		return
 	}
 
-	if err := n.setNextProposer(); err != nil {
-		panic(err)
-	}
+	n.decide(&commit.Block, &commit.Certificate)
 }
 
-func drainChannel[T any](ch chan T) {
-	for {
-		select {
-		case <-ch:
-			// просто читаємо і відкидаємо значення
-		default:
-			// канал порожній - виходимо
-			return
-		}
+// verifyCommit перевіряє, що сертифікат commit саме для його блоку і має кворум validators
+func (n *Node) verifyCommit(commit *Commit, validators []chain.Validator) error {
+	if err := commit.Certificate.VerifyBlock(&commit.Block); err != nil {
+		return err
 	}
+	return commit.Certificate.Verify(validators, chain.HasTwoThirds)
 }
 
 func containsInValidators(pub []byte, validators *[]chain.Validator) (bool, *chain.Validator) {
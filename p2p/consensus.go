@@ -0,0 +1,700 @@
+package p2p
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/PQlite/core/chain"
+	"github.com/rs/zerolog/log"
+)
+
+// Консенсус на висоті проходить раунди, починаючи з 0 (як в Tendermint). Раунд має три кроки:
+//
+//   - propose: proposer раунду (SelectProposer від RandaoMix, висоти і раунду) розсилає Proposal.
+//     Валідатор голосує prevote за блок, якщо він валідний і не суперечить lock, інакше (або по таймауту) за nil
+//   - prevote: коли більше 2/3 stake проголосували prevote за блок, валідатор фіксує lock на ньому
+//     і голосує precommit за нього. Більше 2/3 prevote за nil (або таймаут) - precommit за nil
+//   - precommit: більше 2/3 precommit за блок в будь-якому раунді - блок прийнято. Інакше після таймауту
+//     починаєтся наступний раунд
+//
+// Валідатор з lock голосує тільки за заблокований блок, поки не побачить більше 2/3 prevote за інший
+// блок в пізнішому раунді. Через це два різні блоки не можуть обидва отримати більше 2/3 precommit
+// на одній висоті, якщо нечесних валідаторів менше 1/3 stake.
+//
+// Відлік таймауту propose починаєтся тільки коли є що додати в блок (транзакції в mempool, докази, отриманий блок
+// або голоси раунду), або коли з попереднього блоку пройшло BlockTime. Тоді proposer створює блок без транзакцій
+// (тільки нагорода), тому навіть без транзакцій блоки створюются не рідше ніж раз на BlockTime
+//
+// Два різні голоси одного типу в одному раунді, або два різні блоки від proposer в одному раунді - це
+// подвійний підпис. Нода, яка його побачила, розсилає chain.Evidence, і proposer додає його в блок.
+// Виконання доказу спалює частину stake порушника і виключає його з консенсусу (Jailed).
+// Щоб чесний валідатор не підписав такого після перезапуску, все підписане спочатку зберігаєтся в signState
+
+type roundStep int
+
+const (
+	stepPropose roundStep = iota
+	stepPrevote
+	stepPrecommit
+)
+
+// consensusState стан консенсусу на поточній висоті. Змінюєтся тільки в consensusLoop
+type consensusState struct {
+	height     uint32
+	round      uint32
+	step       roundStep
+	seed       []byte    // RandaoMix після останнього блоку
+	emptyAt    time.Time // з цього часу proposer створює блок, навіть якщо немає транзакцій
+	proposer   chain.Validator
+	validators []chain.Validator
+	totalStake int64
+	params     *chain.ConsensusParams
+
+	lockedBlock *chain.Block
+	lockedRound int32
+	validBlock  *chain.Block // останній блок, за який було більше 2/3 prevote
+	validRound  int32
+
+	proposals  map[uint32]*chain.Proposal
+	blocks     map[string]*chain.Block // hash -> блок з proposal
+	validity   map[string]bool         // hash -> результат fullBlockVerefication
+	prevotes   map[uint32]map[string]chain.Vote
+	precommits map[uint32]map[string]chain.Vote
+
+	proposed          bool
+	proposeDeadline   time.Time // нульові, поки відлік не почався
+	prevoteDeadline   time.Time
+	precommitDeadline time.Time
+}
+
+func (n *Node) consensusLoop() {
+	if err := n.enterHeight(); err != nil {
+		log.Fatal().Err(err).Msg("помилка початку консенсусу")
+	}
+
+	ticker := time.NewTicker(consensusTick)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case message := <-n.messagesQueue:
+			switch message.Type {
+			case MsgBlockProposal:
+				n.handleMsgBlockProposal(message.Data)
+			case MsgCommit:
+				n.handleMsgCommit(message.Data)
+			}
+		case v := <-n.vote:
+			n.addVote(&v)
+		case <-ticker.C:
+			n.onConsensusTick()
+		case <-n.ctx.Done():
+			return
+		}
+	}
+}
+
+// enterHeight починає консенсус для блоку після останнього блоку в базі
+func (n *Node) enterHeight() error {
+	lastBlock, err := n.bs.GetLastBlock()
+	if err != nil {
+		return err
+	}
+	set, err := n.bs.GetValidatorSet()
+	if err != nil {
+		return err
+	}
+	validators := set.Validators
+	params, err := n.bs.GetParams()
+	if err != nil {
+		return err
+	}
+	totalStake, err := chain.TotalStake(validators)
+	if err != nil {
+		return err
+	}
+	seed, err := n.bs.GetRandaoMix()
+	if err != nil {
+		return err
+	}
+
+	n.cs = consensusState{
+		height:      lastBlock.Height + 1,
+		seed:        seed,
+		emptyAt:     params.EmptyBlockAt(lastBlock.Timestamp),
+		validators:  validators,
+		totalStake:  totalStake,
+		params:      params,
+		lockedRound: -1,
+		validRound:  -1,
+		proposals:   make(map[uint32]*chain.Proposal),
+		blocks:      make(map[string]*chain.Block),
+		validity:    make(map[string]bool),
+		prevotes:    make(map[uint32]map[string]chain.Vote),
+		precommits:  make(map[uint32]map[string]chain.Vote),
+	}
+
+	// після перезапуску продовжую з раунду, в якому вже підписував, і враховую збережений підпис
+	if !n.signState.signed(n.cs.height) {
+		return n.enterRound(0)
+	}
+	if err := n.enterRound(n.signState.Round); err != nil {
+		return err
+	}
+	n.restoreSigned()
+	return nil
+}
+
+// restoreSigned додає в стан консенсусу proposal або голос, який валідатор підписав до перезапуску.
+// Розсилаєтся він знову, коли консенсус дійде до того самого кроку
+func (n *Node) restoreSigned() {
+	if n.signState.Step == stepPropose {
+		var p chain.Proposal
+		if err := json.Unmarshal(n.signState.Message, &p); err != nil {
+			log.Error().Err(err).Msg("помилка розпаковки збереженого proposal")
+			return
+		}
+		n.recordProposal(&p)
+		return
+	}
+
+	var v chain.Vote
+	if err := json.Unmarshal(n.signState.Message, &v); err != nil {
+		log.Error().Err(err).Msg("помилка розпаковки збереженого голосу")
+		return
+	}
+	n.recordVote(&v)
+}
+
+func (n *Node) enterRound(round uint32) error {
+	proposer, err := chain.SelectProposer(n.cs.seed, n.cs.height, round, n.cs.validators)
+	if err != nil {
+		return err
+	}
+
+	n.cs.round = round
+	n.cs.step = stepPropose
+	n.cs.proposer = *proposer
+	n.cs.proposed = false
+	n.cs.proposeDeadline = time.Time{}
+	n.cs.prevoteDeadline = time.Time{}
+	n.cs.precommitDeadline = time.Time{}
+
+	log.Debug().Uint32("height", n.cs.height).Uint32("round", round).Hex("proposer", proposer.Address).Int64("stake", proposer.Amount).Msg("новий раунд")
+
+	// блок, за який вже було більше 2/3 prevote, пропонуєтся знову, навіть без нових транзакцій
+	if n.isProposer() && n.cs.validBlock != nil {
+		n.propose(n.cs.validBlock, n.cs.validRound)
+	}
+	return nil
+}
+
+func (n *Node) isProposer() bool {
+	return bytes.Equal(n.cs.proposer.Address, n.keys.Pub)
+}
+
+func (n *Node) onConsensusTick() {
+	// база могла змінитись через синхронізацію
+	lastBlock, err := n.bs.GetLastBlock()
+	if err != nil {
+		log.Error().Err(err).Msg("помилка отримання останнього блоку")
+		return
+	}
+	if lastBlock.Height+1 != n.cs.height {
+		if err := n.enterHeight(); err != nil {
+			log.Error().Err(err).Msg("помилка переходу на нову висоту")
+		}
+		return
+	}
+
+	now := time.Now()
+
+	if n.cs.step == stepPropose {
+		pending := n.getOnlyValidTransaction(n.mempool.Snapshot(), n.cs.height)
+		evidence := n.getOnlyValidEvidence(n.cs.height)
+		hasWork := len(pending) > 0 || len(evidence) > 0 || !now.Before(n.cs.emptyAt)
+
+		if n.isProposer() && !n.cs.proposed && hasWork {
+			block, err := n.createNewBlock(pending, evidence)
+			if err != nil {
+				// в цьому раунді блоку від мене не буде, інші валідатори перейдут в наступний раунд по таймауту
+				log.Error().Err(err).Uint32("height", n.cs.height).Uint32("round", n.cs.round).Msg("помилка створення блоку, пропускаю пропозицію")
+				n.cs.proposed = true
+			} else {
+				n.propose(block, -1)
+			}
+		}
+
+		if n.cs.proposeDeadline.IsZero() && (hasWork || n.roundHasActivity()) {
+			n.cs.proposeDeadline = now.Add(n.cs.params.ProposeTimeout(n.cs.round))
+		}
+	}
+
+	switch {
+	case n.cs.step == stepPropose && isExpired(n.cs.proposeDeadline, now):
+		log.Warn().Uint32("height", n.cs.height).Uint32("round", n.cs.round).Hex("proposer", n.cs.proposer.Address).Msg("блок не було отримано вчасно")
+		n.cs.step = stepPrevote
+		n.castVote(chain.VotePrevote, nil)
+	case n.cs.step == stepPrevote && isExpired(n.cs.prevoteDeadline, now):
+		n.cs.step = stepPrecommit
+		n.castVote(chain.VotePrecommit, nil)
+	case isExpired(n.cs.precommitDeadline, now):
+		log.Warn().Uint32("height", n.cs.height).Uint32("round", n.cs.round).Msg("раунд закінчився без рішення")
+		if err := n.enterRound(n.cs.round + 1); err != nil {
+			log.Error().Err(err).Msg("помилка переходу до наступного раунду")
+			return
+		}
+	}
+	n.advanceConsensus()
+}
+
+func isExpired(deadline time.Time, now time.Time) bool {
+	return !deadline.IsZero() && now.After(deadline)
+}
+
+func (n *Node) roundHasActivity() bool {
+	return n.cs.proposals[n.cs.round] != nil || len(n.cs.prevotes[n.cs.round]) > 0 || len(n.cs.precommits[n.cs.round]) > 0
+}
+
+// propose підписує і розсилає proposal з блоком. polRound -1 для нового блоку
+func (n *Node) propose(block *chain.Block, polRound int32) {
+	proposal := chain.Proposal{
+		Round:    n.cs.round,
+		POLRound: polRound,
+		Block:    *block,
+		Pub:      n.keys.Pub,
+	}
+	signed, err := n.signProposal(&proposal)
+	if err != nil {
+		// в цьому раунді блоку від мене не буде
+		log.Error().Err(err).Uint32("height", n.cs.height).Uint32("round", n.cs.round).Msg("помилка підпису proposal")
+		n.cs.proposed = true
+		return
+	}
+
+	msg, err := n.getMsgBlockProposalMsg(signed)
+	if err != nil {
+		log.Error().Err(err).Msg("помилка створення повідомлення з блоком")
+		return
+	}
+
+	n.cs.proposed = true
+	n.recordProposal(signed)
+
+	if err = n.topic.broadcast(msg, n.ctx); err != nil {
+		log.Error().Err(err).Msg("помилка трансляції нового блоку")
+	}
+}
+
+// addProposal зберігає отриманий proposal і застосовує правила консенсусу
+func (n *Node) addProposal(p *chain.Proposal) {
+	if p.Block.Height > n.cs.height {
+		n.syncBlockchain()
+		return
+	}
+	if n.recordProposal(p) {
+		n.advanceConsensus()
+	}
+}
+
+// recordProposal зберігає proposal поточної висоти від proposer його раунду
+func (n *Node) recordProposal(p *chain.Proposal) bool {
+	if p.Block.Height != n.cs.height {
+		return false
+	}
+	if p.Block.ChainID != n.chainID {
+		log.Warn().Str("chain_id", p.Block.ChainID).Msg("proposal для іншої мережі")
+		return false
+	}
+	proposer, err := chain.SelectProposer(n.cs.seed, n.cs.height, p.Round, n.cs.validators)
+	if err != nil {
+		log.Error().Err(err).Msg("помилка вибору proposer")
+		return false
+	}
+	if !bytes.Equal(p.Pub, proposer.Address) {
+		log.Warn().Hex("від", p.Pub).Hex("proposer", proposer.Address).Uint32("round", p.Round).Msg("proposal не від proposer раунду")
+		return false
+	}
+	if err := p.Verify(); err != nil {
+		log.Warn().Err(err).Hex("від", p.Pub).Msg("proposal не є валідним")
+		return false
+	}
+	if prev, ok := n.cs.proposals[p.Round]; ok {
+		// новий блок того самого раунду від того самого proposer - це подвійний підпис
+		if !bytes.Equal(prev.Block.Hash, p.Block.Hash) && prev.Block.Round == p.Block.Round {
+			n.reportEvidence(chain.NewBlockEvidence(&prev.Block, &p.Block))
+		}
+		return false
+	}
+
+	n.cs.proposals[p.Round] = p
+	n.cs.blocks[string(p.Block.Hash)] = &p.Block
+	return true
+}
+
+// addVote зберігає отриманий голос і застосовує правила консенсусу
+func (n *Node) addVote(v *chain.Vote) {
+	if n.recordVote(v) {
+		n.advanceConsensus()
+	}
+}
+
+// recordVote зберігає голос валідатора за поточну висоту
+func (n *Node) recordVote(v *chain.Vote) bool {
+	if v.Height != n.cs.height {
+		return false
+	}
+	if err := v.Verify(n.chainID); err != nil {
+		log.Warn().Err(err).Hex("від", v.Pub).Msg("голос не є валідним")
+		return false
+	}
+	if contains, _ := containsInValidators(v.Pub, &n.cs.validators); !contains {
+		return false
+	}
+
+	votes := n.cs.prevotes
+	if v.Type == chain.VotePrecommit {
+		votes = n.cs.precommits
+	}
+	roundVotes, ok := votes[v.Round]
+	if !ok {
+		roundVotes = make(map[string]chain.Vote)
+		votes[v.Round] = roundVotes
+	}
+
+	if prev, ok := roundVotes[string(v.Pub)]; ok {
+		if !bytes.Equal(prev.BlockHash, v.BlockHash) {
+			log.Warn().Hex("від", v.Pub).Stringer("type", v.Type).Uint32("round", v.Round).Msg("валідатор проголосував двічі за різні блоки")
+			n.reportEvidence(chain.NewVoteEvidence(&prev, v))
+		}
+		return false
+	}
+	roundVotes[string(v.Pub)] = *v
+	return true
+}
+
+// castVote голосує в поточному раунді, якщо ця нода валідатор. blockHash nil - голос за nil.
+// Правила консенсусу після цього застосовує той, хто викликав
+func (n *Node) castVote(voteType chain.VoteType, blockHash []byte) {
+	if contains, _ := containsInValidators(n.keys.Pub, &n.cs.validators); !contains {
+		return
+	}
+
+	vote := chain.Vote{
+		Type:      voteType,
+		Height:    n.cs.height,
+		Round:     n.cs.round,
+		BlockHash: blockHash,
+		Pub:       n.keys.Pub,
+	}
+	signed, err := n.signVote(&vote)
+	if errors.Is(err, errSignRegression) {
+		log.Warn().Stringer("type", voteType).Uint32("round", n.cs.round).Msg("не голосую, тому що вже підписав пізніший крок")
+		return
+	}
+	if err != nil {
+		log.Error().Err(err).Msg("помилка підпису голосу")
+		return
+	}
+
+	msg, err := n.getVoteMsg(signed)
+	if err != nil {
+		log.Error().Err(err).Msg("помилка створення повідомлення для голосування")
+		return
+	}
+
+	log.Debug().Stringer("type", voteType).Uint32("round", n.cs.round).Hex("block", signed.BlockHash).Msg("голосую")
+	n.recordVote(signed)
+
+	if err = n.topic.broadcast(msg, n.ctx); err != nil {
+		log.Error().Err(err).Msg("помилка розсилання повідомлення голосування")
+	}
+}
+
+// signProposal підписує proposal і зберігає його в signState до розсилання. Якщо на цьому кроці вже є підпис,
+// новий не робиться, а повертаєтся збережений proposal
+func (n *Node) signProposal(p *chain.Proposal) (*chain.Proposal, error) {
+	signBytes, err := p.SigningBytes()
+	if err != nil {
+		return nil, err
+	}
+	saved, err := n.signState.check(p.Block.Height, p.Round, stepPropose, signBytes)
+	if err != nil {
+		return nil, err
+	}
+	if saved != nil {
+		var prev chain.Proposal
+		if err := json.Unmarshal(saved, &prev); err != nil {
+			return nil, err
+		}
+		return &prev, nil
+	}
+
+	if err := p.Sign(n.keys.Priv); err != nil {
+		return nil, err
+	}
+	message, err := json.Marshal(p)
+	if err != nil {
+		return nil, err
+	}
+	if err := n.signState.save(p.Block.Height, p.Round, stepPropose, signBytes, message); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// signVote як signProposal, але для голосу
+func (n *Node) signVote(v *chain.Vote) (*chain.Vote, error) {
+	step := stepPrevote
+	if v.Type == chain.VotePrecommit {
+		step = stepPrecommit
+	}
+	signBytes := v.SigningBytes(n.chainID)
+	saved, err := n.signState.check(v.Height, v.Round, step, signBytes)
+	if err != nil {
+		return nil, err
+	}
+	if saved != nil {
+		var prev chain.Vote
+		if err := json.Unmarshal(saved, &prev); err != nil {
+			return nil, err
+		}
+		return &prev, nil
+	}
+
+	if err := v.Sign(n.chainID, n.keys.Priv); err != nil {
+		return nil, err
+	}
+	message, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	if err := n.signState.save(v.Height, v.Round, step, signBytes, message); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// reportEvidence додає доказ подвійного підпису в пул і розсилає його іншим нодам
+func (n *Node) reportEvidence(evidence *chain.Evidence) {
+	added, err := n.evidence.Add(evidence)
+	if err != nil {
+		log.Error().Err(err).Msg("доказ подвійного підпису не є валідним")
+		return
+	}
+	if !added {
+		return
+	}
+	log.Warn().Stringer("type", evidence.Type).Hex("валідатор", evidence.Offender()).Uint32("height", evidence.Height()).Msg("знайдено подвійний підпис")
+
+	msg, err := n.getEvidenceMsg(evidence)
+	if err != nil {
+		log.Error().Err(err).Msg("помилка створення повідомлення з доказом")
+		return
+	}
+	if err = n.topic.broadcast(msg, n.ctx); err != nil {
+		log.Error().Err(err).Msg("помилка розсилання доказу")
+	}
+}
+
+// advanceConsensus застосовує правила консенсусу, поки стан змінюєтся
+func (n *Node) advanceConsensus() {
+	height := n.cs.height
+	for n.cs.height == height && n.applyConsensusRule() {
+	}
+}
+
+// applyConsensusRule виконує перше правило, умови якого виконані, і повертає true, якщо стан змінився
+func (n *Node) applyConsensusRule() bool {
+	cs := &n.cs
+
+	// рішення: більше 2/3 precommit за блок в будь-якому раунді
+	for round, votes := range cs.precommits {
+		hash, ok := n.twoThirdsFor(votes)
+		if !ok || hash == "" {
+			continue
+		}
+		block := cs.blocks[hash]
+		if block == nil || !n.isValidBlock(block) {
+			continue
+		}
+		n.decideFromVotes(block, round)
+		return true
+	}
+
+	// більше 1/3 stake вже в пізнішому раунді, тому чекати цей раунд немає сенсу
+	for round := range cs.prevotes {
+		if round > cs.round && n.oneThirdInRound(round) {
+			return n.enterRound(round) == nil
+		}
+	}
+	for round := range cs.precommits {
+		if round > cs.round && n.oneThirdInRound(round) {
+			return n.enterRound(round) == nil
+		}
+	}
+
+	prevotes := cs.prevotes[cs.round]
+	polHash, hasPOL := n.twoThirdsFor(prevotes)
+
+	switch cs.step {
+	case stepPropose:
+		p := cs.proposals[cs.round]
+		if p == nil {
+			break
+		}
+		hash := string(p.Block.Hash)
+
+		if p.POLRound == -1 {
+			cs.step = stepPrevote
+			if n.isValidBlock(&p.Block) && (cs.lockedRound == -1 || string(cs.lockedBlock.Hash) == hash) {
+				n.castVote(chain.VotePrevote, p.Block.Hash)
+			} else {
+				n.castVote(chain.VotePrevote, nil)
+			}
+			return true
+		}
+
+		// блок знову запропоновано, тому що за нього вже було більше 2/3 prevote в раунді POLRound
+		if polPrevotes, ok := n.twoThirdsFor(cs.prevotes[uint32(p.POLRound)]); ok && polPrevotes == hash {
+			cs.step = stepPrevote
+			if n.isValidBlock(&p.Block) && (cs.lockedRound <= p.POLRound || string(cs.lockedBlock.Hash) == hash) {
+				n.castVote(chain.VotePrevote, p.Block.Hash)
+			} else {
+				n.castVote(chain.VotePrevote, nil)
+			}
+			return true
+		}
+
+	case stepPrevote:
+		if hasPOL && polHash != "" {
+			if block := cs.blocks[polHash]; block != nil && n.isValidBlock(block) {
+				cs.lockedBlock, cs.lockedRound = block, int32(cs.round)
+				cs.validBlock, cs.validRound = block, int32(cs.round)
+				cs.step = stepPrecommit
+				n.castVote(chain.VotePrecommit, block.Hash)
+				return true
+			}
+		}
+		if hasPOL && polHash == "" {
+			cs.step = stepPrecommit
+			n.castVote(chain.VotePrecommit, nil)
+			return true
+		}
+		if cs.prevoteDeadline.IsZero() && n.twoThirdsAny(prevotes) {
+			cs.prevoteDeadline = time.Now().Add(cs.params.VoteTimeout(cs.round))
+			return true
+		}
+
+	case stepPrecommit:
+		// більше 2/3 prevote за блок прийшли вже після мого precommit
+		if hasPOL && polHash != "" && cs.validRound < int32(cs.round) {
+			if block := cs.blocks[polHash]; block != nil && n.isValidBlock(block) {
+				cs.validBlock, cs.validRound = block, int32(cs.round)
+				return true
+			}
+		}
+	}
+
+	if cs.precommitDeadline.IsZero() && n.twoThirdsAny(cs.precommits[cs.round]) {
+		cs.precommitDeadline = time.Now().Add(cs.params.VoteTimeout(cs.round))
+		return true
+	}
+	return false
+}
+
+// decide застосовує блок разом з сертифікатом, який доводить, що блок прийнято
+func (n *Node) decide(block *chain.Block, cert *chain.CommitCertificate) {
+	if err := n.applyBlock(block, cert); err != nil {
+		log.Error().Err(err).Uint32("height", block.Height).Msg("помилка застосування блоку")
+		n.cs.validity[string(block.Hash)] = false
+		return
+	}
+	log.Info().Hex("block hash", block.Hash).Uint32("height", block.Height).Uint32("round", cert.Round).Msg("додано новий блок до ланцюжка")
+
+	n.promoteMempool(block.Height + 1)
+	n.evidence.Remove(block.Evidence)
+
+	if err := n.enterHeight(); err != nil {
+		log.Error().Err(err).Msg("помилка переходу на нову висоту")
+	}
+}
+
+// decideFromVotes приймає блок, за який більше 2/3 stake проголосували precommit в раунді round.
+// Proposer раунду розсилає commit для нод, які пропустили голоси
+func (n *Node) decideFromVotes(block *chain.Block, round uint32) {
+	votes := make([]chain.Vote, 0, len(n.cs.precommits[round]))
+	for _, v := range n.cs.precommits[round] {
+		votes = append(votes, v)
+	}
+	cert := chain.NewCommitCertificate(block, round, votes)
+
+	proposer, err := chain.SelectProposer(n.cs.seed, n.cs.height, round, n.cs.validators)
+	if err == nil && bytes.Equal(proposer.Address, n.keys.Pub) {
+		commitMsg, err := n.getCommitMsg(&Commit{Certificate: *cert, Block: *block})
+		if err != nil {
+			log.Error().Err(err).Msg("помилка створення повідомлення commit")
+		} else if err = n.topic.broadcast(commitMsg, n.ctx); err != nil {
+			log.Error().Err(err).Msg("помилка розсилання commit")
+		}
+	}
+
+	n.decide(block, cert)
+}
+
+func (n *Node) isValidBlock(block *chain.Block) bool {
+	hash := string(block.Hash)
+	if valid, ok := n.cs.validity[hash]; ok {
+		return valid
+	}
+
+	valid := n.fullBlockVerefication(block) == nil
+	n.cs.validity[hash] = valid
+	return valid
+}
+
+// votesPower рахує stake голосів: всього і за кожен hash блоку ("" - nil)
+func (n *Node) votesPower(votes map[string]chain.Vote) (int64, map[string]int64) {
+	var total int64
+	byHash := make(map[string]int64)
+	for _, v := range votes {
+		_, validator := containsInValidators(v.Pub, &n.cs.validators)
+		if validator == nil {
+			continue
+		}
+		total += validator.Amount
+		byHash[string(v.BlockHash)] += validator.Amount
+	}
+	return total, byHash
+}
+
+// twoThirdsFor повертає hash ("" - nil), за який проголосували більше 2/3 stake
+func (n *Node) twoThirdsFor(votes map[string]chain.Vote) (string, bool) {
+	_, byHash := n.votesPower(votes)
+	for hash, power := range byHash {
+		if chain.HasTwoThirds(power, n.cs.totalStake) {
+			return hash, true
+		}
+	}
+	return "", false
+}
+
+func (n *Node) twoThirdsAny(votes map[string]chain.Vote) bool {
+	total, _ := n.votesPower(votes)
+	return chain.HasTwoThirds(total, n.cs.totalStake)
+}
+
+// oneThirdInRound чи валідатори з більше ніж 1/3 stake вже голосували в раунді round
+func (n *Node) oneThirdInRound(round uint32) bool {
+	signers := make(map[string]chain.Vote)
+	for pub, v := range n.cs.prevotes[round] {
+		signers[pub] = v
+	}
+	for pub, v := range n.cs.precommits[round] {
+		signers[pub] = v
+	}
+	total, _ := n.votesPower(signers)
+	return chain.HasOneThird(total, n.cs.totalStake)
+}
@@ -1,19 +1,23 @@
 package p2p
 
-import "github.com/libp2p/go-libp2p/core/protocol"
+import (
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/protocol"
+)
 
 const (
-	// wallets
-	STAKE        = "stake"
-	REWARDWALLET = "reward"
-	REWARD       = int64(1)
+	// consensus
+	consensusTick = 100 * time.Millisecond // як часто consensusLoop перевіряє таймаут і mempool
 
 	// network
-	ns                         = "PQlite_test"
+	nsPrefix                   = "PQlite_"        // простір імен для пошуку peer в DHT, до нього додаєтся chain id
+	topicPrefix                = "pqlite/gossip/" // назва gossip topic, до неї додаєтся chain id
 	directProtocol protocol.ID = "/pqlite/direct/1.0.0"
-)
 
-var BOOTSTRAPLIST = [2]string{
-	"/ip6/2603:c020:8020:57e:0:ad30:3238:982b/tcp/4003/p2p/12D3KooWC3MYiZuijTDt18e29z8Fh1S7ZzkWzU9JCY7kDpm83RMY",
-	"/ip4/158.101.175.51/tcp/4003/p2p/12D3KooWC3MYiZuijTDt18e29z8Fh1S7ZzkWzU9JCY7kDpm83RMY",
-}
+	// найбільше повідомлення - відповідь на синхронізацію, див. maxMessageSize
+	syncBatchBlocks      = 1        // скільки блоків з сертифікатами в одній відповіді на синхронізацію
+	jsonExpansion        = 3        // у скільки разів json блоку або сертифікату може бути більшим за канонічне представлення (голоси - в 2.5)
+	blockJSONOverhead    = 4 << 10  // hash, підпис і назви полів блоку, які не входять в Block.Size
+	messageEnvelopeBytes = 64 << 10 // решта полів Message: тип, timestamp, публічний ключ і підпис
+)
@@ -0,0 +1,46 @@
+package p2p
+
+import (
+	"github.com/PQlite/core/chain"
+	"github.com/PQlite/core/database"
+	"github.com/rs/zerolog/log"
+)
+
+// Набір валідаторів (хто створює блоки, хто голосує і з якою вагою) зберігаєтся в стані окремо від
+// валідаторів і змінюєтся тільки після останнього блоку епохи. Bond, unbond, штрафи і unjail
+// всередині епохи змінюют stake і статус валідатора, але не набір, тому для будь-якої висоти
+// proposer і кворум однакові на всіх нодах, незалежно від того, синхронізуєтся нода чи ні.
+// Покараний валідатор залишаєтся в наборі до кінця епохи, але його пропуски вже не рахуются
+
+// endBlock запамʼятовує набір, який створив блок b, і на межі епохи будує новий з валідаторів в стані.
+// Якщо активних валідаторів не залишилось, набір не змінюєтся, інакше мережа вже не створить жодного блоку
+func endBlock(st *database.StateTxn, b *chain.Block) error {
+	set, err := st.GetValidatorSet()
+	if err != nil {
+		return err
+	}
+	if err := st.SetLastValidatorSet(set); err != nil {
+		return err
+	}
+
+	params, err := st.GetParams()
+	if err != nil {
+		return err
+	}
+	if !params.IsEpochEnd(b.Height) {
+		return nil
+	}
+
+	validators, err := st.GetValidatorsList()
+	if err != nil {
+		return err
+	}
+	next := chain.NewValidatorSet(validators)
+	if len(next.Validators) == 0 {
+		log.Warn().Uint32("висота", b.Height).Msg("немає активних валідаторів для нової епохи, набір не змінюєтся")
+		return nil
+	}
+
+	log.Debug().Uint32("висота", b.Height).Int("валідатори", len(next.Validators)).Hex("validators hash", next.Hash()).Msg("новий набір валідаторів епохи")
+	return st.SetValidatorSet(next)
+}
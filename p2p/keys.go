@@ -1,9 +1,11 @@
 package p2p
 
 import (
+	"bytes"
 	"crypto/rand"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"os"
 
 	"github.com/PQlite/crypto"
@@ -16,14 +18,17 @@ type Keys struct {
 	Pub  []byte `json:"pub"`
 }
 
+// Де ключі зберігались до data dir, відносно робочої директорії. Їх треба перенести, інакше нода
+// після оновлення створить нові ключі і валідатор втратить свою адресу і stake
 const (
-	filePath = ".env"
+	legacyKeysFile    = ".env"
+	legacyNodeKeyFile = ".node.key"
 )
 
 // NOTE: я швидко писав, тому може бути не дуже
 
-func save(priv []byte, pub []byte) error {
-	file, err := os.OpenFile(filePath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+func save(filePath string, priv []byte, pub []byte) error {
+	file, err := os.OpenFile(filePath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
 	if err != nil {
 		return err
 	}
@@ -42,8 +47,52 @@ func save(priv []byte, pub []byte) error {
 	return err
 }
 
-func LoadKeys() (*Keys, error) {
-	_, err := os.Stat(filePath)
+// importLegacyKey копіює ключ зі старого розташування legacy в path, якщо в path ключа ще немає.
+// legacy, який valid не вважає ключем, пропускаєтся: .env в робочій директорії може бути чужим файлом.
+// Якщо ключі є в обох місцях і вони різні, невідомо, який з них правельний, тому нода не запускаєтся
+func importLegacyKey(legacy string, path string, valid func(data []byte) bool) error {
+	old, err := os.ReadFile(legacy)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("помилка читання старого ключа %s: %w", legacy, err)
+	}
+	if !valid(old) {
+		log.Debug().Str("file", legacy).Msg("файл в старому розташуванні не є ключем, пропускаю")
+		return nil
+	}
+
+	current, err := os.ReadFile(path)
+	if err == nil {
+		if !bytes.Equal(current, old) {
+			return fmt.Errorf("знайдено різні ключі в %s і %s: залиште тільки правельний", legacy, path)
+		}
+		return nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	if err := os.WriteFile(path, old, 0600); err != nil {
+		return fmt.Errorf("помилка перенесення ключа %s в %s: %w", legacy, path, err)
+	}
+	log.Warn().Str("from", legacy).Str("to", path).Msg("ключ перенесено в data dir, старий файл можна видалити")
+	return nil
+}
+
+// LoadKeys завантажує ключі валідатора з filePath, або створює нові, якщо файлу немає.
+// Ключі зі старого розташування ./.env спочатку переносяться в filePath
+func LoadKeys(filePath string) (*Keys, error) {
+	err := importLegacyKey(legacyKeysFile, filePath, func(data []byte) bool {
+		var keys Keys
+		return json.Unmarshal(data, &keys) == nil && len(keys.Priv) != 0 && len(keys.Pub) != 0
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = os.Stat(filePath)
 	if err == nil {
 		data, err := os.ReadFile(filePath)
 		if err != nil {
@@ -62,7 +111,7 @@ func LoadKeys() (*Keys, error) {
 			return nil, err
 		}
 
-		err = save(priv, pub)
+		err = save(filePath, priv, pub)
 		if err != nil {
 			return nil, err
 		}
@@ -70,7 +119,17 @@ func LoadKeys() (*Keys, error) {
 	}
 }
 
+// LoadOrCreateIdentity завантажує libp2p ключ ноди з path, або створює новий. Ключ зі старого
+// розташування ./.node.key спочатку переноситься в path, щоб не змінився peer id
 func LoadOrCreateIdentity(path string) (libp2pcrypto.PrivKey, error) {
+	err := importLegacyKey(legacyNodeKeyFile, path, func(data []byte) bool {
+		_, err := libp2pcrypto.UnmarshalPrivateKey(data)
+		return err == nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
 	// HACK: це написав ChatGPT
 	// Перевіряємо, чи вже існує ключ
 	if _, err := os.Stat(path); err == nil {
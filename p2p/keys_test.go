@@ -0,0 +1,87 @@
+package p2p
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestImportLegacyKey(t *testing.T) {
+	priv, pub := testKey(t, 1)
+	keys, err := json.Marshal(Keys{Priv: priv, Pub: pub})
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherPriv, otherPub := testKey(t, 2)
+	other, err := json.Marshal(Keys{Priv: otherPriv, Pub: otherPub})
+	if err != nil {
+		t.Fatal(err)
+	}
+	valid := func(data []byte) bool {
+		var k Keys
+		return json.Unmarshal(data, &k) == nil && len(k.Priv) != 0
+	}
+
+	tests := []struct {
+		name    string
+		legacy  []byte // nil - файлу немає
+		current []byte
+		want    []byte // що буде в новому розташуванні, nil - файлу немає
+		wantErr bool
+	}{
+		{"старого ключа немає", nil, nil, nil, false},
+		{"перенесення", keys, nil, keys, false},
+		{"вже перенесено", keys, keys, keys, false},
+		{"новий ключ без старого", nil, other, other, false},
+		{"різні ключі", keys, other, other, true},
+		{"чужий .env", []byte("API_TOKEN=secret\n"), nil, nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			legacy, path := filepath.Join(dir, ".env"), filepath.Join(dir, "data", "keys.json")
+			if err := os.Mkdir(filepath.Dir(path), 0700); err != nil {
+				t.Fatal(err)
+			}
+			for file, data := range map[string][]byte{legacy: tt.legacy, path: tt.current} {
+				if data == nil {
+					continue
+				}
+				if err := os.WriteFile(file, data, 0600); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			err := importLegacyKey(legacy, path, valid)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("помилка %v, очікувалась помилка: %v", err, tt.wantErr)
+			}
+
+			got, err := os.ReadFile(path)
+			if tt.want == nil {
+				if !os.IsNotExist(err) {
+					t.Errorf("в новому розташуванні зʼявився файл: %q", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, tt.want) {
+				t.Errorf("в новому розташуванні %q, очікувалось %q", got, tt.want)
+			}
+			if tt.current != nil {
+				return
+			}
+			info, err := os.Stat(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if info.Mode().Perm() != 0600 {
+				t.Errorf("перенесений ключ має права %v, очікувалось 0600", info.Mode().Perm())
+			}
+		})
+	}
+}
@@ -0,0 +1,220 @@
+package p2p
+
+import (
+	"fmt"
+
+	"github.com/PQlite/core/chain"
+	"github.com/PQlite/core/database"
+	"github.com/rs/zerolog/log"
+)
+
+// Пропуски валідаторів рахуются з блоків, тому всі ноди рахують їх однаково:
+//   - пропущений голос: валідатора немає в LastCommit блоку, тобто його precommit за попередній блок
+//     не потрапив в сертифікат
+//   - пропущений блок: валідатор був proposer раунду, меншого за раунд блоку, тобто в його раунді блок не вийшов
+//
+// Хто за останні SignedBlocksWindow висот пропустив більше MaxMissedVotes голосів або MaxMissedProposals блоків,
+// втрачає SlashFractionDowntime stake і з наступної епохи виключаєтся з консенсусу на JailDuration блоків.
+// Після цього він повертаєтся транзакцією chain.TxUnjail
+
+// buildLastCommit сертифікат блоку height-1 для блоку height. Його підписав той самий набір, який
+// перевірятиме LastCommit (vs_last), тому сертифікат можна взяти з бази без змін
+func (n *Node) buildLastCommit(height uint32) (*chain.CommitCertificate, error) {
+	if height < 2 {
+		return nil, nil
+	}
+	return n.bs.GetCommit(height - 1)
+}
+
+// verifyLastCommit перевіряє LastCommit блоку b набором validators, який створив попередній блок,
+// і повертає адреси валідаторів, які за попередній блок голосували
+func (n *Node) verifyLastCommit(validators []chain.Validator, b *chain.Block) (map[string]bool, error) {
+	signed := make(map[string]bool)
+	if b.Height < 2 {
+		if b.LastCommit != nil {
+			return nil, fmt.Errorf("блок %d не може мати LastCommit", b.Height)
+		}
+		return signed, nil
+	}
+	if b.LastCommit == nil {
+		return nil, fmt.Errorf("блок %d без сертифікату попереднього блоку", b.Height)
+	}
+
+	prev, err := n.bs.GetBlock(b.Height - 1)
+	if err != nil {
+		return nil, err
+	}
+	if err := b.LastCommit.VerifyBlock(prev); err != nil {
+		return nil, err
+	}
+	if err := b.LastCommit.Verify(validators, chain.HasTwoThirds); err != nil {
+		return nil, err
+	}
+
+	for _, v := range b.LastCommit.Precommits {
+		signed[string(v.Pub)] = true
+	}
+	return signed, nil
+}
+
+// updateLiveness записує пропуски валідаторів за блок b і карає тих, хто пропустив забагато.
+// Голоси за попередній блок рахуются для набору, який його створив (vs_last), а пропущені блоки - для набору
+// цього блоку. Виконуєтся першим в executeBlock, тому обидва набори ще ті, з якими працювали ці висоти
+func (n *Node) updateLiveness(st *database.StateTxn, b *chain.Block) error {
+	params, err := st.GetParams()
+	if err != nil {
+		return err
+	}
+	lastSet, err := st.GetLastValidatorSet()
+	if err != nil {
+		return err
+	}
+	set, err := st.GetValidatorSet()
+	if err != nil {
+		return err
+	}
+
+	signed, err := n.verifyLastCommit(lastSet.Validators, b)
+	if err != nil {
+		return err
+	}
+
+	// пропуски рахуются тільки для валідаторів з наборів, які ще не покарані
+	window := params.SignedBlocksWindow
+	var tracked []*chain.Validator
+	infos := make(map[string]*chain.LivenessInfo)
+	for _, members := range [][]chain.Validator{lastSet.Validators, set.Validators} {
+		for _, m := range members {
+			if infos[string(m.Address)] != nil {
+				continue
+			}
+			v, err := st.GetValidator(m.Address)
+			if err != nil {
+				return err
+			}
+			if v == nil || v.Jailed {
+				continue
+			}
+			info, err := st.GetLiveness(v.Address)
+			if err != nil {
+				return err
+			}
+			if info == nil {
+				info = chain.NewLivenessInfo(v.Address, window)
+			}
+			infos[string(v.Address)] = info
+			tracked = append(tracked, v)
+		}
+	}
+
+	if b.Height >= 2 {
+		for _, m := range lastSet.Validators {
+			if info := infos[string(m.Address)]; info != nil {
+				info.MarkVote(b.Height-1, !signed[string(m.Address)], window)
+			}
+		}
+	}
+
+	// reveal цього блоку ще не застосований, тому mix той самий, з якого вибирались proposer раундів
+	mix, err := st.GetRandaoMix()
+	if err != nil {
+		return err
+	}
+	for round := uint32(0); round < b.Round; round++ {
+		proposer, err := chain.SelectProposer(mix, b.Height, round, set.Validators)
+		if err != nil {
+			return err
+		}
+		if info := infos[string(proposer.Address)]; info != nil {
+			info.AddMissedProposal(b.Height, window)
+		}
+	}
+
+	// останнього активного валідатора не карають, інакше наступна епоха залишиться без валідаторів
+	validators, err := st.GetValidatorsList()
+	if err != nil {
+		return err
+	}
+	remaining := len(chain.ActiveValidators(validators))
+	for _, v := range tracked {
+		info := infos[string(v.Address)]
+
+		missedTooMuch := int64(info.MissedVotesCount) > params.MaxMissedVotes || info.MissedProposalsCount(b.Height, window) > params.MaxMissedProposals
+		if missedTooMuch && remaining > 1 {
+			remaining--
+			if err := jailForDowntime(st, v, params, b.Height); err != nil {
+				return err
+			}
+			info.Reset(window)
+		}
+		if err := st.SetLiveness(info); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func jailForDowntime(st *database.StateTxn, v *chain.Validator, params *chain.ConsensusParams, height uint32) error {
+	v.Jailed = true
+	v.JailedUntil = height + uint32(params.JailDuration)
+	// пропуски накопичуются за все вікно, тому порушенням вважаєтся висота, на якій їх стало забагато
+	slash, err := slashValidator(st, v, params.SlashFractionDowntime, height, height)
+	if err != nil {
+		return err
+	}
+
+	log.Info().Hex("валідатор", v.Address).Int64("спалено", slash).Uint32("до висоти", v.JailedUntil).Msg("валідатора покарано за пропуски")
+	return nil
+}
+
+// canUnjail перевіряє, чи може валідатор addr повернутись в консенсус на висоті height
+func canUnjail(st *database.StateTxn, addr []byte, height uint32) error {
+	validator, err := st.GetValidator(addr)
+	if err != nil {
+		return err
+	}
+	switch {
+	case validator == nil:
+		return fmt.Errorf("%x не є валідатором", addr)
+	case !validator.Jailed:
+		return fmt.Errorf("валідатор %x не покараний", addr)
+	case validator.Tombstoned:
+		return fmt.Errorf("валідатор %x покараний за подвійний підпис і не може повернутись", addr)
+	case validator.Amount == 0:
+		return fmt.Errorf("валідатор %x не має stake", addr)
+	case height < validator.JailedUntil:
+		return fmt.Errorf("валідатор %x може повернутись тільки з висоти %d", addr, validator.JailedUntil)
+	}
+	return nil
+}
+
+// unjail повертає валідатора в консенсус і забуває його пропуски. Перевіряє це canUnjail
+func unjail(st *database.StateTxn, addr []byte) error {
+	params, err := st.GetParams()
+	if err != nil {
+		return err
+	}
+
+	validator, err := st.GetValidator(addr)
+	if err != nil {
+		return err
+	}
+	validator.Jailed = false
+	if err := st.SetValidator(validator); err != nil {
+		return err
+	}
+
+	info, err := st.GetLiveness(addr)
+	if err != nil {
+		return err
+	}
+	if info != nil {
+		info.Reset(params.SignedBlocksWindow)
+		if err := st.SetLiveness(info); err != nil {
+			return err
+		}
+	}
+
+	log.Info().Hex("валідатор", addr).Msg("валідатор повернувся в консенсус")
+	return nil
+}
@@ -0,0 +1,264 @@
+package p2p
+
+import (
+	"bytes"
+	"slices"
+	"testing"
+
+	"github.com/PQlite/core/chain"
+	"github.com/PQlite/core/database"
+)
+
+// testLivenessState зберігає блок 1 зі станом з валідаторами seed 1 (stake 700) і seed 2 (stake 300) в обох наборах
+// і повертає стан для блоку 2. Валідатори з jailed вже покарані, але ще в наборах поточної епохи
+func testLivenessState(t *testing.T, n *Node, params *chain.ConsensusParams, jailed []byte) (*database.StateTxn, *chain.Block) {
+	t.Helper()
+	st := n.bs.NewStateTxn()
+	defer st.Discard()
+	if err := st.SetParams(params); err != nil {
+		t.Fatal(err)
+	}
+
+	var validators []chain.Validator
+	for seed, amount := range map[byte]int64{1: 700, 2: 300} {
+		_, pub := testKey(t, seed)
+		v := chain.Validator{Address: pub, Amount: amount, DelegatorShares: amount}
+		if err := st.SetValidator(&v); err != nil {
+			t.Fatal(err)
+		}
+		validators = append(validators, v)
+	}
+	slices.SortFunc(validators, func(a, b chain.Validator) int { return bytes.Compare(a.Address, b.Address) })
+	set := chain.NewValidatorSet(validators)
+	if err := st.SetValidatorSet(set); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.SetLastValidatorSet(set); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.SetRandaoMix(bytes.Repeat([]byte{0x11}, 32)); err != nil {
+		t.Fatal(err)
+	}
+	for _, seed := range jailed {
+		_, pub := testKey(t, seed)
+		v, err := st.GetValidator(pub)
+		if err != nil {
+			t.Fatal(err)
+		}
+		v.Jailed, v.JailedUntil = true, 100
+		if err := st.SetValidator(v); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	_, proposer := testKey(t, 1)
+	b := &chain.Block{BlockHeader: chain.BlockHeader{ChainID: "local", Height: 1, Timestamp: 1, Proposer: proposer}}
+	root, err := st.StateRoot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.StateRoot = root
+	if err := b.GenerateHash(); err != nil {
+		t.Fatal(err)
+	}
+	if err := n.bs.ApplyBlock(b, nil, st); err != nil {
+		t.Fatal(err)
+	}
+
+	next := n.bs.NewStateTxn()
+	t.Cleanup(next.Discard)
+	return next, b
+}
+
+// testLastCommit сертифікат блоку prev з precommit валідаторів signers
+func testLastCommit(t *testing.T, prev *chain.Block, signers []byte) *chain.CommitCertificate {
+	t.Helper()
+	c := &chain.CommitCertificate{ChainID: prev.ChainID, Height: prev.Height, BlockHash: prev.Hash}
+	for _, seed := range signers {
+		priv, pub := testKey(t, seed)
+		v := chain.Vote{Type: chain.VotePrecommit, Height: prev.Height, BlockHash: prev.Hash, Pub: pub}
+		if err := v.Sign(prev.ChainID, priv); err != nil {
+			t.Fatal(err)
+		}
+		c.Precommits = append(c.Precommits, v)
+	}
+	return c
+}
+
+func TestUpdateLiveness(t *testing.T) {
+	tests := []struct {
+		name               string
+		signers            []byte // хто підписав LastCommit
+		round              uint32
+		maxMissedVotes     int64
+		jailedBefore       []byte
+		wantJailed         []byte // хто покараний за пропуски в цьому блоці, 0 - proposer раунду 0
+		wantMissedVotesOf2 uint32
+	}{
+		{"всі голосували", []byte{1, 2}, 0, 0, nil, nil, 0},
+		{"пропущений голос", []byte{1}, 0, 0, nil, []byte{2}, 0}, // після покарання пропуски забуваются
+		{"пропуски в межах", []byte{1}, 0, 1, nil, nil, 1},
+		{"пропущений блок", []byte{1, 2}, 1, 1, nil, []byte{0}, 0},
+		{"останній активний валідатор", []byte{1}, 0, 0, []byte{1}, nil, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := testNode(t)
+			params := &chain.ConsensusParams{
+				SignedBlocksWindow:    10,
+				MaxMissedVotes:        tt.maxMissedVotes,
+				MaxMissedProposals:    0,
+				SlashFractionDowntime: 100,
+				JailDuration:          20,
+			}
+			st, prev := testLivenessState(t, n, params, tt.jailedBefore)
+
+			mix, err := st.GetRandaoMix()
+			if err != nil {
+				t.Fatal(err)
+			}
+			set, err := st.GetValidatorSet()
+			if err != nil {
+				t.Fatal(err)
+			}
+			roundZero, err := chain.SelectProposer(mix, 2, 0, set.Validators)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			b := &chain.Block{BlockHeader: chain.BlockHeader{ChainID: "local", Height: 2, Round: tt.round}, LastCommit: testLastCommit(t, prev, tt.signers)}
+			if err := n.updateLiveness(st, b); err != nil {
+				t.Fatal(err)
+			}
+
+			for seed, stake := range map[byte]int64{1: 700, 2: 300} {
+				_, pub := testKey(t, seed)
+				v, err := st.GetValidator(pub)
+				if err != nil {
+					t.Fatal(err)
+				}
+				want := slices.Contains(tt.wantJailed, seed) || (slices.Contains(tt.wantJailed, 0) && bytes.Equal(pub, roundZero.Address))
+				if !want {
+					if v.Jailed != slices.Contains(tt.jailedBefore, seed) || v.Amount != stake {
+						t.Errorf("валідатор %d покарано: %+v", seed, v)
+					}
+					continue
+				}
+				if !v.Jailed || v.JailedUntil != 22 || v.Amount != stake-stake/100 {
+					t.Errorf("валідатор %d не покарано за пропуски: %+v", seed, v)
+				}
+			}
+
+			_, pub := testKey(t, 2)
+			info, err := st.GetLiveness(pub)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if info.MissedVotesCount != tt.wantMissedVotesOf2 {
+				t.Errorf("валідатор 2 пропустив %d голосів, очікувалось %d", info.MissedVotesCount, tt.wantMissedVotesOf2)
+			}
+		})
+	}
+}
+
+func TestUpdateLivenessRejectsLastCommit(t *testing.T) {
+	n := testNode(t)
+	st, prev := testLivenessState(t, n, &chain.ConsensusParams{SignedBlocksWindow: 10}, nil)
+
+	other := *prev
+	other.Timestamp = 2
+	if err := other.GenerateHash(); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		lastCommit *chain.CommitCertificate
+	}{
+		{"без LastCommit", nil},
+		{"менше 2/3 stake", testLastCommit(t, prev, []byte{2})},
+		{"сертифікат іншого блоку", testLastCommit(t, &other, []byte{1, 2})},
+		{"не валідатор", testLastCommit(t, prev, []byte{1, 3})},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := &chain.Block{BlockHeader: chain.BlockHeader{ChainID: "local", Height: 2}, LastCommit: tt.lastCommit}
+			if n.updateLiveness(st, b) == nil {
+				t.Error("блок з не валідним LastCommit пройшов перевірку")
+			}
+		})
+	}
+}
+
+func TestCanUnjail(t *testing.T) {
+	_, pub := testKey(t, 1)
+	tests := []struct {
+		name      string
+		validator *chain.Validator
+		height    uint32
+		wantErr   bool
+	}{
+		{"покарання закінчилось", &chain.Validator{Address: pub, Amount: 100, Jailed: true, JailedUntil: 50}, 50, false},
+		{"покарання ще триває", &chain.Validator{Address: pub, Amount: 100, Jailed: true, JailedUntil: 50}, 49, true},
+		{"не валідатор", nil, 50, true},
+		{"не покараний", &chain.Validator{Address: pub, Amount: 100}, 50, true},
+		{"подвійний підпис", &chain.Validator{Address: pub, Amount: 100, Jailed: true, JailedUntil: 50, Tombstoned: true}, 50, true},
+		{"без stake", &chain.Validator{Address: pub, Jailed: true, JailedUntil: 50}, 50, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := testNode(t)
+			st := n.bs.NewStateTxn()
+			defer st.Discard()
+			if tt.validator != nil {
+				if err := st.SetValidator(tt.validator); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := canUnjail(st, pub, tt.height); (err != nil) != tt.wantErr {
+				t.Errorf("помилка %v, очікувалась помилка: %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// Після unjail валідатор починає рахувати пропуски з нуля
+func TestUnjail(t *testing.T) {
+	n := testNode(t)
+	st := n.bs.NewStateTxn()
+	defer st.Discard()
+	_, pub := testKey(t, 1)
+
+	if err := st.SetParams(&chain.ConsensusParams{SignedBlocksWindow: 10}); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.SetValidator(&chain.Validator{Address: pub, Amount: 100, Jailed: true, JailedUntil: 50}); err != nil {
+		t.Fatal(err)
+	}
+	info := chain.NewLivenessInfo(pub, 10)
+	info.MarkVote(3, true, 10)
+	info.AddMissedProposal(3, 10)
+	if err := st.SetLiveness(info); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := unjail(st, pub); err != nil {
+		t.Fatal(err)
+	}
+	v, err := st.GetValidator(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.Jailed || v.Amount != 100 {
+		t.Errorf("валідатор після unjail: %+v", v)
+	}
+	if info, err = st.GetLiveness(pub); err != nil {
+		t.Fatal(err)
+	}
+	if info.MissedVotesCount != 0 || len(info.MissedProposals) != 0 {
+		t.Errorf("після unjail залишились пропуски: %+v", info)
+	}
+	if canUnjail(st, pub, 50) == nil {
+		t.Error("валідатор повернувся в консенсус двічі")
+	}
+}
@@ -1,6 +1,7 @@
 package p2p
 
 import (
+	"encoding/base64"
 	"encoding/json"
 	"time"
 
@@ -17,17 +18,17 @@ const (
 
 	// Блоки
 	MsgNewBlock     MessageType = "newBlock"
-	MsgRequestBlock MessageType = "requestBlock"
-	MsgResponeBlock MessageType = "responeBlock" // data - block
+	MsgRequestBlock MessageType = "requestBlock" // data - chain.Block, з якого береться тільки висота
+	MsgResponeBlock MessageType = "responeBlock" // data - Commit (блок разом з його сертифікатом)
 
 	// Mempool sync
 	MsgMempool MessageType = "mempool"
 
 	// PoS
-	MsgBlockProposal MessageType = "blockProposal"
-	MsgVote          MessageType = "vote"
-	MsgCommit        MessageType = "commit"
-	MsgReject        MessageType = "reject"
+	MsgBlockProposal MessageType = "blockProposal" // data - chain.Proposal
+	MsgVote          MessageType = "vote"          // data - chain.Vote (prevote або precommit)
+	MsgCommit        MessageType = "commit"        // data - Commit
+	MsgEvidence      MessageType = "evidence"      // data - chain.Evidence (доказ подвійного підпису)
 
 	// Валідатори
 	MsgValidatorSet    MessageType = "validatorSet"
@@ -43,10 +44,21 @@ type Message struct {
 	Signature []byte      `json:"signature"` // підпис відправника
 }
 
-// OPTIMIZE: треба видалити, тому що відправка блока 2 рази це не дуже ефективно
+// maxMessageSize найбільше повідомлення, яке нода приймає від peer (через gossip або потік).
+// Найбільше з них - відповідь на синхронізацію: syncBatchBlocks блоків з сертифікатами, закодовані json,
+// а потім ще раз base64 в Data. Блок не більший за MaxBlockBytes, а сертифікат блоку потім стає LastCommit
+// наступного блоку, тому теж не більший за MaxBlockBytes. Запропонований блок і голоси менші за це
+func maxMessageSize(params *chain.ConsensusParams) int64 {
+	commit := 2 * (jsonExpansion*params.MaxBlockBytes + blockJSONOverhead)
+	data := base64.StdEncoding.EncodedLen(int(syncBatchBlocks * commit))
+	return int64(data) + messageEnvelopeBytes
+}
+
+// Commit блок разом з сертифікатом, який доводить, що блок прийнято. Так блоки отримуют ноди,
+// які пропустили голоси, і ноди, які синхронізуются
 type Commit struct {
-	Voters []chain.Vote `json:"voters"`
-	Block  chain.Block  `json:"block"`
+	Certificate chain.CommitCertificate `json:"certificate"`
+	Block       chain.Block             `json:"block"`
 }
 
 func (m *Message) sign(priv []byte) error {
@@ -86,10 +98,8 @@ func (m *Message) verify() bool {
 	return true
 }
 
-func (n *Node) getMsgBlockProposalMsg() (*Message, error) {
-	newBlock := n.createNewBlock()
-
-	newBlockBytes, err := json.Marshal(newBlock)
+func (n *Node) getMsgBlockProposalMsg(proposal *chain.Proposal) (*Message, error) {
+	proposalBytes, err := json.Marshal(proposal)
 	if err != nil {
 		log.Error().Err(err).Msg("помилка розпаковки нового блоку")
 		return nil, err
@@ -98,7 +108,7 @@ func (n *Node) getMsgBlockProposalMsg() (*Message, error) {
 	blockProposalMsg := Message{
 		Type:      MsgBlockProposal,
 		Timestamp: time.Now().UnixMilli(),
-		Data:      newBlockBytes,
+		Data:      proposalBytes,
 		Pub:       n.keys.Pub,
 	}
 
@@ -111,12 +121,7 @@ func (n *Node) getMsgBlockProposalMsg() (*Message, error) {
 	return &blockProposalMsg, nil
 }
 
-func (n *Node) getCommitMsg(voters *[]chain.Vote, b *chain.Block) (*Message, error) {
-	commit := Commit{
-		Voters: *voters,
-		Block:  *b,
-	}
-
+func (n *Node) getCommitMsg(commit *Commit) (*Message, error) {
 	commitBytes, err := json.Marshal(commit)
 	if err != nil {
 		return nil, err
@@ -137,26 +142,36 @@ func (n *Node) getCommitMsg(voters *[]chain.Vote, b *chain.Block) (*Message, err
 }
 
 // TODO: додати логування
-func (n *Node) getVoteMsg(blockBytes []byte) (*Message, error) {
-	sig, err := crypto.Sign(n.keys.Priv, blockBytes)
+func (n *Node) getVoteMsg(vote *chain.Vote) (*Message, error) {
+	voteBytes, err := json.Marshal(vote)
 	if err != nil {
 		return nil, err
 	}
 
-	vote := chain.Vote{
+	msg := Message{
+		Type:      MsgVote,
+		Timestamp: time.Now().UnixMilli(),
+		Data:      voteBytes,
 		Pub:       n.keys.Pub,
-		Signature: sig,
 	}
 
-	voteBytes, err := json.Marshal(vote)
+	if err = msg.sign(n.keys.Priv); err != nil {
+		return nil, err
+	}
+
+	return &msg, nil
+}
+
+func (n *Node) getEvidenceMsg(evidence *chain.Evidence) (*Message, error) {
+	evidenceBytes, err := json.Marshal(evidence)
 	if err != nil {
 		return nil, err
 	}
 
 	msg := Message{
-		Type:      MsgVote,
+		Type:      MsgEvidence,
 		Timestamp: time.Now().UnixMilli(),
-		Data:      voteBytes,
+		Data:      evidenceBytes,
 		Pub:       n.keys.Pub,
 	}
 
@@ -0,0 +1,137 @@
+package p2p
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/json"
+	"math"
+	"testing"
+
+	"github.com/PQlite/core/chain"
+)
+
+func testKey(t *testing.T, seed byte) (ed25519.PrivateKey, ed25519.PublicKey) {
+	t.Helper()
+	priv := ed25519.NewKeyFromSeed(bytes.Repeat([]byte{seed}, ed25519.SeedSize))
+	return priv, priv.Public().(ed25519.PublicKey)
+}
+
+// testFullCommit блок з txs транзакцій, votes голосами в LastCommit і evidence доказами, разом з сертифікатом
+// від votes валідаторів. Всі числа найбільші, щоб в json вони займали найбільше місця
+func testFullCommit(t *testing.T, txs int, votes int, evidence int) *Commit {
+	t.Helper()
+	priv, pub := testKey(t, 1)
+
+	b := chain.Block{
+		BlockHeader: chain.BlockHeader{
+			ChainID:        "local",
+			Height:         math.MaxUint32,
+			Round:          math.MaxUint32,
+			Timestamp:      math.MinInt64,
+			PrevHash:       bytes.Repeat([]byte{0xaa}, 28),
+			ValidatorsHash: bytes.Repeat([]byte{0xbb}, 32),
+			Proposer:       pub,
+			RandaoReveal:   bytes.Repeat([]byte{0xcc}, 32),
+			RandaoCommit:   bytes.Repeat([]byte{0xdd}, 32),
+			StateRoot:      bytes.Repeat([]byte{0xee}, 32),
+		},
+	}
+	for range txs {
+		tx := &chain.Transaction{ChainID: "local", From: pub, Amount: math.MinInt64, Fee: math.MinInt64, Timestamp: math.MinInt64, Nonce: math.MaxUint32}
+		if err := tx.Sign(priv); err != nil {
+			t.Fatal(err)
+		}
+		b.Transactions = append(b.Transactions, tx)
+	}
+
+	cert := func() chain.CommitCertificate {
+		c := chain.CommitCertificate{ChainID: "local", Height: math.MaxUint32, Round: math.MaxUint32, BlockHash: bytes.Repeat([]byte{0x11}, 28)}
+		for i := range votes {
+			vPriv, vPub := testKey(t, byte(i+2))
+			v := chain.Vote{Type: chain.VotePrecommit, Height: c.Height, Round: c.Round, BlockHash: c.BlockHash, Pub: vPub}
+			if err := v.Sign("local", vPriv); err != nil {
+				t.Fatal(err)
+			}
+			c.Precommits = append(c.Precommits, v)
+		}
+		return c
+	}
+	lastCommit := cert()
+	b.LastCommit = &lastCommit
+
+	for range evidence {
+		a := chain.Vote{Type: chain.VotePrevote, Height: math.MaxUint32, Round: math.MaxUint32, BlockHash: bytes.Repeat([]byte{0x01}, 28), Pub: pub}
+		c := a
+		c.BlockHash = nil
+		if err := a.Sign("local", priv); err != nil {
+			t.Fatal(err)
+		}
+		if err := c.Sign("local", priv); err != nil {
+			t.Fatal(err)
+		}
+		b.Evidence = append(b.Evidence, chain.NewVoteEvidence(&a, &c))
+	}
+
+	b.LastCommitHash = b.ComputeLastCommitHash()
+	b.TxRoot = b.ComputeTxRoot()
+	b.EvidenceRoot = b.ComputeEvidenceRoot()
+	if err := b.Sign(priv); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.GenerateHash(); err != nil {
+		t.Fatal(err)
+	}
+
+	return &Commit{Block: b, Certificate: cert()}
+}
+
+// Відповідь на синхронізацію з найбільшим блоком, який дозволяют параметри, має вміщатись в maxMessageSize
+func TestMaxMessageSizeFitsSyncResponse(t *testing.T) {
+	priv, pub := testKey(t, 1)
+
+	tests := []struct {
+		name     string
+		txs      int
+		votes    int
+		evidence int
+	}{
+		{"порожній блок", 0, 0, 0},
+		{"тільки сертифікат", 0, 100, 0},
+		{"тільки транзакції", 200, 1, 0},
+		{"тільки докази", 0, 1, 100},
+		{"все разом", 100, 50, 50},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			commit := testFullCommit(t, tt.txs, tt.votes, tt.evidence)
+
+			// найменші параметри, з якими цей блок і його сертифікат ще валідні
+			certSize := int64(len(commit.Certificate.MarshalDeterministic()))
+			params := &chain.ConsensusParams{MaxBlockBytes: max(commit.Block.Size(), certSize)}
+
+			blockJSON, err := json.Marshal(commit.Block)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if limit := jsonExpansion*commit.Block.Size() + blockJSONOverhead; int64(len(blockJSON)) > limit {
+				t.Errorf("json блоку %d байт, більше за %d", len(blockJSON), limit)
+			}
+
+			data, err := json.Marshal(commit)
+			if err != nil {
+				t.Fatal(err)
+			}
+			m := Message{Type: MsgResponeBlock, Timestamp: math.MinInt64, Data: data, Pub: pub}
+			if err := m.sign(priv); err != nil {
+				t.Fatal(err)
+			}
+			msg, err := json.Marshal(m)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if size := int64(len(msg)) + 1; size > maxMessageSize(params) {
+				t.Errorf("відповідь %d байт, maxMessageSize %d", size, maxMessageSize(params))
+			}
+		})
+	}
+}
@@ -3,91 +3,132 @@ package p2p
 import (
 	"bytes"
 	"fmt"
+	"math"
 	"time"
 
 	"github.com/PQlite/core/chain"
+	"github.com/PQlite/core/database"
 	"github.com/rs/zerolog/log"
 )
 
-func (n *Node) chooseValidator() (chain.Validator, error) {
+// chooseValidator повертає proposer для наступного блоку в раунді round
+func (n *Node) chooseValidator(round uint32) (chain.Validator, error) {
 	lastBlock, err := n.bs.GetLastBlock()
 	if err != nil {
 		return chain.Validator{}, fmt.Errorf("помилка отримання останнього блоку: %w", err)
 	}
-	validators, err := n.bs.GetValidatorsList()
+	set, err := n.bs.GetValidatorSet()
 	if err != nil {
-		return chain.Validator{}, fmt.Errorf("помилка отримання списку валідаторів: %w", err)
+		return chain.Validator{}, fmt.Errorf("помилка отримання набору валідаторів: %w", err)
 	}
 
-	nextProposer, err := chain.SelectNextProposer(lastBlock.Hash, *validators)
+	mix, err := n.bs.GetRandaoMix()
+	if err != nil {
+		return chain.Validator{}, fmt.Errorf("помилка отримання randao mix: %w", err)
+	}
+
+	proposer, err := chain.SelectProposer(mix, lastBlock.Height+1, round, set.Validators)
 	if err != nil {
 		return chain.Validator{}, err
 	}
 
-	return *nextProposer, nil
+	return *proposer, nil
 }
 
-func (n *Node) createNewBlock() chain.Block {
+// createNewBlock створює блок поточного раунду з транзакцій pending і доказів evidence,
+// які вже перевірені getOnlyValidTransaction і getOnlyValidEvidence
+func (n *Node) createNewBlock(pending []*chain.Transaction, evidence []*chain.Evidence) (*chain.Block, error) {
 	lastBlock, err := n.bs.GetLastBlock()
 	if err != nil {
-		log.Fatal().Err(err).Msg("помилка отримання останнього блоку")
+		return nil, fmt.Errorf("помилка отримання останнього блоку: %w", err)
 	}
 
-	log.Info().Msg("очікування транзакцій для нового блоку")
+	log.Info().Int("pending", len(pending)).Msg("кількість транзакцій для нового блоку")
 
-	// очікування транзакцій для блоку
-	for {
-		n.mempool.TXs = n.getOnlyValidTransaction(n.mempool.TXs)
-		if n.mempool.Len() > 0 {
-			break
-		}
-		time.Sleep(100 * time.Millisecond)
+	lastCommit, err := n.buildLastCommit(lastBlock.Height + 1)
+	if err != nil {
+		return nil, fmt.Errorf("помилка отримання сертифікату попереднього блоку: %w", err)
+	}
+	set, err := n.bs.GetValidatorSet()
+	if err != nil {
+		return nil, fmt.Errorf("помилка отримання набору валідаторів: %w", err)
+	}
+	reveal, commit, err := n.randaoForBlock()
+	if err != nil {
+		return nil, fmt.Errorf("помилка отримання randao секрету: %w", err)
+	}
+	params, err := n.bs.GetParams()
+	if err != nil {
+		return nil, fmt.Errorf("помилка отримання параметрів консенсусу: %w", err)
 	}
-
-	log.Info().Int("mempool", n.mempool.Len()).Msg("кількість транзакцій в mempool")
 
 	block := chain.Block{
-		Height:       lastBlock.Height + 1,
-		Timestamp:    time.Now().UnixMilli(),
-		PrevHash:     lastBlock.Hash,
-		Proposer:     n.keys.Pub,
-		Transactions: n.mempool.TXs,
+		BlockHeader: chain.BlockHeader{
+			ChainID:        n.chainID,
+			Height:         lastBlock.Height + 1,
+			Round:          n.cs.round,
+			Timestamp:      max(time.Now().UnixMilli(), lastBlock.Timestamp+1),
+			PrevHash:       lastBlock.Hash,
+			ValidatorsHash: set.Hash(),
+			Proposer:       n.keys.Pub,
+			RandaoReveal:   reveal,
+			RandaoCommit:   commit,
+		},
+		LastCommit: lastCommit,
 	}
-
-	n.addRewardTx(&block)
-
-	if err = block.Sign(n.keys.Priv); err != nil {
-		log.Fatal().Err(err).Msg("помилка підпису блоку")
+	block.LastCommitHash = block.ComputeLastCommitHash()
+	block.EvidenceRoot = block.ComputeEvidenceRoot()
+	block.TxRoot = block.ComputeTxRoot()
+
+	// всі hash`і в заголовку вже мають остаточну довжину, крім state_root, який такої ж довжини, як tx_root.
+	// докази додаются першими, а транзакції займають те, що залишилось від MaxBlockBytes. Те, що вже не вміщуєтся,
+	// пропускаєтся, але менші за нього ще можуть вміститись
+	size := block.Size() + int64(len(block.TxRoot))
+	for _, e := range evidence {
+		evidenceSize := int64(len(e.MarshalDeterministic()))
+		if size+evidenceSize > params.MaxBlockBytes {
+			continue
+		}
+		block.Evidence = append(block.Evidence, e)
+		size += evidenceSize
 	}
+	block.EvidenceRoot = block.ComputeEvidenceRoot()
+
+	// транзакції з більшою fee йдуть першими. після сортування ще раз перевіряю на стані,
+	// який вже змінили пропуски і докази цього блоку, тому що транзакції виконуются по черзі і порядок змінився
+	txs := make([]*chain.Transaction, 0, len(pending))
+	for _, tx := range pending {
+		if tx.Size() <= params.MaxTxBytes {
+			txs = append(txs, tx)
+		}
+	}
+	chain.SortByFee(txs)
 
-	block.GenerateHash()
+	st := n.bs.NewStateTxn()
+	defer st.Discard()
+	if err := n.beginBlock(st, &block); err != nil {
+		return nil, fmt.Errorf("помилка виконання нового блоку: %w", err)
+	}
+	block.Transactions = n.selectValidTransactions(st, txs, block.Height, int(params.MaxBlockTxs), params.MaxBlockBytes-size)
+	block.TxRoot = block.ComputeTxRoot()
+	block.TxCount = uint32(len(block.Transactions))
 
-	return block
-}
+	if block.StateRoot, err = n.computeStateRoot(&block); err != nil {
+		return nil, fmt.Errorf("помилка виконання нового блоку: %w", err)
+	}
 
-func (n *Node) addRewardTx(b *chain.Block) {
-	tx := chain.Transaction{
-		From:      []byte(REWARDWALLET),
-		To:        n.keys.Pub,
-		Amount:    REWARD,
-		Timestamp: time.Now().UnixMilli(),
-		Nonce:     0,
+	if err = block.Sign(n.keys.Priv); err != nil {
+		return nil, fmt.Errorf("помилка підпису блоку: %w", err)
 	}
 
-	err := tx.Sign(n.keys.Priv)
-	if err != nil {
-		log.Fatal().Err(err).Msg("помилка підпису транзакції")
+	if err = block.GenerateHash(); err != nil {
+		return nil, fmt.Errorf("помилка генерації hash`у блоку: %w", err)
 	}
 
-	b.Transactions = append(b.Transactions, &tx)
+	return &block, nil
 }
 
 func (n *Node) fullBlockVerefication(block *chain.Block) error {
-	// Чи правельний творець блоку
-	if !bytes.Equal(block.Proposer, n.nextProposer.Address) {
-		log.Error().Hex("творець блоку", block.Proposer).Hex("хто повинен робити блок", n.nextProposer.Address).Msg("творець блоку і той, хто повинен робити блок, не збігаются")
-		return fmt.Errorf("err")
-	}
 	// чи правельна висота блоку який був отриманий (на один більше попереднього)
 	lastLocalBlock, err := n.bs.GetLastBlock()
 	if err != nil {
@@ -99,8 +140,40 @@ func (n *Node) fullBlockVerefication(block *chain.Block) error {
 		n.syncBlockchain()
 		return fmt.Errorf("err")
 	}
+	// Чи timestamp після попереднього блоку і не в майбутньому
+	params, err := n.bs.GetParams()
+	if err != nil {
+		return err
+	}
+	if err := params.VerifyTimestamp(block.Timestamp, lastLocalBlock.Timestamp, time.Now()); err != nil {
+		log.Error().Err(err).Int64("timestamp блоку", block.Timestamp).Msg("timestamp блоку не правельний")
+		return err
+	}
+	// Чи блок не більший за ліміти параметрів консенсусу
+	if err := params.VerifyBlockSize(block); err != nil {
+		log.Error().Err(err).Msg("блок перевищує ліміти розміру")
+		return err
+	}
+	// Чи блок для набору валідаторів поточної епохи
+	set, err := n.bs.GetValidatorSet()
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(block.ValidatorsHash, set.Hash()) {
+		log.Error().Hex("validators hash блоку", block.ValidatorsHash).Hex("локальний validators hash", set.Hash()).Msg("набір валідаторів блоку не збігаєтся з локальним")
+		return fmt.Errorf("validators hash не збігаєтся")
+	}
+	// Чи правельний творець блоку для раунду блоку
+	proposer, err := n.chooseValidator(block.Round)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(block.Proposer, proposer.Address) {
+		log.Error().Hex("творець блоку", block.Proposer).Hex("хто повинен робити блок", proposer.Address).Uint32("round", block.Round).Msg("творець блоку і той, хто повинен робити блок, не збігаются")
+		return fmt.Errorf("err")
+	}
 	// Перевірка підпису і hash`у
-	if err := block.Verify(); err != nil {
+	if err := block.Verify(n.chainID); err != nil {
 		log.Error().Err(err).Hex("proposer", block.Proposer).Msg("валідація підпису блоку не пройшла")
 		return fmt.Errorf("err")
 	}
@@ -109,147 +182,323 @@ func (n *Node) fullBlockVerefication(block *chain.Block) error {
 		log.Error().Err(err).Msg("верефікаця транзакцій блоку не пройшла")
 		return err
 	}
-	// Перевірка балансів і Nonce`ів усіх транзакцій
-	if err := n.checkBalances(block.Transactions); err != nil {
+	// Виконання транзакцій (баланси і Nonce`и) і перевірка state root
+	stateRoot, err := n.computeStateRoot(block)
+	if err != nil {
 		log.Error().Err(err).Msg("помилка перевірки бланасів/nonce транзакцій")
 		return err
 	}
+	if !bytes.Equal(stateRoot, block.StateRoot) {
+		log.Error().Hex("локальний state root", stateRoot).Hex("state root блоку", block.StateRoot).Msg("state root блоку не збігаєтся з локальним виконанням")
+		return fmt.Errorf("state root не збігаєтся")
+	}
 
 	return nil
 }
 
-func (n *Node) setNextProposer() error {
-	nextProposer, err := n.chooseValidator()
-	if err != nil {
-		log.Error().Err(err).Msg("помилка вибору наступного валідатора")
-		return err
+func (n *Node) validateTx(st *database.StateTxn, tx *chain.Transaction, height uint32) error {
+	if tx.Amount < 0 || tx.Fee < 0 {
+		return fmt.Errorf("сума і fee транзакції не можуть бути від'ємними")
 	}
-	n.nextProposer = nextProposer
-	log.Debug().Hex("proposer", n.nextProposer.Address).Int64("баланс", n.nextProposer.Amount).Msg("наступний proposer")
-	return nil
-}
-
-func (n *Node) validateTx(tx *chain.Transaction) error {
-	// Перевірка транзакції нагороди
-	if bytes.Equal(tx.From, []byte(REWARDWALLET)) {
-		if tx.Amount != REWARD {
-			return fmt.Errorf("транзакція нагороди має не правельну нагороду")
-		}
-		return nil
+	if tx.Kind != chain.TxTransfer && tx.Kind != chain.TxBond && tx.Kind != chain.TxUnbond && len(tx.To) != 0 {
+		return fmt.Errorf("транзакція %s не може мати отримувача", tx.Kind)
 	}
 
-	wallet, err := n.bs.GetWalletByAddress(tx.From)
+	wallet, err := st.GetWallet(tx.From)
 	if err != nil {
 		return fmt.Errorf("помилка отримання даних про гаманець: %w", err)
 	}
 
-	// Не вистачає балансу
-	if wallet.Balance < tx.Amount {
-		return fmt.Errorf("гаманець не має достатньої кількість грошей для переказу")
-	}
 	// Nonce не правельний
 	if tx.Nonce != wallet.Nonce+1 {
 		return fmt.Errorf("транзакція має не правельний Nonce: %d, коли Nonce гаманця це: %d", tx.Nonce, wallet.Nonce)
 	}
 
-	return nil
+	total, err := tx.Debit()
+	if err != nil {
+		return err
+	}
+	// Не вистачає балансу
+	if wallet.Balance < total {
+		return fmt.Errorf("гаманець не має достатньої кількість грошей для переказу")
+	}
+
+	switch tx.Kind {
+	case chain.TxTransfer:
+		if len(tx.To) == 0 {
+			return fmt.Errorf("переказ без отримувача")
+		}
+		return nil
+	case chain.TxBond:
+		return canBond(st, tx)
+	case chain.TxUnbond:
+		return canUnbond(st, tx)
+	case chain.TxWithdraw:
+		return canWithdraw(st, tx, height)
+	case chain.TxUnjail:
+		if tx.Amount != 0 {
+			return fmt.Errorf("транзакція unjail не може мати суму")
+		}
+		return canUnjail(st, tx.From, height)
+	case chain.TxCommission:
+		return canSetCommission(st, tx)
+	}
+	return fmt.Errorf("невідомий тип транзакції: %d", tx.Kind)
 }
 
-func (n *Node) getOnlyValidTransaction(txs []*chain.Transaction) []*chain.Transaction {
-	validTxs := make([]*chain.Transaction, 0, len(txs))
+// getOnlyValidTransaction повертає транзакції, які можна виконати одна за одною на поточному стані в блоці height
+func (n *Node) getOnlyValidTransaction(txs []*chain.Transaction, height uint32) []*chain.Transaction {
+	st := n.bs.NewStateTxn()
+	defer st.Discard()
+
+	return n.selectValidTransactions(st, txs, height, len(txs), math.MaxInt64)
+}
+
+// promoteMempool оновлює mempool після блоку, коли наступний блок буде на висоті height.
+// Pending транзакції кожного відправника виконуются на окремій копії нового стану
+func (n *Node) promoteMempool(height uint32) {
+	n.mempool.Promote(func(txs []*chain.Transaction) (int, error) {
+		st := n.bs.NewStateTxn()
+		defer st.Discard()
+
+		for i, tx := range txs {
+			if err := n.applyTx(st, tx, height); err != nil {
+				return i, err
+			}
+		}
+		return len(txs), nil
+	})
+}
+
+// selectValidTransactions виконує на st транзакції, які можна виконати одна за одною, і повертає їх.
+// Вибираєтся не більше maxTxs транзакцій сумарним розміром не більше maxBytes, транзакції, які вже не вміщуются, пропускаются
+func (n *Node) selectValidTransactions(st *database.StateTxn, txs []*chain.Transaction, height uint32, maxTxs int, maxBytes int64) []*chain.Transaction {
+	validTxs := make([]*chain.Transaction, 0, min(len(txs), maxTxs))
+	var size int64
 	for _, tx := range txs {
-		if err := n.validateTx(tx); err == nil {
+		if len(validTxs) == maxTxs {
+			break
+		}
+		txSize := tx.Size()
+		if size+txSize > maxBytes {
+			continue
+		}
+		if err := n.applyTx(st, tx, height); err == nil {
 			validTxs = append(validTxs, tx)
+			size += txSize
 		}
 	}
 	return validTxs
 }
 
-func (n *Node) checkBalances(txs []*chain.Transaction) error {
-	for _, tx := range txs {
-		if err := n.validateTx(tx); err != nil {
-			return err
-		}
+// getOnlyValidEvidence повертає докази з пулу, які можна додати в блок на висоті height.
+// Докази, які вже ніколи не стануть валідними, видаляются з пулу
+func (n *Node) getOnlyValidEvidence(height uint32) []*chain.Evidence {
+	pool := n.evidence.Snapshot()
+	if len(pool) == 0 {
+		return nil
 	}
-	return nil
-}
 
-func (n *Node) updateBalancesNonces(b *chain.Block) error {
-	for _, tx := range b.Transactions {
-		// HACK: не найкраще рішення, через повторення логіки
-		// HACK: якщо той, хто робить блок, відправить транзакцію то Nonce оновится 2 рази
-		if bytes.Equal(tx.From, []byte(STAKE)) || bytes.Equal(tx.From, []byte(REWARDWALLET)) {
-			walletTo, err := n.bs.GetWalletByAddress(tx.To)
-			if err != nil {
-				return err
-			}
-			walletTo.Balance += tx.Amount
-			walletTo.Nonce++
-			n.bs.UpdateBalance(&walletTo)
+	st := n.bs.NewStateTxn()
+	defer st.Discard()
+
+	var valid, stale []*chain.Evidence
+	for _, e := range pool {
+		if e.Height() > height {
 			continue
 		}
-		walletFrom, err := n.bs.GetWalletByAddress(tx.From)
-		if err != nil {
-			return err
-		}
-		walletTo, err := n.bs.GetWalletByAddress(tx.To)
-		if err != nil {
-			return err
+		if err := n.applyEvidence(st, height, e); err != nil {
+			log.Debug().Err(err).Hex("валідатор", e.Offender()).Msg("доказ не може бути доданий в блок")
+			stale = append(stale, e)
+			continue
 		}
+		valid = append(valid, e)
+	}
+	n.evidence.Remove(stale)
+	return valid
+}
 
-		// оновлюю баланси
-		walletFrom.Balance -= tx.Amount
-		walletTo.Balance += tx.Amount
+// applyEvidence перевіряє доказ подвійного підпису і карає валідатора: спалює частину його stake
+// і виключає з консенсусу. Вже покараний валідатор вдруге не карається, тому такий доказ не валідний
+func (n *Node) applyEvidence(st *database.StateTxn, height uint32, e *chain.Evidence) error {
+	if err := e.Verify(n.chainID); err != nil {
+		return err
+	}
 
-		// додаю +1 до Nonce
-		walletFrom.Nonce++
+	params, err := st.GetParams()
+	if err != nil {
+		return err
+	}
+	if e.Height() > height {
+		return fmt.Errorf("доказ для висоти %d, яка ще не настала", e.Height())
+	}
+	if int64(height-e.Height()) > params.EvidenceMaxAge {
+		return fmt.Errorf("доказ для висоти %d застарів", e.Height())
+	}
 
-		if err := n.bs.UpdateBalance(&walletFrom); err != nil {
-			return err
-		}
-		if err := n.bs.UpdateBalance(&walletTo); err != nil {
+	exists, err := st.HasEvidence(e)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return fmt.Errorf("доказ вже був в блоці")
+	}
+
+	validator, err := st.GetValidator(e.Offender())
+	if err != nil {
+		return err
+	}
+	if validator == nil {
+		return fmt.Errorf("порушник %x не є валідатором", e.Offender())
+	}
+	if validator.Tombstoned {
+		return fmt.Errorf("валідатор %x вже покараний", e.Offender())
+	}
+
+	validator.Jailed = true
+	validator.Tombstoned = true
+	slash, err := slashValidator(st, validator, params.SlashFractionDoubleSign, e.Height(), height)
+	if err != nil {
+		return err
+	}
+	log.Info().Hex("валідатор", validator.Address).Int64("спалено", slash).Int64("stake", validator.Amount).Msg("валідатора покарано за подвійний підпис")
+
+	return st.SetEvidence(e)
+}
+
+// beginBlock виконує те, що в блоці йде перед транзакціями: пропуски валідаторів і докази
+func (n *Node) beginBlock(st *database.StateTxn, b *chain.Block) error {
+	if err := n.updateLiveness(st, b); err != nil {
+		return err
+	}
+	if err := applyRandao(st, b); err != nil {
+		return err
+	}
+	for _, e := range b.Evidence {
+		if err := n.applyEvidence(st, b.Height, e); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
-func (n *Node) addValidatorsToDB(block *chain.Block) error {
-	// ISSUE: треба додавати баланс до валідатора, якщо він вже існує, а не перезаписувати його
-	for _, tx := range block.Transactions {
-		if bytes.Equal(tx.To, []byte(STAKE)) {
-			validator, _ := n.bs.GetValidator(tx.From)
-			if validator != nil {
-				log.Info().Int64("був", validator.Amount).Int64("став", validator.Amount+tx.Amount).Msg("оновлено баланс валідатора")
-				validator.Amount += tx.Amount
-			} else {
-				validator = &chain.Validator{
-					Address: tx.From,
-					Amount:  tx.Amount,
-				}
-				log.Info().Int64("amount", validator.Amount).Msg("додано валідатора")
-			}
+// executeBlock виконує блок на стані st: пропуски валідаторів, докази, потім транзакції по черзі, нагорода
+// і на межі епохи новий набір валідаторів. Кожна транзакція перевіряєтся на стані після попередніх.
+// Зберігати чи відкидати st вирішує той, хто викликав
+func (n *Node) executeBlock(st *database.StateTxn, b *chain.Block) error {
+	if err := n.beginBlock(st, b); err != nil {
+		return err
+	}
+	if err := n.updateBalancesNonces(st, b); err != nil {
+		return err
+	}
+	return endBlock(st, b)
+}
 
-			if err := n.bs.AddValidator(validator); err != nil {
-				return err
-			}
+// computeStateRoot виконує блок на тимчасовому стані і повертає state root після нього. База не змінюєтся
+func (n *Node) computeStateRoot(b *chain.Block) ([]byte, error) {
+	st := n.bs.NewStateTxn()
+	defer st.Discard()
 
-		}
+	if err := n.executeBlock(st, b); err != nil {
+		return nil, err
 	}
-	return nil
+	return st.StateRoot()
 }
 
-func (n *Node) deleteValidatorsFromDB(block *chain.Block) error {
-	for _, tx := range block.Transactions {
-		if bytes.Equal(tx.From, []byte("unstake")) {
-			validator, err := n.bs.GetValidator(tx.To)
-			if err != nil {
-				panic(err)
-				return err
-			}
+// applyBlock виконує блок, перевіряє state root і атомарно зберігає блок разом з сертифікатом і новим станом
+func (n *Node) applyBlock(b *chain.Block, cert *chain.CommitCertificate) error {
+	st := n.bs.NewStateTxn()
+	defer st.Discard()
+
+	if err := n.executeBlock(st, b); err != nil {
+		return err
+	}
+
+	stateRoot, err := st.StateRoot()
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(stateRoot, b.StateRoot) {
+		return fmt.Errorf("state root блоку %d не збігаєтся з локальним виконанням", b.Height)
+	}
+
+	return n.bs.ApplyBlock(b, cert, st)
+}
 
-			n.bs.DeleteValidator(validator)
+// applyTx перевіряє транзакцію на стані st блоку height і виконує її. Fee тут тільки списуєтся.
+// Якщо транзакція не виконалась до кінця, всі її зміни відкидаются, і st залишаєтся як до неї
+func (n *Node) applyTx(st *database.StateTxn, tx *chain.Transaction, height uint32) error {
+	snapshot := st.Snapshot()
+	err := n.executeTx(st, tx, height)
+	if err == nil {
+		return nil
+	}
+	if revertErr := st.RevertToSnapshot(snapshot); revertErr != nil {
+		return fmt.Errorf("%w, а відкинути її зміни не вдалось: %w", err, revertErr)
+	}
+	return err
+}
+
+func (n *Node) executeTx(st *database.StateTxn, tx *chain.Transaction, height uint32) error {
+	if err := n.validateTx(st, tx, height); err != nil {
+		return err
+	}
+
+	total, err := tx.Debit()
+	if err != nil {
+		return err
+	}
+
+	// спочатку списую і зберігаю, а потім читаю отримувача, щоб переказ самому собі працював правельно
+	walletFrom, err := st.GetWallet(tx.From)
+	if err != nil {
+		return err
+	}
+	if err := walletFrom.Debit(total); err != nil {
+		return err
+	}
+	// додаю +1 до Nonce
+	walletFrom.Nonce++
+	if err := st.SetWallet(&walletFrom); err != nil {
+		return err
+	}
+
+	switch tx.Kind {
+	case chain.TxBond:
+		return bond(st, stakingValidator(tx), tx.From, tx.Amount)
+	case chain.TxUnbond:
+		return unbond(st, stakingValidator(tx), tx.From, tx.Amount, height)
+	case chain.TxWithdraw:
+		return withdraw(st, tx.From, height)
+	case chain.TxUnjail:
+		return unjail(st, tx.From)
+	case chain.TxCommission:
+		return setCommission(st, tx.From, tx.Amount)
+	}
+
+	walletTo, err := st.GetWallet(tx.To)
+	if err != nil {
+		return err
+	}
+	if err := walletTo.Credit(tx.Amount); err != nil {
+		return err
+	}
+	return st.SetWallet(&walletTo)
+}
+
+func (n *Node) updateBalancesNonces(st *database.StateTxn, b *chain.Block) error {
+	var fees int64
+	for _, tx := range b.Transactions {
+		if err := n.applyTx(st, tx, b.Height); err != nil {
+			return err
+		}
+
+		var err error
+		if fees, err = chain.AddAmount(fees, tx.Fee); err != nil {
+			return err
 		}
 	}
-	return nil
+
+	return payBlockRewards(st, b, fees)
 }
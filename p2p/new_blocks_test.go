@@ -0,0 +1,219 @@
+package p2p
+
+import (
+	"bytes"
+	"math"
+	"testing"
+
+	"github.com/PQlite/core/chain"
+	"github.com/PQlite/core/database"
+)
+
+func testNode(t *testing.T) *Node {
+	t.Helper()
+	bs, err := database.InitDB(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { bs.Close() })
+	return &Node{bs: bs}
+}
+
+// Транзакція, яка не виконалась до кінця, не залишає в стані списання з відправника
+func TestApplyTxRevertsOnFailure(t *testing.T) {
+	_, from := testKey(t, 1)
+	_, to := testKey(t, 2)
+
+	tests := []struct {
+		name      string
+		toBalance int64
+		amount    int64
+		wantErr   bool
+	}{
+		{"переказ", 0, 100, false},
+		{"баланс отримувача переповнюєтся", math.MaxInt64 - 50, 100, true},
+		{"баланс отримувача на межі", math.MaxInt64 - 100, 100, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := testNode(t)
+			st := n.bs.NewStateTxn()
+			defer st.Discard()
+			for _, w := range []chain.Wallet{{Address: from, Balance: 1000}, {Address: to, Balance: tt.toBalance}} {
+				if err := st.SetWallet(&w); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			snapshot := st.Snapshot()
+			tx := &chain.Transaction{Kind: chain.TxTransfer, From: from, To: to, Amount: tt.amount, Fee: 1, Nonce: 1}
+			err := n.applyTx(st, tx, 1)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("помилка %v, очікувалась помилка: %v", err, tt.wantErr)
+			}
+
+			sender, err2 := st.GetWallet(from)
+			if err2 != nil {
+				t.Fatal(err2)
+			}
+			wantBalance, wantNonce := int64(1000), uint32(0)
+			if !tt.wantErr {
+				wantBalance, wantNonce = 1000-tt.amount-1, 1
+			}
+			if sender.Balance != wantBalance || sender.Nonce != wantNonce {
+				t.Errorf("відправник: баланс %d nonce %d, очікувалось %d і %d", sender.Balance, sender.Nonce, wantBalance, wantNonce)
+			}
+			if tt.wantErr && st.Snapshot() != snapshot {
+				t.Errorf("після помилки в journal залишились зміни")
+			}
+		})
+	}
+}
+
+func testVoteEvidence(t *testing.T, seed byte, height uint32) *chain.Evidence {
+	t.Helper()
+	priv, pub := testKey(t, seed)
+	votes := make([]*chain.Vote, 2)
+	for i := range votes {
+		votes[i] = &chain.Vote{Type: chain.VotePrevote, Height: height, Pub: pub, BlockHash: bytes.Repeat([]byte{byte(i + 1)}, 28)}
+		if err := votes[i].Sign("local", priv); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return chain.NewVoteEvidence(votes[0], votes[1])
+}
+
+// testSlashState стан з валідатором seed 1 (stake 1000) і його unbond: 100 до порушення на висоті 5 і 200 після
+func testSlashState(t *testing.T, n *Node) *database.StateTxn {
+	t.Helper()
+	_, pub := testKey(t, 1)
+	_, delegator := testKey(t, 3)
+
+	st := n.bs.NewStateTxn()
+	t.Cleanup(st.Discard)
+	params := &chain.ConsensusParams{SlashFractionDoubleSign: 500, EvidenceMaxAge: 100, UnbondingPeriod: 50}
+	if err := st.SetParams(params); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.SetValidator(&chain.Validator{Address: pub, Amount: 1000, DelegatorShares: 1000}); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.SetDelegation(&chain.Delegation{Validator: pub, Delegator: pub, Shares: 1000}); err != nil {
+		t.Fatal(err)
+	}
+	unbonding := &chain.Unbonding{Delegator: delegator, Validator: pub, Entries: []chain.UnbondingEntry{
+		{Amount: 100, CreationHeight: 3, CompletionHeight: 53},
+		{Amount: 200, CreationHeight: 8, CompletionHeight: 58},
+	}}
+	if err := st.SetUnbonding(unbonding); err != nil {
+		t.Fatal(err)
+	}
+	return st
+}
+
+func TestApplyEvidence(t *testing.T) {
+	tests := []struct {
+		name       string
+		evidence   func(t *testing.T) []*chain.Evidence
+		height     uint32
+		wantErr    bool    // для останнього доказу
+		slashed    bool    // чи валідатора покарано
+		wantUnbond []int64 // unbond після доказів
+	}{
+		{"подвійний голос", func(t *testing.T) []*chain.Evidence {
+			return []*chain.Evidence{testVoteEvidence(t, 1, 5)}
+		}, 20, false, true, []int64{100, 190}},
+		{"доказ на межі EvidenceMaxAge", func(t *testing.T) []*chain.Evidence {
+			return []*chain.Evidence{testVoteEvidence(t, 1, 5)}
+		}, 105, false, true, []int64{100, 200}}, // unbond після порушення вже завершився
+		{"застарілий доказ", func(t *testing.T) []*chain.Evidence {
+			return []*chain.Evidence{testVoteEvidence(t, 1, 5)}
+		}, 106, true, false, []int64{100, 200}},
+		{"доказ з майбутнього", func(t *testing.T) []*chain.Evidence {
+			return []*chain.Evidence{testVoteEvidence(t, 1, 21)}
+		}, 20, true, false, []int64{100, 200}},
+		{"не валідатор", func(t *testing.T) []*chain.Evidence {
+			return []*chain.Evidence{testVoteEvidence(t, 2, 5)}
+		}, 20, true, false, []int64{100, 200}},
+		{"той самий доказ вдруге", func(t *testing.T) []*chain.Evidence {
+			e := testVoteEvidence(t, 1, 5)
+			return []*chain.Evidence{e, e}
+		}, 20, true, true, []int64{100, 190}},
+		{"інший доказ на вже покараного", func(t *testing.T) []*chain.Evidence {
+			return []*chain.Evidence{testVoteEvidence(t, 1, 5), testVoteEvidence(t, 1, 6)}
+		}, 20, true, true, []int64{100, 190}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := testNode(t)
+			n.chainID = "local"
+			st := testSlashState(t, n)
+
+			evidence := tt.evidence(t)
+			for i, e := range evidence {
+				err := n.applyEvidence(st, tt.height, e)
+				last := i == len(evidence)-1
+				if last && (err != nil) != tt.wantErr {
+					t.Fatalf("помилка %v, очікувалась помилка: %v", err, tt.wantErr)
+				}
+				if !last && err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			_, pub := testKey(t, 1)
+			v, err := st.GetValidator(pub)
+			if err != nil {
+				t.Fatal(err)
+			}
+			wantAmount := int64(1000)
+			if tt.slashed {
+				wantAmount = 950
+			}
+			if v.Amount != wantAmount || v.Jailed != tt.slashed || v.Tombstoned != tt.slashed {
+				t.Errorf("валідатор %+v, очікувався stake %d, покараний: %v", v, wantAmount, tt.slashed)
+			}
+
+			_, delegator := testKey(t, 3)
+			u, err := st.GetUnbonding(delegator, pub)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// unbond до порушення не карається
+			for i, entry := range u.Entries {
+				if entry.Amount != tt.wantUnbond[i] {
+					t.Errorf("unbond %d: %d, очікувалось %d", i, entry.Amount, tt.wantUnbond[i])
+				}
+			}
+		})
+	}
+}
+
+// Покараний за подвійний підпис валідатор не може отримати stake і не видаляєтся після повного unbond
+func TestTombstonedValidator(t *testing.T) {
+	n := testNode(t)
+	n.chainID = "local"
+	st := testSlashState(t, n)
+	if err := n.applyEvidence(st, 20, testVoteEvidence(t, 1, 5)); err != nil {
+		t.Fatal(err)
+	}
+
+	_, pub := testKey(t, 1)
+	if canBond(st, &chain.Transaction{Kind: chain.TxBond, From: pub, Amount: 100}) == nil {
+		t.Error("покараний валідатор отримав stake")
+	}
+
+	if err := unbond(st, pub, pub, 950, 20); err != nil {
+		t.Fatal(err)
+	}
+	v, err := st.GetValidator(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v == nil || !v.Tombstoned {
+		t.Fatalf("покараного валідатора видалено після повного unbond: %+v", v)
+	}
+	if canBond(st, &chain.Transaction{Kind: chain.TxBond, From: pub, Amount: 100}) == nil {
+		t.Error("після повного unbond покараний валідатор знову отримав stake")
+	}
+}
@@ -4,9 +4,11 @@ package p2p
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"time"
 
 	"github.com/PQlite/core/chain"
+	"github.com/PQlite/core/config"
 	"github.com/PQlite/core/database"
 	"github.com/libp2p/go-libp2p"
 	dht "github.com/libp2p/go-libp2p-kad-dht"
@@ -25,18 +27,29 @@ type Node struct {
 	TxCh          chan *chain.Transaction
 	topic         *Topic
 	mempool       *chain.Mempool
+	evidence      *chain.EvidencePool // докази подвійного підпису, які ще не в блоці
 	bs            *database.BlockStorage
 	kdht          *dht.IpfsDHT
-	keys          *Keys // NOTE: не думаю, що це гарне рішення, але вже як є
-	nextProposer  chain.Validator
+	keys          *Keys      // NOTE: не думаю, що це гарне рішення, але вже як є
+	signState     *signState // останнє, що підписала ця нода як валідатор
+	chainID       string
+	cs            consensusState // змінюєтся тільки в consensusLoop
 	vote          chain.VoteCh
 	messagesQueue chan Message
+	bootstrap     []string
+	maxMsgSize    int64 // найбільше повідомлення від peer, див. maxMessageSize
 }
 
-func NewNode(ctx context.Context, mempool *chain.Mempool, bs *database.BlockStorage) (Node, error) {
+func NewNode(ctx context.Context, cfg *config.Config, mempool *chain.Mempool, bs *database.BlockStorage) (Node, error) {
 	var kdht *dht.IpfsDHT
 
-	priv, err := LoadOrCreateIdentity(".node.key")
+	// chain id береться з genesis блоку
+	genesis, err := bs.GetBlock(0)
+	if err != nil {
+		return Node{}, fmt.Errorf("помилка отримання genesis блоку: %w", err)
+	}
+
+	priv, err := LoadOrCreateIdentity(cfg.NodeKeyFile)
 	if err != nil {
 		log.Fatal().Err(err).Msg("помилка завантаження ідентифікатора")
 	}
@@ -51,7 +64,7 @@ func NewNode(ctx context.Context, mempool *chain.Mempool, bs *database.BlockStor
 			return kdht, nil
 		}),
 
-		libp2p.ListenAddrStrings("/ip6/::/tcp/4003", "/ip4/0.0.0.0/tcp/4003"),
+		libp2p.ListenAddrStrings(cfg.ListenAddrs...),
 		libp2p.Identity(priv),
 		// NAT traversal (UPnP, NAT-PMP, AutoNAT)
 		libp2p.NATPortMap(), // Пробує пробросити порт (UPnP/NAT-PMP)
@@ -70,18 +83,29 @@ func NewNode(ctx context.Context, mempool *chain.Mempool, bs *database.BlockStor
 		return Node{}, err
 	}
 
+	params, err := bs.GetParams()
+	if err != nil {
+		return Node{}, fmt.Errorf("помилка отримання параметрів консенсусу: %w", err)
+	}
+	maxMsgSize := maxMessageSize(params)
+
 	// init topic
-	topic, err := topicInit(ctx, node)
+	topic, err := topicInit(ctx, node, genesis.ChainID, maxMsgSize)
 	if err != nil {
 		return Node{}, err
 	}
 
-	keys, err := LoadKeys()
+	keys, err := LoadKeys(cfg.KeysFile)
 	if err != nil {
 		log.Error().Err(err).Msg("помилка завантаження ключів")
 		return Node{}, err
 	}
 
+	signState, err := loadSignState(cfg.SignState)
+	if err != nil {
+		return Node{}, fmt.Errorf("помилка завантаження останнього підпису: %w", err)
+	}
+
 	for _, p := range node.Addrs() {
 		log.Info().Str("address", p.String()).Str("peer_id", node.ID().String()).Msg("p2p node address")
 	}
@@ -92,12 +116,16 @@ func NewNode(ctx context.Context, mempool *chain.Mempool, bs *database.BlockStor
 		TxCh:          make(chan *chain.Transaction),
 		topic:         &topic,
 		mempool:       mempool,
+		evidence:      &chain.EvidencePool{ChainID: genesis.ChainID},
 		bs:            bs,
 		kdht:          kdht,
 		keys:          keys,
-		nextProposer:  chain.Validator{},
+		signState:     signState,
+		chainID:       genesis.ChainID,
 		vote:          make(chan chain.Vote),
 		messagesQueue: make(chan Message),
+		bootstrap:     cfg.Bootstrap,
+		maxMsgSize:    maxMsgSize,
 	}, nil
 }
 
@@ -113,13 +141,13 @@ func (n *Node) Start() {
 	go n.host.SetStreamHandler(directProtocol, n.handleStreamMessages)
 
 	n.syncBlockchain()
+	go n.consensusLoop()
 
 	<-n.ctx.Done()
 	log.Info().Msg("отримано команду зупинки в Node")
 	n.host.Close()
 }
 
-// TODO: треба перевіряти баланси і nonce перед додаванням, або перевіряти їх перед тим, як додати в блок
 func (n *Node) handleTxCh() {
 	for {
 		select {
@@ -158,6 +186,8 @@ func (n *Node) handleTxCh() {
 func (n *Node) peerDiscovery() {
 	ticker := time.NewTicker(120 * time.Second)
 
+	// ноди з різним chain id не шукают одна одну
+	ns := nsPrefix + n.chainID
 	routingDiscovery := discovery_routing.NewRoutingDiscovery(n.kdht)
 	util.Advertise(n.ctx, routingDiscovery, ns)
 
@@ -185,10 +215,11 @@ func (n *Node) peerDiscovery() {
 }
 
 func (n *Node) connectingToBootstrap() {
-	for _, addr := range BOOTSTRAPLIST {
+	for _, addr := range n.bootstrap {
 		pi, err := peer.AddrInfoFromString(addr)
 		if err != nil {
 			log.Error().Err(err).Str("address", addr).Msg("помилка отримання адреси bootstrap")
+			continue
 		}
 		err = n.host.Connect(n.ctx, *pi)
 		if err != nil {
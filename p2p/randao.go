@@ -0,0 +1,64 @@
+package p2p
+
+import (
+	"fmt"
+
+	"github.com/PQlite/core/chain"
+	"github.com/PQlite/core/database"
+)
+
+// Proposer наступного блоку вибираєтся з RandaoMix, а не з hash попереднього блоку: hash proposer
+// може змінювати (час, транзакції), підбираючи, хто буде наступним. Reveal він змінити не може,
+// тому що його hash вже в стані, тому єдиний вплив proposer - не створити блок взагалі,
+// а за це він отримує пропуск (і штраф за downtime, якщо пропусків забагато)
+
+// applyRandao перевіряє reveal proposer блоку b, додає його до RandaoMix і записує новий commit proposer.
+// Перший блок валідатора не має reveal, тому що ще нічого не зафіксовано
+func applyRandao(st *database.StateTxn, b *chain.Block) error {
+	if len(b.RandaoCommit) != chain.RandaoSize {
+		return fmt.Errorf("randao commit має бути %d байт, отримано %d", chain.RandaoSize, len(b.RandaoCommit))
+	}
+
+	commit, err := st.GetRandaoCommit(b.Proposer)
+	if err != nil {
+		return err
+	}
+	if commit == nil {
+		if len(b.RandaoReveal) != 0 {
+			return fmt.Errorf("перший блок валідатора %x не може мати randao reveal", b.Proposer)
+		}
+		return st.SetRandaoCommit(&chain.RandaoCommit{Address: b.Proposer, Commit: b.RandaoCommit})
+	}
+
+	if err := commit.VerifyReveal(b.RandaoReveal); err != nil {
+		return err
+	}
+	mix, err := st.GetRandaoMix()
+	if err != nil {
+		return err
+	}
+	if err := st.SetRandaoMix(chain.MixRandao(mix, b.RandaoReveal)); err != nil {
+		return err
+	}
+
+	commit.Commit = b.RandaoCommit
+	commit.Count++
+	return st.SetRandaoCommit(commit)
+}
+
+// randaoForBlock reveal і commit для нового блоку цієї ноди. Секрети рахуются з ключа,
+// тому номер потрібного береться з commit ноди в стані
+func (n *Node) randaoForBlock() ([]byte, []byte, error) {
+	commit, err := n.bs.GetRandaoCommit(n.keys.Pub)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var reveal []byte
+	next := uint32(0)
+	if commit != nil {
+		reveal = chain.RandaoSecret(n.chainID, n.keys.Priv, commit.Count)
+		next = commit.Count + 1
+	}
+	return reveal, chain.RandaoHash(chain.RandaoSecret(n.chainID, n.keys.Priv, next)), nil
+}
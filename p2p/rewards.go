@@ -0,0 +1,81 @@
+package p2p
+
+import (
+	"github.com/PQlite/core/chain"
+	"github.com/PQlite/core/database"
+)
+
+// payBlockRewards ділить нагороду блоку b (випуск за ConsensusParams.BlockRewardAt і fee всіх його транзакцій):
+// proposer отримує ProposerBonus, решта ділиться між підписантами LastCommit пропорційно їх stake в наборі,
+// який його підписав. Те, що залишилось від округлення, і все, якщо підписантів немає (блок 1), отримує proposer.
+// Частку кожного валідатора далі ділить з делегаторами distributeReward
+func payBlockRewards(st *database.StateTxn, b *chain.Block, fees int64) error {
+	params, err := st.GetParams()
+	if err != nil {
+		return err
+	}
+	total, err := chain.AddAmount(params.BlockRewardAt(b.Height), fees)
+	if err != nil {
+		return err
+	}
+	if total == 0 {
+		return nil
+	}
+
+	signers, power, err := lastCommitSigners(st, b)
+	if err != nil {
+		return err
+	}
+
+	toProposer := total
+	if power > 0 {
+		rest := total - chain.MulDiv(total, params.ProposerBonus, chain.RewardFractionDenominator)
+		for _, v := range signers {
+			part := chain.MulDiv(rest, v.Amount, power)
+			if part == 0 {
+				continue
+			}
+			if err := distributeReward(st, v.Address, part); err != nil {
+				return err
+			}
+			toProposer -= part
+		}
+	}
+	return distributeReward(st, b.Proposer, toProposer)
+}
+
+// lastCommitSigners валідатори з LastCommit блоку b з їх stake в наборі, який підписав LastCommit (vs_last),
+// і сума цього stake. Bond, unbond і штрафи після початку епохи на вагу не впливают, так само як на кворум.
+// Валідатори, яких вже немає або які покарані за подвійний підпис, нагороду не отримують
+func lastCommitSigners(st *database.StateTxn, b *chain.Block) ([]chain.Validator, int64, error) {
+	if b.LastCommit == nil {
+		return nil, 0, nil
+	}
+
+	// endBlock цього блоку ще не виконано, тому vs_last - набір, який створив попередній блок
+	lastSet, err := st.GetLastValidatorSet()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var signers []chain.Validator
+	var power int64
+	for _, vote := range b.LastCommit.Precommits {
+		ok, member := containsInValidators(vote.Pub, &lastSet.Validators)
+		if !ok || member.Amount == 0 {
+			continue
+		}
+		v, err := st.GetValidator(vote.Pub)
+		if err != nil {
+			return nil, 0, err
+		}
+		if v == nil || v.Tombstoned {
+			continue
+		}
+		if power, err = chain.AddAmount(power, member.Amount); err != nil {
+			return nil, 0, err
+		}
+		signers = append(signers, *member)
+	}
+	return signers, power, nil
+}
@@ -0,0 +1,78 @@
+package p2p
+
+import (
+	"testing"
+
+	"github.com/PQlite/core/chain"
+)
+
+// Нагорода підписантів LastCommit ділиться за stake в наборі, який його підписав, а не за stake в стані
+func TestPayBlockRewards(t *testing.T) {
+	_, first := testKey(t, 1)
+	_, second := testKey(t, 2)
+	_, proposer := testKey(t, 3)
+
+	tests := []struct {
+		name         string
+		signers      [][]byte
+		tombstoned   bool // другий валідатор покараний за подвійний підпис
+		fees         int64
+		wantFirst    int64
+		wantSecond   int64
+		wantProposer int64
+	}{
+		{"обидва підписали", [][]byte{first, second}, false, 0, 450, 450, 100},
+		{"з fee", [][]byte{first, second}, false, 100, 495, 495, 110},
+		{"один підписант", [][]byte{first}, false, 0, 900, 0, 100},
+		{"покараний підписант", [][]byte{first, second}, true, 0, 900, 0, 100},
+		{"без LastCommit", nil, false, 0, 0, 0, 1000},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := testNode(t)
+			st := n.bs.NewStateTxn()
+			defer st.Discard()
+
+			if err := st.SetParams(&chain.ConsensusParams{BlockReward: 1000, ProposerBonus: 1000}); err != nil {
+				t.Fatal(err)
+			}
+			// в наборі епохи в обох по 500, а в стані перший вже додав stake, а другий частину забрав
+			set := &chain.ValidatorSet{Validators: []chain.Validator{{Address: first, Amount: 500}, {Address: second, Amount: 500}}}
+			if err := st.SetLastValidatorSet(set); err != nil {
+				t.Fatal(err)
+			}
+			for _, v := range []chain.Validator{
+				{Address: first, Amount: 900, DelegatorShares: 900},
+				{Address: second, Amount: 100, DelegatorShares: 100, Jailed: tt.tombstoned, Tombstoned: tt.tombstoned},
+			} {
+				if err := st.SetValidator(&v); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			b := &chain.Block{BlockHeader: chain.BlockHeader{Height: 2, Proposer: proposer}}
+			if tt.signers != nil {
+				b.LastCommit = &chain.CommitCertificate{Height: 1}
+				for _, pub := range tt.signers {
+					b.LastCommit.Precommits = append(b.LastCommit.Precommits, chain.Vote{Type: chain.VotePrecommit, Height: 1, Pub: pub})
+				}
+			}
+
+			if err := payBlockRewards(st, b, tt.fees); err != nil {
+				t.Fatal(err)
+			}
+			for _, want := range []struct {
+				addr    []byte
+				balance int64
+			}{{first, tt.wantFirst}, {second, tt.wantSecond}, {proposer, tt.wantProposer}} {
+				w, err := st.GetWallet(want.addr)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if w.Balance != want.balance {
+					t.Errorf("%x отримав %d, очікувалось %d", want.addr[:4], w.Balance, want.balance)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,116 @@
+package p2p
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/rs/zerolog/log"
+)
+
+// signState останнє, що підписав валідатор (як privval в Tendermint). Зберігаєтся у файл до того, як
+// повідомлення розсилаєтся, тому після перезапуску нода не підпише на тому самому кроці інший блок або голос.
+// Кроки (висота, раунд, крок) підписуются тільки по зростанню. Якщо на вже підписаному кроці треба підписати
+// щось інше, підпис не робиться, і замість нового повідомлення розсилаєтся збережене
+type signState struct {
+	path string
+
+	Height    uint32          `json:"height"`
+	Round     uint32          `json:"round"`
+	Step      roundStep       `json:"step"`
+	SignBytes []byte          `json:"sign_bytes"`
+	Message   json.RawMessage `json:"message"` // підписаний Proposal або Vote
+}
+
+var errSignRegression = errors.New("вже підписано пізніший крок консенсусу")
+
+// loadSignState читає останній підпис з path. Якщо файлу немає, валідатор ще нічого не підписував
+func loadSignState(path string) (*signState, error) {
+	s := &signState{path: path}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err = json.Unmarshal(data, s); err != nil {
+		return nil, fmt.Errorf("помилка розпаковки %s: %w", path, err)
+	}
+	return s, nil
+}
+
+// signed чи вже був підпис на висоті height
+func (s *signState) signed(height uint32) bool {
+	return len(s.Message) != 0 && s.Height == height
+}
+
+// check перевіряє, чи можна підписати signBytes на кроці (height, round, step). Якщо цей крок вже підписано,
+// повертає збережене повідомлення, яке треба розіслати замість нового
+func (s *signState) check(height uint32, round uint32, step roundStep, signBytes []byte) (json.RawMessage, error) {
+	if len(s.Message) == 0 {
+		return nil, nil
+	}
+
+	switch {
+	case height != s.Height:
+		if height < s.Height {
+			return nil, errSignRegression
+		}
+		return nil, nil
+	case round != s.Round:
+		if round < s.Round {
+			return nil, errSignRegression
+		}
+		return nil, nil
+	case step != s.Step:
+		if step < s.Step {
+			return nil, errSignRegression
+		}
+		return nil, nil
+	}
+
+	if !bytes.Equal(signBytes, s.SignBytes) {
+		log.Warn().Uint32("height", height).Uint32("round", round).Int("step", int(step)).Msg("на цьому кроці вже підписано інше повідомлення, розсилаю його")
+	}
+	return s.Message, nil
+}
+
+// save зберігає підписане message на кроці (height, round, step). Спочатку пишеться тимчасовий файл,
+// тому при збої залишаєтся або старий, або новий стан
+func (s *signState) save(height uint32, round uint32, step roundStep, signBytes []byte, message json.RawMessage) error {
+	next := *s
+	next.Height, next.Round, next.Step = height, round, step
+	next.SignBytes = signBytes
+	next.Message = message
+
+	data, err := json.Marshal(&next)
+	if err != nil {
+		return err
+	}
+
+	tmp := s.path + ".tmp"
+	file, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err = file.Write(data); err != nil {
+		file.Close()
+		return err
+	}
+	if err = file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	if err = file.Close(); err != nil {
+		return err
+	}
+	if err = os.Rename(tmp, s.path); err != nil {
+		return err
+	}
+
+	*s = next
+	return nil
+}
@@ -0,0 +1,157 @@
+package p2p
+
+import (
+	"bytes"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/PQlite/core/chain"
+)
+
+func TestSignStateCheck(t *testing.T) {
+	saved := []byte("збережене")
+	s := &signState{Height: 10, Round: 2, Step: stepPrevote, SignBytes: []byte("a"), Message: saved}
+
+	tests := []struct {
+		name      string
+		height    uint32
+		round     uint32
+		step      roundStep
+		signBytes string
+		wantSaved bool
+		wantErr   error
+	}{
+		{"наступна висота", 11, 0, stepPropose, "b", false, nil},
+		{"наступний раунд", 10, 3, stepPropose, "b", false, nil},
+		{"наступний крок", 10, 2, stepPrecommit, "b", false, nil},
+		{"той самий підпис", 10, 2, stepPrevote, "a", true, nil},
+		{"інший підпис на тому самому кроці", 10, 2, stepPrevote, "b", true, nil},
+		{"попередній крок", 10, 2, stepPropose, "b", false, errSignRegression},
+		{"попередній раунд", 10, 1, stepPrecommit, "b", false, errSignRegression},
+		{"попередня висота", 9, 5, stepPrecommit, "b", false, errSignRegression},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := s.check(tt.height, tt.round, tt.step, []byte(tt.signBytes))
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("помилка %v, очікувалось %v", err, tt.wantErr)
+			}
+			if (got != nil) != tt.wantSaved || (got != nil && !bytes.Equal(got, saved)) {
+				t.Errorf("збережене повідомлення %q, очікувалось: %v", got, tt.wantSaved)
+			}
+		})
+	}
+
+	empty := &signState{}
+	if got, err := empty.check(1, 0, stepPropose, []byte("a")); got != nil || err != nil {
+		t.Errorf("без підписів: %q, %v", got, err)
+	}
+}
+
+// testSignNode нода-валідатор, стан підписів якої зберігаєтся в path
+func testSignNode(t *testing.T, path string) *Node {
+	t.Helper()
+	priv, pub := testKey(t, 1)
+	s, err := loadSignState(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &Node{chainID: "local", keys: &Keys{Priv: priv, Pub: pub}, signState: s}
+}
+
+// Після перезапуску нода не підписує на тому самому кроці інший голос, а повертає збережений
+func TestSignVoteAfterRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sign_state.json")
+	n := testSignNode(t, path)
+	_, pub := testKey(t, 1)
+	hashA, hashB := bytes.Repeat([]byte{0xaa}, 28), bytes.Repeat([]byte{0xbb}, 28)
+
+	first, err := n.signVote(&chain.Vote{Type: chain.VotePrevote, Height: 5, Round: 1, BlockHash: hashA, Pub: pub})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	n = testSignNode(t, path)
+	tests := []struct {
+		name     string
+		vote     chain.Vote
+		wantHash []byte
+		wantErr  error
+	}{
+		{"той самий голос", chain.Vote{Type: chain.VotePrevote, Height: 5, Round: 1, BlockHash: hashA}, hashA, nil},
+		{"інший блок", chain.Vote{Type: chain.VotePrevote, Height: 5, Round: 1, BlockHash: hashB}, hashA, nil},
+		{"nil", chain.Vote{Type: chain.VotePrevote, Height: 5, Round: 1}, hashA, nil},
+		{"попередній раунд", chain.Vote{Type: chain.VotePrecommit, Height: 5, Round: 0, BlockHash: hashB}, nil, errSignRegression},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.vote.Pub = pub
+			got, err := n.signVote(&tt.vote)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("помилка %v, очікувалось %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if !bytes.Equal(got.BlockHash, tt.wantHash) || !bytes.Equal(got.Signature, first.Signature) {
+				t.Errorf("голос за %x, очікувався збережений голос за %x", got.BlockHash, tt.wantHash)
+			}
+			if err := got.Verify(n.chainID); err != nil {
+				t.Errorf("збережений голос не валідний: %v", err)
+			}
+		})
+	}
+
+	// наступний крок підписуєтся і зберігаєтся
+	precommit, err := n.signVote(&chain.Vote{Type: chain.VotePrecommit, Height: 5, Round: 1, BlockHash: hashB, Pub: pub})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n = testSignNode(t, path); n.signState.Step != stepPrecommit || !bytes.Equal(n.signState.SignBytes, precommit.SigningBytes(n.chainID)) {
+		t.Errorf("після precommit збережено крок %d", n.signState.Step)
+	}
+}
+
+func TestSignProposalAfterRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sign_state.json")
+	n := testSignNode(t, path)
+	priv, pub := testKey(t, 1)
+
+	block := func(timestamp int64) chain.Block {
+		b := chain.Block{BlockHeader: chain.BlockHeader{ChainID: "local", Height: 7, Round: 0, Timestamp: timestamp, Proposer: pub}}
+		if err := b.GenerateHash(); err != nil {
+			t.Fatal(err)
+		}
+		if err := b.Sign(priv); err != nil {
+			t.Fatal(err)
+		}
+		return b
+	}
+
+	first, err := n.signProposal(&chain.Proposal{Round: 0, POLRound: -1, Block: block(1000), Pub: pub})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// після перезапуску proposer створює інший блок для того самого раунду
+	n = testSignNode(t, path)
+	got, err := n.signProposal(&chain.Proposal{Round: 0, POLRound: -1, Block: block(2000), Pub: pub})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got.Block.Hash, first.Block.Hash) || !bytes.Equal(got.Signature, first.Signature) {
+		t.Errorf("підписано блок %x, очікувався збережений %x", got.Block.Hash, first.Block.Hash)
+	}
+	if err := got.Verify(); err != nil {
+		t.Errorf("збережений proposal не валідний: %v", err)
+	}
+
+	next, err := n.signProposal(&chain.Proposal{Round: 1, POLRound: -1, Block: block(2000), Pub: pub})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(next.Block.Hash, first.Block.Hash) {
+		t.Error("в наступному раунді повернуто збережений proposal")
+	}
+}
@@ -0,0 +1,323 @@
+package p2p
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/PQlite/core/chain"
+	"github.com/PQlite/core/database"
+	"github.com/rs/zerolog/log"
+)
+
+// Stake змінюєтся тільки транзакціями:
+//   - bond: сума списуєтся з балансу і делегуєтся валідатору To. Порожній To - сам відправник,
+//     якщо такого валідатора ще немає, він створюєтся
+//   - unbond: сума знімаєтся з делегації, але ще UnbondingPeriod блоків заблокована і може бути покарана
+//   - withdraw: всі суми, в яких закінчився UnbondingPeriod, повертаются на баланс
+//   - commission: валідатор змінює, яку частину нагороди залишає собі
+//
+// Делегатор отримує shares - частку в stake валідатора. Штраф зменшує stake, а не shares, тому
+// ділиться між делегаторами пропорційно. Валідатор без жодної делегації видаляєтся зі стану.
+// Покараного за подвійний підпис не видаляю, щоб він не міг повернутись новим bond на ту ж адресу
+
+// stakingValidator адреса валідатора транзакції bond або unbond
+func stakingValidator(tx *chain.Transaction) []byte {
+	if len(tx.To) == 0 {
+		return tx.From
+	}
+	return tx.To
+}
+
+func canBond(st *database.StateTxn, tx *chain.Transaction) error {
+	if tx.Amount <= 0 {
+		return fmt.Errorf("сума bond має бути більшою за 0")
+	}
+	addr := stakingValidator(tx)
+	validator, err := st.GetValidator(addr)
+	if err != nil {
+		return err
+	}
+	if validator == nil {
+		if !bytes.Equal(addr, tx.From) {
+			return fmt.Errorf("%x не є валідатором", addr)
+		}
+		return nil
+	}
+	if validator.Tombstoned {
+		return fmt.Errorf("валідатор %x покараний за подвійний підпис і не може отримати stake", addr)
+	}
+	_, err = validator.AddTokens(tx.Amount)
+	return err
+}
+
+func canUnbond(st *database.StateTxn, tx *chain.Transaction) error {
+	if tx.Amount <= 0 {
+		return fmt.Errorf("сума unbond має бути більшою за 0")
+	}
+	addr := stakingValidator(tx)
+	validator, err := st.GetValidator(addr)
+	if err != nil {
+		return err
+	}
+	if validator == nil {
+		return fmt.Errorf("%x не є валідатором", addr)
+	}
+	delegation, err := st.GetDelegation(addr, tx.From)
+	if err != nil {
+		return err
+	}
+	if delegation == nil {
+		return fmt.Errorf("%x не делегував валідатору %x", tx.From, addr)
+	}
+	if _, err := validator.RemoveTokens(delegation, tx.Amount); err != nil {
+		return err
+	}
+
+	unbonding, err := st.GetUnbonding(tx.From, addr)
+	if err != nil {
+		return err
+	}
+	if len(unbonding.Entries) >= chain.MaxUnbondingEntries {
+		return fmt.Errorf("%x вже має %d незавершених unbond у валідатора %x", tx.From, len(unbonding.Entries), addr)
+	}
+	return nil
+}
+
+func canWithdraw(st *database.StateTxn, tx *chain.Transaction, height uint32) error {
+	if tx.Amount != 0 {
+		return fmt.Errorf("транзакція withdraw не може мати суму")
+	}
+	if len(tx.To) != 0 {
+		return fmt.Errorf("транзакція withdraw не може мати отримувача")
+	}
+	matured, err := maturedUnbondings(st, tx.From, height)
+	if err != nil {
+		return err
+	}
+	if matured == 0 {
+		return fmt.Errorf("%x не має сум, які можна повернути на висоті %d", tx.From, height)
+	}
+	return nil
+}
+
+func canSetCommission(st *database.StateTxn, tx *chain.Transaction) error {
+	if len(tx.To) != 0 {
+		return fmt.Errorf("транзакція commission не може мати отримувача")
+	}
+	if tx.Amount < 0 || tx.Amount > chain.CommissionDenominator {
+		return fmt.Errorf("комісія має бути від 0 до %d", chain.CommissionDenominator)
+	}
+	validator, err := st.GetValidator(tx.From)
+	if err != nil {
+		return err
+	}
+	if validator == nil {
+		return fmt.Errorf("%x не є валідатором", tx.From)
+	}
+	return nil
+}
+
+// bond делегує amount валідатору addr від delegator. Баланс вже списаний в applyTx
+func bond(st *database.StateTxn, addr []byte, delegator []byte, amount int64) error {
+	validator, err := st.GetValidator(addr)
+	if err != nil {
+		return err
+	}
+	if validator == nil {
+		validator = &chain.Validator{Address: addr}
+	}
+	delegation, err := st.GetDelegation(addr, delegator)
+	if err != nil {
+		return err
+	}
+	if delegation == nil {
+		delegation = &chain.Delegation{Validator: addr, Delegator: delegator}
+	}
+
+	shares, err := validator.AddTokens(amount)
+	if err != nil {
+		return err
+	}
+	if delegation.Shares, err = chain.AddAmount(delegation.Shares, shares); err != nil {
+		return err
+	}
+
+	if err := st.SetValidator(validator); err != nil {
+		return err
+	}
+	return st.SetDelegation(delegation)
+}
+
+// unbond знімає amount з делегації delegator валідатору addr і блокує його до висоти height+UnbondingPeriod
+func unbond(st *database.StateTxn, addr []byte, delegator []byte, amount int64, height uint32) error {
+	params, err := st.GetParams()
+	if err != nil {
+		return err
+	}
+	validator, err := st.GetValidator(addr)
+	if err != nil {
+		return err
+	}
+	delegation, err := st.GetDelegation(addr, delegator)
+	if err != nil {
+		return err
+	}
+	if _, err := validator.RemoveTokens(delegation, amount); err != nil {
+		return err
+	}
+	if err := st.SetDelegation(delegation); err != nil {
+		return err
+	}
+
+	if validator.DelegatorShares == 0 && !validator.Tombstoned {
+		if err := st.DeleteValidator(addr); err != nil {
+			return err
+		}
+		if err := st.DeleteLiveness(addr); err != nil {
+			return err
+		}
+		// RandaoCommit залишаєтся: інакше, забравши весь stake і повернувшись, валідатор не відкрив би
+		// зафіксований секрет, а зафіксував би новий, коли вже відомо, кого вибере старий
+	} else if err := st.SetValidator(validator); err != nil {
+		return err
+	}
+
+	unbonding, err := st.GetUnbonding(delegator, addr)
+	if err != nil {
+		return err
+	}
+	unbonding.Entries = append(unbonding.Entries, chain.UnbondingEntry{
+		Amount:           amount,
+		CreationHeight:   height,
+		CompletionHeight: height + uint32(params.UnbondingPeriod),
+	})
+	return st.SetUnbonding(unbonding)
+}
+
+// maturedUnbondings сума всіх unbond делегатора, які можна повернути на висоті height
+func maturedUnbondings(st *database.StateTxn, delegator []byte, height uint32) (int64, error) {
+	unbondings, err := st.GetUnbondings(delegator)
+	if err != nil {
+		return 0, err
+	}
+	var total int64
+	for _, u := range unbondings {
+		matured, err := u.Matured(height)
+		if err != nil {
+			return 0, err
+		}
+		if total, err = chain.AddAmount(total, matured); err != nil {
+			return 0, err
+		}
+	}
+	return total, nil
+}
+
+// withdraw повертає на баланс delegator всі суми, в яких на висоті height закінчився UnbondingPeriod
+func withdraw(st *database.StateTxn, delegator []byte, height uint32) error {
+	matured, err := maturedUnbondings(st, delegator, height)
+	if err != nil {
+		return err
+	}
+	unbondings, err := st.GetUnbondings(delegator)
+	if err != nil {
+		return err
+	}
+	for _, u := range unbondings {
+		u.RemoveMatured(height)
+		if err := st.SetUnbonding(u); err != nil {
+			return err
+		}
+	}
+
+	wallet, err := st.GetWallet(delegator)
+	if err != nil {
+		return err
+	}
+	if err := wallet.Credit(matured); err != nil {
+		return err
+	}
+	return st.SetWallet(&wallet)
+}
+
+func setCommission(st *database.StateTxn, addr []byte, commission int64) error {
+	validator, err := st.GetValidator(addr)
+	if err != nil {
+		return err
+	}
+	validator.Commission = commission
+	return st.SetValidator(validator)
+}
+
+// distributeReward ділить amount між валідатором addr і його делегаторами: спочатку комісія валідатора,
+// решта пропорційно shares. Якщо валідатора вже немає, все отримує адреса addr
+func distributeReward(st *database.StateTxn, addr []byte, amount int64) error {
+	validator, err := st.GetValidator(addr)
+	if err != nil {
+		return err
+	}
+	if validator == nil {
+		return credit(st, addr, amount)
+	}
+
+	delegations, err := st.GetDelegations(addr)
+	if err != nil {
+		return err
+	}
+	toValidator, parts := validator.SplitReward(amount, delegations)
+	for i, d := range delegations {
+		if parts[i] == 0 {
+			continue
+		}
+		if err := credit(st, d.Delegator, parts[i]); err != nil {
+			return err
+		}
+	}
+	return credit(st, addr, toValidator)
+}
+
+func credit(st *database.StateTxn, addr []byte, amount int64) error {
+	if amount == 0 {
+		return nil
+	}
+	wallet, err := st.GetWallet(addr)
+	if err != nil {
+		return err
+	}
+	if err := wallet.Credit(amount); err != nil {
+		return err
+	}
+	return st.SetWallet(&wallet)
+}
+
+// slashValidator спалює частину fraction stake валідатора v і незавершених на висоті height unbond з нього,
+// зберігає v і повертає, скільки спалено. Shares делегаторів не змінюются, тому штраф ділиться між ними
+// пропорційно. Unbond, створені на висоті порушення infractionHeight або пізніше, теж караются, інакше можна було б
+// уникнути штрафу, забравши stake одразу після порушення. Створені раніше вже не були в stake під час порушення
+func slashValidator(st *database.StateTxn, v *chain.Validator, fraction int64, infractionHeight uint32, height uint32) (int64, error) {
+	slash := chain.SlashAmount(v.Amount, fraction)
+	var err error
+	if v.Amount, err = chain.SubAmount(v.Amount, slash); err != nil {
+		return 0, err
+	}
+	if err := st.SetValidator(v); err != nil {
+		return 0, err
+	}
+
+	unbondings, err := st.GetValidatorUnbondings(v.Address)
+	if err != nil {
+		return 0, err
+	}
+	for _, u := range unbondings {
+		unbondingSlash := u.Slash(fraction, infractionHeight, height)
+		if err := st.SetUnbonding(u); err != nil {
+			return 0, err
+		}
+		log.Debug().Hex("валідатор", v.Address).Hex("делегатор", u.Delegator).Int64("спалено", unbondingSlash).Msg("покарано незавершені unbond")
+
+		if slash, err = chain.AddAmount(slash, unbondingSlash); err != nil {
+			return 0, err
+		}
+	}
+	return slash, nil
+}
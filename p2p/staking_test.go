@@ -0,0 +1,144 @@
+package p2p
+
+import (
+	"testing"
+
+	"github.com/PQlite/core/chain"
+)
+
+// slashValidator карає stake валідатора і всі його unbond, які ще були в stake на висоті порушення,
+// а unbond з інших валідаторів не чіпає
+func TestSlashValidator(t *testing.T) {
+	_, pub := testKey(t, 1)
+	_, other := testKey(t, 2)
+	_, alice := testKey(t, 3)
+	_, bob := testKey(t, 4)
+
+	tests := []struct {
+		name             string
+		fraction         int64
+		infractionHeight uint32
+		height           uint32
+		wantAmount       int64
+		wantSlash        int64
+		wantAlice        []int64
+		wantBob          []int64
+	}{
+		{"всі unbond після порушення", 1000, 2, 20, 900, 100 + 10 + 20 + 30, []int64{90, 180}, []int64{270}},
+		{"частина unbond до порушення", 1000, 5, 20, 900, 100 + 20 + 30, []int64{100, 180}, []int64{270}},
+		{"частина unbond завершилась", 1000, 2, 55, 900, 100 + 20 + 30, []int64{100, 180}, []int64{270}},
+		{"без штрафу", 0, 2, 20, 1000, 0, []int64{100, 200}, []int64{300}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := testNode(t)
+			st := n.bs.NewStateTxn()
+			defer st.Discard()
+
+			v := &chain.Validator{Address: pub, Amount: 1000, DelegatorShares: 1000}
+			if err := st.SetValidator(v); err != nil {
+				t.Fatal(err)
+			}
+			unbondings := []*chain.Unbonding{
+				{Delegator: alice, Validator: pub, Entries: []chain.UnbondingEntry{
+					{Amount: 100, CreationHeight: 3, CompletionHeight: 53},
+					{Amount: 200, CreationHeight: 8, CompletionHeight: 58},
+				}},
+				{Delegator: bob, Validator: pub, Entries: []chain.UnbondingEntry{{Amount: 300, CreationHeight: 10, CompletionHeight: 60}}},
+				{Delegator: alice, Validator: other, Entries: []chain.UnbondingEntry{{Amount: 400, CreationHeight: 10, CompletionHeight: 60}}},
+			}
+			for _, u := range unbondings {
+				if err := st.SetUnbonding(u); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			slash, err := slashValidator(st, v, tt.fraction, tt.infractionHeight, tt.height)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if slash != tt.wantSlash {
+				t.Errorf("спалено %d, очікувалось %d", slash, tt.wantSlash)
+			}
+			if v, err = st.GetValidator(pub); err != nil {
+				t.Fatal(err)
+			}
+			if v.Amount != tt.wantAmount || v.DelegatorShares != 1000 {
+				t.Errorf("валідатор після штрафу: %+v", v)
+			}
+
+			for _, want := range []struct {
+				delegator, validator []byte
+				amounts              []int64
+			}{
+				{alice, pub, tt.wantAlice},
+				{bob, pub, tt.wantBob},
+				{alice, other, []int64{400}},
+			} {
+				u, err := st.GetUnbonding(want.delegator, want.validator)
+				if err != nil {
+					t.Fatal(err)
+				}
+				for i, e := range u.Entries {
+					if e.Amount != want.amounts[i] {
+						t.Errorf("unbond %x з %x: %d, очікувалось %d", want.delegator[:4], want.validator[:4], e.Amount, want.amounts[i])
+					}
+				}
+			}
+		})
+	}
+}
+
+// Після повного unbond RandaoCommit залишаєтся, тому валідатор, який повернувся, має відкрити зафіксований секрет
+func TestUnbondKeepsRandaoCommit(t *testing.T) {
+	n := testNode(t)
+	st := n.bs.NewStateTxn()
+	defer st.Discard()
+	priv, pub := testKey(t, 1)
+
+	if err := st.SetParams(&chain.ConsensusParams{UnbondingPeriod: 10}); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.SetRandaoMix(make([]byte, chain.RandaoSize)); err != nil {
+		t.Fatal(err)
+	}
+	if err := bond(st, pub, pub, 100); err != nil {
+		t.Fatal(err)
+	}
+	committed := chain.RandaoSecret("local", priv, 0)
+	if err := st.SetRandaoCommit(&chain.RandaoCommit{Address: pub, Commit: chain.RandaoHash(committed)}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := unbond(st, pub, pub, 100, 5); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := st.GetValidator(pub); err != nil || v != nil {
+		t.Fatalf("валідатор після повного unbond: %+v, %v", v, err)
+	}
+	if c, err := st.GetRandaoCommit(pub); err != nil || c == nil {
+		t.Fatalf("RandaoCommit видалено після повного unbond: %v", err)
+	}
+
+	if err := bond(st, pub, pub, 100); err != nil {
+		t.Fatal(err)
+	}
+	next := chain.RandaoHash(chain.RandaoSecret("local", priv, 1))
+	tests := []struct {
+		name    string
+		reveal  []byte
+		wantErr bool
+	}{
+		{"без reveal, як перший блок", nil, true},
+		{"новий секрет", chain.RandaoSecret("local", priv, 5), true},
+		{"зафіксований секрет", committed, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := &chain.Block{BlockHeader: chain.BlockHeader{Height: 20, Proposer: pub, RandaoReveal: tt.reveal, RandaoCommit: next}}
+			if err := applyRandao(st, b); (err != nil) != tt.wantErr {
+				t.Errorf("помилка %v, очікувалась помилка: %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -3,10 +3,13 @@ package p2p
 import (
 	"bufio"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"time"
 
 	"github.com/PQlite/core/chain"
+	"github.com/PQlite/core/database"
 	"github.com/libp2p/go-libp2p/core/network"
 	"github.com/libp2p/go-libp2p/core/peer"
 	"github.com/rs/zerolog/log"
@@ -23,8 +26,8 @@ func (n *Node) handleStreamMessages(stream network.Stream) {
 		stream.Close()
 	}()
 
-	// Створюємо reader для читання даних з потоку
-	reader := bufio.NewReader(stream)
+	// Створюємо reader для читання даних з потоку. Більше за maxMsgSize не читаю, тоді ReadBytes поверне помилку
+	reader := bufio.NewReader(io.LimitReader(stream, n.maxMsgSize))
 	// Читаємо дані до символу нового рядка. Це простий спосіб розділяти повідомлення.
 	reqBytes, err := reader.ReadBytes('\n')
 	if err != nil {
@@ -40,7 +43,7 @@ func (n *Node) handleStreamMessages(stream network.Stream) {
 	}
 
 	switch msg.Type {
-	case MsgRequestBlock: // HACK: ну тут треба точно переписувати, тому що зараз це жахливо
+	case MsgRequestBlock:
 		var data chain.Block
 		if err := json.Unmarshal(msg.Data, &data); err != nil {
 			log.Error().Err(err).Msg("помилка розпаковки block з запиту на блок")
@@ -51,71 +54,70 @@ func (n *Node) handleStreamMessages(stream network.Stream) {
 			log.Error().Err(err).Msg("помилка бази даних")
 			return
 		}
-		if lastBlock.Height <= data.Height {
-			respBlockBytes, err := json.Marshal(lastBlock)
-			if err != nil {
-				log.Fatal().Err(err).Msg("помилка розпаковки останнього блоку")
-			}
-
-			respMsg := Message{
-				Type:      MsgResponeBlock,
-				Timestamp: time.Now().UnixMilli(),
-				Data:      respBlockBytes,
-				Pub:       n.keys.Pub,
-			}
-
-			err = respMsg.sign(n.keys.Priv)
-			if err != nil {
-				log.Fatal().Err(err).Msg("помилка підпису повідомлення")
-			}
-
-			respBytes, err := json.Marshal(respMsg)
-			if err != nil {
-				log.Fatal().Err(err).Msg("помилка розпаковки повідомлення")
-			}
-			writer := bufio.NewWriter(stream)
-			_, err = writer.Write(append(respBytes, '\n'))
-			if err != nil {
-				log.Fatal().Err(err).Msg("помилка запису в потік")
-			}
-			writer.Flush()
-		} else {
-			reqBlock, err := n.bs.GetBlock(data.Height)
-			if err != nil {
-				log.Fatal().Err(err).Msg("помилка отримання блоку")
-			}
-			reqBlockBytes, err := json.Marshal(reqBlock)
-			if err != nil {
-				log.Fatal().Err(err).Msg("помилка розпаковки блоку")
-			}
-
-			respMsg := Message{
-				Type:      MsgResponeBlock,
-				Timestamp: time.Now().UnixMilli(),
-				Data:      reqBlockBytes,
-				Pub:       n.keys.Pub,
-			}
-
-			err = respMsg.sign(n.keys.Priv)
-			if err != nil {
-				log.Fatal().Err(err).Msg("помилка підпису повідомлення")
-			}
-
-			respBytes, err := json.Marshal(respMsg)
-			if err != nil {
-				log.Fatal().Err(err).Msg("помилка розпаковки повідомлення")
-			}
-
-			writer := bufio.NewWriter(stream)
-			_, err = writer.Write(append(respBytes, '\n'))
-			if err != nil {
-				log.Fatal().Err(err).Msg("помилка запису в потік")
-			}
-			if err = writer.Flush(); err != nil {
-				log.Err(err).Msg("помилка відправки повідомлення")
-			}
+
+		// якщо такого блоку ще немає, відповідь - останній блок, і той, хто питав, розуміє, що в нього все є
+		height := data.Height
+		if lastBlock.Height < height {
+			height = lastBlock.Height
+		}
+
+		commit, err := n.getStoredCommit(height)
+		if err != nil {
+			log.Error().Err(err).Uint32("height", height).Msg("помилка отримання блоку")
+			return
+		}
+		respBytes, err := json.Marshal(commit)
+		if err != nil {
+			log.Error().Err(err).Msg("помилка розпаковки commit")
+			return
 		}
+
+		respMsg := Message{
+			Type:      MsgResponeBlock,
+			Timestamp: time.Now().UnixMilli(),
+			Data:      respBytes,
+			Pub:       n.keys.Pub,
+		}
+		if err = respMsg.sign(n.keys.Priv); err != nil {
+			log.Error().Err(err).Msg("помилка підпису повідомлення")
+			return
+		}
+
+		if err = writeStreamMessage(stream, &respMsg); err != nil {
+			log.Err(err).Msg("помилка відправки повідомлення")
+		}
+	}
+}
+
+// getStoredCommit повертає збережений блок разом з його сертифікатом. В genesis блоку сертифікату немає
+func (n *Node) getStoredCommit(height uint32) (*Commit, error) {
+	block, err := n.bs.GetBlock(height)
+	if err != nil {
+		return nil, err
+	}
+
+	commit := &Commit{Block: *block}
+
+	cert, err := n.bs.GetCommit(height)
+	if err == nil {
+		commit.Certificate = *cert
+	} else if !errors.Is(err, database.ErrNoCommit) {
+		return nil, err
+	}
+	return commit, nil
+}
+
+func writeStreamMessage(stream network.Stream, msg *Message) error {
+	msgBytes, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	writer := bufio.NewWriter(stream)
+	if _, err = writer.Write(append(msgBytes, '\n')); err != nil {
+		return err
 	}
+	return writer.Flush()
 }
 
 func (n *Node) sendStreamMessage(targetPeer peer.ID, msg *Message) (*Message, error) {
@@ -126,7 +128,7 @@ func (n *Node) sendStreamMessage(targetPeer peer.ID, msg *Message) (*Message, er
 	defer stream.Close()
 
 	writer := bufio.NewWriter(stream)
-	reader := bufio.NewReader(stream)
+	reader := bufio.NewReader(io.LimitReader(stream, n.maxMsgSize))
 
 	msgBytes, err := json.Marshal(msg)
 	if err != nil {
@@ -1,7 +1,6 @@
 package p2p
 
 import (
-	"bytes"
 	"encoding/json"
 	"time"
 
@@ -11,18 +10,17 @@ import (
 	"github.com/rs/zerolog/log"
 )
 
+// syncBlockchain запитує наступні блоки в peer, поки не отримає останній. Peer, який не відповів
+// або відповів не тим, більше не питаю, а беру іншого
 func (n *Node) syncBlockchain() {
 	// OPTIMIZE: зробити отримання нових блоків в btach
+	failed := make(map[peer.ID]bool)
 	for {
 		localBlockHeight, err := n.bs.GetLastBlock()
 		if err != nil {
 			panic(err)
 		}
-		if err := n.setNextProposer(); err != nil {
-			panic(err)
-		}
-
-		data, err := json.Marshal(chain.Block{Height: localBlockHeight.Height + 1})
+		data, err := json.Marshal(chain.Block{BlockHeader: chain.BlockHeader{Height: localBlockHeight.Height + 1}})
 		if err != nil {
 			log.Fatal().Err(err).Msg("помилка розпаковки блоку")
 		}
@@ -38,62 +36,66 @@ func (n *Node) syncBlockchain() {
 			log.Fatal().Err(err).Msg("помилка підпису повідомлення")
 		}
 
-		peerForSync := n.chooseRandomPeer()
+		peerForSync := n.chooseRandomPeer(failed)
 		if peerForSync == nil {
 			log.Warn().Msg("не було знайдено peer для синхронізації")
 			return
 		}
 		respMsg, err := n.sendStreamMessage(*peerForSync, &m)
 		if err != nil {
-			log.Fatal().Err(err).Msg("помилка відправки повідомлення")
+			log.Error().Err(err).Str("peer_id", peerForSync.String()).Msg("помилка запиту блоку в peer")
+			failed[*peerForSync] = true
+			continue
 		}
 
-		var respBlock chain.Block
-		if err = json.Unmarshal(respMsg.Data, &respBlock); err != nil {
-			log.Fatal().Err(err).Msg("помилка розпаковки блоку")
+		var commit Commit
+		if err = json.Unmarshal(respMsg.Data, &commit); err != nil {
+			log.Error().Err(err).Str("peer_id", peerForSync.String()).Msg("помилка розпаковки блоку")
+			failed[*peerForSync] = true
+			continue
 		}
 
 		// це якщо запитаного блоку не існує. це означає, що локальна база вже актуальна і має останній блок
 		// TODO: винести в окерму функцію
-		if respBlock.Height < localBlockHeight.Height+1 {
+		if commit.Block.Height < localBlockHeight.Height+1 {
 			log.Info().Msg("blockchain is up to date!")
-
-			if err := n.setNextProposer(); err != nil {
-				panic(err)
-			}
-
-			if bytes.Equal(n.nextProposer.Address, n.keys.Pub) {
-				blockProposalMsg, err := n.getMsgBlockProposalMsg()
-				if err != nil {
-					panic(err)
-				}
-
-				if err := n.topic.broadcast(blockProposalMsg, n.ctx); err != nil {
-					panic(err)
-				}
-			}
 			return
 		}
+		if commit.Block.Height != localBlockHeight.Height+1 {
+			log.Warn().Uint32("height", commit.Block.Height).Str("peer_id", peerForSync.String()).Msg("peer відповів не тим блоком")
+			failed[*peerForSync] = true
+			continue
+		}
 
-		if err := n.fullBlockVerefication(&respBlock); err != nil {
-			log.Fatal().Err(err).Msg("помилочка")
+		// блок приймаєтся тільки з сертифікатом від набору валідаторів, який створює цей блок,
+		// тому один peer не може підсунути свій блок
+		set, err := n.bs.GetValidatorSet()
+		if err != nil {
+			log.Error().Err(err).Msg("помилка отримання набору валідаторів")
+			return
 		}
-		if err := n.bs.SaveBlock(&respBlock); err != nil {
-			panic(err)
+		if err := n.verifyCommit(&commit, set.Validators); err != nil {
+			log.Error().Err(err).Uint32("height", commit.Block.Height).Str("peer_id", peerForSync.String()).Msg("блок від peer не має валідного сертифікату")
+			failed[*peerForSync] = true
+			continue
 		}
-		if err := n.addValidatorsToDB(&respBlock); err != nil {
-			panic(err)
+		if err := n.fullBlockVerefication(&commit.Block); err != nil {
+			log.Error().Err(err).Uint32("height", commit.Block.Height).Msg("блок від peer не пройшов перевірку")
+			return
 		}
-		if err := n.updateBalancesNonces(&respBlock); err != nil {
-			panic(err)
+		if err := n.applyBlock(&commit.Block, &commit.Certificate); err != nil {
+			log.Error().Err(err).Uint32("height", commit.Block.Height).Msg("помилка застосування блоку")
+			return
 		}
-		log.Info().Uint32("height", respBlock.Height).Int64("latency", time.Now().UnixMilli()-respMsg.Timestamp).Msg("додано новий блок до ланцюжка")
+		log.Info().Uint32("height", commit.Block.Height).Int64("latency", time.Now().UnixMilli()-respMsg.Timestamp).Msg("додано новий блок до ланцюжка")
+		n.promoteMempool(commit.Block.Height + 1)
 	}
 }
 
-func (n *Node) chooseRandomPeer() *peer.ID {
+// chooseRandomPeer повертає підключеного peer, крім тих, які є в exclude
+func (n *Node) chooseRandomPeer(exclude map[peer.ID]bool) *peer.ID {
 	for _, p := range n.host.Peerstore().Peers() {
-		if p == n.host.ID() {
+		if p == n.host.ID() || exclude[p] {
 			continue
 		}
 		if n.host.Network().Connectedness(p) != network.Connected {
@@ -15,13 +15,14 @@ type Topic struct {
 	ps    *pubsub.PubSub
 }
 
-func topicInit(ctx context.Context, node host.Host) (Topic, error) {
-	ps, err := pubsub.NewGossipSub(ctx, node)
+// topicInit підключаєтся до gossip мережі chainID. Повідомлення, більші за maxSize, pubsub відкидає ще до розпаковки
+func topicInit(ctx context.Context, node host.Host, chainID string, maxSize int64) (Topic, error) {
+	ps, err := pubsub.NewGossipSub(ctx, node, pubsub.WithMaxMessageSize(int(maxSize)))
 	if err != nil {
 		return Topic{}, err
 	}
 
-	topic, err := ps.Join("test-topic")
+	topic, err := ps.Join(topicPrefix + chainID)
 	if err != nil {
 		return Topic{}, err
 	}